@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -10,22 +17,61 @@ import (
 
 	"github.com/marceterrone10/social/docs"
 	"github.com/marceterrone10/social/internal/auth"
+	"github.com/marceterrone10/social/internal/blob"
+	"github.com/marceterrone10/social/internal/events"
+	"github.com/marceterrone10/social/internal/logging"
 	"github.com/marceterrone10/social/internal/mailer"
+	"github.com/marceterrone10/social/internal/media"
+	"github.com/marceterrone10/social/internal/metrics"
+	"github.com/marceterrone10/social/internal/ranking"
 	"github.com/marceterrone10/social/internal/ratelimiter"
+	"github.com/marceterrone10/social/internal/realip"
 	"github.com/marceterrone10/social/internal/store"
 	"github.com/marceterrone10/social/internal/store/cache"
+	"github.com/marceterrone10/social/internal/stream"
+	"github.com/marceterrone10/social/internal/suggestions"
+	"github.com/marceterrone10/social/internal/timeline"
+	"github.com/marceterrone10/social/internal/tracing"
+	"github.com/marceterrone10/social/internal/trending"
+	"github.com/redis/go-redis/v9"
 	httpSwagger "github.com/swaggo/http-swagger" // http-swagger middleware
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
 type application struct {
 	config        config
 	store         store.Storage // inyeccion de dependencias, paso el store a la aplicación
 	logger        *zap.SugaredLogger
+	db            *sql.DB       // para los probes del healthcheck
+	redis         *redis.Client // nil si Redis no esta habilitado
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	cacheStorage  cache.Storage
-	rateLimiter   ratelimiter.Limiter
+	rateLimiter   *ratelimiter.Policies
+	blobStorage   blob.Storage
+	mediaWorker   *media.Worker
+	events        *events.Bus
+	streamBroker  stream.Broker
+	timeline      *timeline.Service
+	ranker        *ranking.Ranker
+	trending      *trending.Service
+	suggestions   *suggestions.Service
+
+	// invalida el cache en todas las instancias
+	cacheInvalidator *cache.Invalidator
+	cacheStats       *cache.Stats
+	// agrupa las cargas concurrentes de una misma entrada del cache
+	cacheGroup singleflight.Group
+	metrics    *metrics.Metrics
+
+	// false hasta que el server arranca y desde que empieza a apagarse
+	ready atomic.Bool
+	// ultimo resultado de los probes de /readyz
+	readiness readinessCache
+	// se cancela al apagar el server para cerrar las conexiones del stream
+	streamsCtx  context.Context
+	stopStreams context.CancelFunc
 }
 
 type config struct {
@@ -37,7 +83,21 @@ type config struct {
 	frontendURL string
 	auth        authConfig
 	redis       redisConfig
+	cache       cacheConfig
+	shutdown    shutdownConfig
+	metrics     metricsConfig
+	tracing     tracing.Config
+	events      events.Config
 	rateLimiter ratelimiter.Config
+	blob        blob.Config
+	media       media.Config
+	timeline    timeline.Config
+	ranking     ranking.Config
+	trending    trending.Config
+	suggestions suggestions.Config
+
+	// proxies cuyos headers X-Forwarded-For y X-Real-IP se aceptan
+	trustedProxies realip.Trusted
 }
 
 type redisConfig struct {
@@ -47,6 +107,27 @@ type redisConfig struct {
 	enabled bool
 }
 
+type metricsConfig struct {
+	enabled bool
+	// credenciales propias de /metrics, distintas de las del healthcheck
+	basic basicAuthConfig
+}
+
+type shutdownConfig struct {
+	// tiempo maximo para drenar los requests en curso y detener las tareas en segundo plano
+	timeout time.Duration
+	// tiempo entre marcar la instancia como no lista y empezar a drenar, para que el load balancer deje de mandarle trafico
+	delay time.Duration
+}
+
+type cacheConfig struct {
+	// cantidad maxima de entradas del cache en memoria
+	maxEntries int
+	// con Redis, agrega un L1 en memoria delante de Redis
+	l1Enabled bool
+	l1TTL     time.Duration
+}
+
 type authConfig struct {
 	basic basicAuthConfig
 	token tokenAuthConfig
@@ -77,7 +158,7 @@ type dbConfig struct {
 	addr         string
 	maxOpenConns int
 	maxIdleConns int
-	maxLifetime  string
+	maxLifetime  time.Duration
 }
 
 /* Mount the routes for the API */
@@ -86,57 +167,157 @@ func (app *application) mount() *chi.Mux {
 
 	// use the middleware for the router
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(realip.Middleware(app.config.trustedProxies))
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware(app.logger))
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Logger)
+	r.Use(app.metrics.Middleware)
+	r.Use(app.AuditContextMiddleware)
 	r.Use(app.RateLimiterMiddleware)
 
-	r.Use(middleware.Timeout(60 * time.Second)) // middleware to timeout requests after 60 seconds
+	if app.config.metrics.enabled {
+		r.With(app.basicAuth(app.config.metrics.basic)).Get("/metrics", app.metrics.Handler().ServeHTTP)
+	}
+
+	// probes para el orquestador y el load balancer, sin auth
+	r.Get("/livez", app.livezHandler)
+	r.Get("/readyz", app.readyzHandler)
 
 	// route the API to the healthcheck handler
 	r.Route("/v1", func(r chi.Router) {
-		r.With(app.BasicAuthMiddleware()).Get("/healthcheck", app.healthcheckHandler)
-
-		docsURL := fmt.Sprintf("http://%s/v1/swagger/doc.json", app.config.apiURL)
-		r.Get("/swagger/*", httpSwagger.Handler(
-			httpSwagger.URL(docsURL), //The url pointing to API definition
-		))
+		// las conexiones del stream quedan abiertas, por eso van fuera del timeout
+		r.Route("/stream", func(r chi.Router) {
+			r.Use(app.streamTokenMiddleware)
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.streamSSEHandler)
+			r.Get("/ws", app.streamWebSocketHandler)
+		})
 
-		r.Route("/posts", func(r chi.Router) {
+		// los uploads de adjuntos tardan mas que el resto de los requests, tienen su propio timeout
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(uploadTimeout))
 			r.Use(app.AuthTokenMiddleware)
-			r.Post("/", app.createPostHandler)
+			r.With(app.postsContextMiddleware).Post("/posts/{id}/attachments", app.CheckPostOwnership("admin", app.uploadAttachmentsHandler))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(60 * time.Second)) // middleware to timeout requests after 60 seconds
+
+			r.With(app.BasicAuthMiddleware()).Get("/healthcheck", app.healthcheckHandler)
+
+			docsURL := fmt.Sprintf("http://%s/v1/swagger/doc.json", app.config.apiURL)
+			r.Get("/swagger/*", httpSwagger.Handler(
+				httpSwagger.URL(docsURL), //The url pointing to API definition
+			))
+
+			if app.config.blob.Backend == "local" {
+				r.Get("/media/*", app.mediaHandler)
+			}
+
+			r.Route("/posts", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Post("/", app.createPostHandler)
+				r.Get("/drafts", app.getDraftsHandler)
+
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(app.postsContextMiddleware)
+					r.Get("/", app.getPostHandler)
+					r.Delete("/", app.CheckPostOwnership("admin", app.deletePostHandler))
+					r.Patch("/", app.CheckPostOwnership("moderator", app.updatePostHandler))
 
-			r.Route("/{id}", func(r chi.Router) {
-				r.Use(app.postsContextMiddleware)
-				r.Get("/", app.getPostHandler)
-				r.Delete("/", app.CheckPostOwnership("admin", app.deletePostHandler))
-				r.Patch("/", app.CheckPostOwnership("moderator", app.updatePostHandler))
+					r.Put("/repost", app.repostHandler)
+					r.Delete("/repost", app.undoRepostHandler)
+					r.Put("/reactions", app.reactToPostHandler)
+					r.Delete("/reactions", app.removeReactionHandler)
 
+					r.Route("/revisions", func(r chi.Router) {
+						r.Get("/", app.getPostRevisionsHandler)
+						r.Get("/diff", app.getPostRevisionDiffHandler)
+						r.Post("/{revision}/revert", app.CheckPostOwnership("moderator", app.revertPostRevisionHandler))
+					})
+
+					r.Route("/attachments", func(r chi.Router) {
+						r.Put("/order", app.CheckPostOwnership("admin", app.reorderAttachmentsHandler))
+						r.Delete("/{attachmentID}", app.CheckPostOwnership("admin", app.deleteAttachmentHandler))
+					})
+
+				})
 			})
-		})
-		r.Route("/comments", func(r chi.Router) {
-			r.Post("/", app.createCommentHandler)
-		})
-		r.Route("/users", func(r chi.Router) {
-			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Route("/notifications", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Get("/", app.getNotificationsHandler)
+				r.Get("/unread-count", app.getUnreadNotificationsCountHandler)
+				r.Post("/read-all", app.markAllNotificationsReadHandler)
+				r.Patch("/{notificationID}/read", app.markNotificationReadHandler)
+				r.Get("/preferences", app.getNotificationPreferencesHandler)
+				r.Put("/preferences", app.updateNotificationPreferencesHandler)
+			})
+			r.Route("/conversations", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Post("/", app.createConversationHandler)
+				r.Get("/", app.getConversationsHandler)
+				r.Get("/unread-count", app.getUnreadMessagesCountHandler)
+				r.Get("/settings", app.getDMSettingsHandler)
+				r.Put("/settings", app.updateDMSettingsHandler)
 
-			r.Route("/{id}", func(r chi.Router) {
+				r.Route("/{conversationID}", func(r chi.Router) {
+					r.Use(app.conversationsContextMiddleware)
+					r.Get("/", app.getConversationHandler)
+					r.Get("/messages", app.getMessagesHandler)
+					r.Post("/messages", app.sendMessageHandler)
+					r.Post("/read", app.markConversationReadHandler)
+				})
+			})
+			r.Route("/explore", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Get("/trending", app.getTrendingHandler)
+			})
+			r.Route("/tags", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Get("/{tag}/posts", app.getTagPostsHandler)
+			})
+			r.Route("/comments", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
+				r.Post("/", app.createCommentHandler)
+				r.Get("/{commentID}", app.getCommentHandler)
+				r.Patch("/{commentID}", app.updateCommentHandler)
+			})
+			r.Route("/users", func(r chi.Router) {
+				r.Put("/activate/{token}", app.activateUserHandler)
+
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
 
-				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+					r.Get("/", app.getUserHandler)
+					r.Patch("/", app.updateUserProfileHandler)
+					r.Put("/follow", app.followUserHandler)
+					r.Put("/unfollow", app.unfollowUserHandler)
+					r.Put("/block", app.blockUserHandler)
+					r.Delete("/block", app.unblockUserHandler)
+					r.With(app.RequireRoleMiddleware("moderator")).Put("/suspension", app.updateUserSuspensionHandler)
+					r.With(app.RequireRoleMiddleware("admin")).Patch("/role", app.updateUserRoleHandler)
 
+				})
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Get("/feed", app.getFeedHandler)
+					r.Get("/suggestions", app.getSuggestionsHandler)
+					r.Delete("/suggestions/{suggestedID}", app.dismissSuggestionHandler)
+					r.Put("/password", app.updatePasswordHandler)
+				})
 			})
-			r.Group(func(r chi.Router) {
+
+			r.Route("/audit", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
-				r.Get("/feed", app.getFeedHandler)
+				r.Use(app.RequireRoleMiddleware("admin"))
+				r.Get("/", app.getAuditEventsHandler)
+				r.Get("/export", app.exportAuditEventsHandler)
 			})
-		})
 
-		r.Route("/authentication", func(r chi.Router) {
-			r.Post("/user", app.registerUserHandler)
-			r.Post("/token", app.createTokenHandler)
+			r.Route("/authentication", func(r chi.Router) {
+				r.Post("/user", app.registerUserHandler)
+				r.Post("/token", app.createTokenHandler)
+			})
 		})
 	})
 	return r
@@ -157,8 +338,35 @@ func (app *application) serve(mux *chi.Mux) error {
 		IdleTimeout:  time.Minute,
 	}
 
+	// Shutdown no espera a las conexiones del stream (quedan abiertas), se cierran aparte
+	srv.RegisterOnShutdown(app.stopStreams)
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		// primero deja de estar lista para que el load balancer no le mande requests nuevos
+		app.logger.Infow("Shutting down server", "signal", s.String(), "delay", app.config.shutdown.delay)
+		app.ready.Store(false)
+		time.Sleep(app.config.shutdown.delay)
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdown.timeout)
+		defer cancel()
+		shutdownErr <- srv.Shutdown(ctx)
+	}()
+
 	app.logger.Infow("Starting server", "addr", app.config.addr, "env", app.config.env)
+	app.ready.Store(true)
 
-	return srv.ListenAndServe()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	if err := <-shutdownErr; err != nil {
+		return err
+	}
 
+	app.logger.Infow("Server drained", "addr", app.config.addr)
+	return nil
 }
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/marceterrone10/social/internal/blob"
+	"github.com/marceterrone10/social/internal/media"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/store/cache"
+)
+
+const (
+	maxAttachmentsPerPost = 10
+	maxAltTextLength      = 1000
+	// limite del body completo del upload; readJSON (1MB) no aplica a estas rutas
+	maxUploadRequestBytes = maxAttachmentsPerPost*media.MaxVideoBytes + 1<<20
+	multipartMemory       = 32 << 20
+	// tiempo para recibir y procesar un upload completo, bastante mas que el ReadTimeout del server
+	uploadTimeout = 5 * time.Minute
+)
+
+var errFileTooLarge = errors.New("file exceeds the maximum allowed size")
+
+// UploadAttachments godoc
+//
+//	@Summary		Upload media attachments to a post
+//	@Description	Upload images (JPEG, PNG, GIF, WebP up to 10MB) or short videos (MP4, WebM up to 50MB) as multipart form data. Metadata such as EXIF is stripped.
+//	@Tags			Posts
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			files		formData	file	true	"Files to upload (repeat the field for several files)"
+//	@Param			alt_text	formData	string	false	"Alt text, one per file in the same order"
+//	@Success		201			{array}		store.Attachment
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		413			{object}	error
+//	@Failure		415			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/attachments [post]
+func (app *application) uploadAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r.Context())
+	ctx := r.Context()
+
+	// el body puede ser de cientos de MB: el ReadTimeout y WriteTimeout del server no alcanzan para subirlo
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Now().Add(uploadTimeout)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := rc.SetWriteDeadline(time.Now().Add(uploadTimeout)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadRequestBytes)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			app.payloadTooLargeError(w, r, err)
+			return
+		}
+		app.badRequestError(w, r, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["files"]
+	if len(files) == 0 {
+		app.badRequestError(w, r, fmt.Errorf("at least one file is required in the 'files' field"))
+		return
+	}
+
+	altTexts := r.MultipartForm.Value["alt_text"]
+	if len(altTexts) > len(files) {
+		app.badRequestError(w, r, fmt.Errorf("got %d alt texts for %d files", len(altTexts), len(files)))
+		return
+	}
+
+	existing, err := app.store.Attachments.GetByPostId(ctx, post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if len(existing)+len(files) > maxAttachmentsPerPost {
+		app.badRequestError(w, r, fmt.Errorf("a post can have at most %d attachments", maxAttachmentsPerPost))
+		return
+	}
+
+	attachments := make([]*store.Attachment, 0, len(files))
+
+	// si algo falla borramos lo que ya se subio para no dejar blobs huerfanos
+	cleanup := func() {
+		for _, a := range attachments {
+			if err := app.blobStorage.Delete(context.Background(), a.StorageKey); err != nil {
+				app.logger.Errorw("error deleting orphan blob", "key", a.StorageKey, "error", err)
+			}
+		}
+	}
+
+	for i, fh := range files {
+		altText := ""
+		if i < len(altTexts) {
+			altText = altTexts[i]
+		}
+		if len(altText) > maxAltTextLength {
+			cleanup()
+			app.badRequestError(w, r, fmt.Errorf("alt text must be at most %d characters", maxAltTextLength))
+			return
+		}
+
+		attachment, err := app.storeAttachment(ctx, post.ID, fh, altText)
+		if err != nil {
+			cleanup()
+			switch {
+			case errors.Is(err, media.ErrMalformed):
+				app.badRequestError(w, r, fmt.Errorf("%s: %w", fh.Filename, err))
+			case errors.Is(err, media.ErrUnsupportedType):
+				app.unsupportedMediaTypeError(w, r, fmt.Errorf("%s: %w", fh.Filename, err))
+			case errors.Is(err, errFileTooLarge), errors.Is(err, media.ErrTooManyPixels):
+				app.payloadTooLargeError(w, r, fmt.Errorf("%s: %w", fh.Filename, err))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	if err := app.store.Attachments.Create(ctx, post.ID, attachments); err != nil {
+		cleanup()
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.mediaWorker.Notify()
+	app.cacheInvalidator.Invalidate(ctx, cache.KindPost, post.ID)
+
+	if err := app.writeResponse(w, http.StatusCreated, attachments); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// storeAttachment valida el archivo por su contenido, le quita los metadatos y lo sube al blob storage
+func (app *application) storeAttachment(ctx context.Context, postID int64, fh *multipart.FileHeader, altText string) (*store.Attachment, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, media.MaxVideoBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := media.Inspect(data, app.config.media.MaxImagePixels)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > info.MaxBytes() {
+		return nil, errFileTooLarge
+	}
+
+	data, err = media.StripMetadata(info.MimeType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("posts/%d/%s%s", postID, uuid.New().String(), info.Ext)
+	if err := app.blobStorage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), info.MimeType); err != nil {
+		return nil, err
+	}
+
+	// solo las imagenes que el pipeline sabe decodificar pasan por el worker de variants
+	status := store.AttachmentStatusSkipped
+	if media.CanProcess(info.MimeType) {
+		status = store.AttachmentStatusPending
+	}
+
+	return &store.Attachment{
+		Status:     status,
+		Kind:       info.Kind,
+		MimeType:   info.MimeType,
+		StorageKey: key,
+		URL:        app.blobStorage.URL(key),
+		SizeBytes:  int64(len(data)),
+		Width:      info.Width,
+		Height:     info.Height,
+		AltText:    altText,
+	}, nil
+}
+
+// DeleteAttachment godoc
+//
+//	@Summary		Delete an attachment from a post
+//	@Description	Delete an attachment from a post and its stored file
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			id				path	int	true	"Post ID"
+//	@Param			attachmentID	path	int	true	"Attachment ID"
+//	@Success		204				"Attachment deleted"
+//	@Failure		400				{object}	error
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/attachments/{attachmentID} [delete]
+func (app *application) deleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r.Context())
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	attachment, err := app.store.Attachments.Delete(ctx, post.ID, attachmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.deleteBlobs(*attachment)
+	app.cacheInvalidator.Invalidate(ctx, cache.KindPost, post.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ReorderAttachmentsPayload struct {
+	IDs []int64 `json:"ids" validate:"required,unique,dive,min=1"`
+}
+
+// ReorderAttachments godoc
+//
+//	@Summary		Reorder the attachments of a post
+//	@Description	Set the order of the attachments of a post. Every attachment id must appear exactly once.
+//	@Tags			Posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int							true	"Post ID"
+//	@Param			payload	body		ReorderAttachmentsPayload	true	"Attachment ids in the new order"
+//	@Success		200		{array}		store.Attachment
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/attachments/order [put]
+func (app *application) reorderAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r.Context())
+
+	var payload ReorderAttachmentsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Attachments.Reorder(ctx, post.ID, payload.IDs); err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidAttachmentOrder):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.cacheInvalidator.Invalidate(ctx, cache.KindPost, post.ID)
+
+	attachments, err := app.store.Attachments.GetByPostId(ctx, post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, attachments); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// deleteBlobs borra los archivos de los adjuntos y sus variants. Es best-effort: la fila ya no existe en la DB.
+func (app *application) deleteBlobs(attachments ...store.Attachment) {
+	for _, a := range attachments {
+		keys := []string{a.StorageKey}
+		for _, v := range a.Variants {
+			keys = append(keys, v.StorageKey)
+		}
+
+		for _, key := range keys {
+			if err := app.blobStorage.Delete(context.Background(), key); err != nil {
+				app.logger.Errorw("error deleting blob", "key", key, "error", err)
+			}
+		}
+	}
+}
+
+// mediaHandler sirve los archivos del backend local. Con S3 los clientes van directo al bucket.
+func (app *application) mediaHandler(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+
+	f, err := app.blobStorage.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	// son archivos subidos por usuarios: el navegador no tiene que adivinar el tipo
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, key, time.Time{}, rs)
+		return
+	}
+
+	if _, err := io.Copy(w, f); err != nil {
+		app.logger.Warnw("error serving media", "key", key, "error", err)
+	}
+}
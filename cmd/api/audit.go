@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/marceterrone10/social/internal/store"
+)
+
+// GetAuditEvents godoc
+//
+//	@Summary		List audit events
+//	@Description	List audit events filtered by actor, action, target and time range (admin only)
+//	@Tags			Audit
+//	@Produce		json
+//	@Param			actor_id	query		int		false	"Actor user ID"
+//	@Param			action		query		string	false	"Action, e.g. post.update"
+//	@Param			target_type	query		string	false	"Target type, e.g. post"
+//	@Param			target_id	query		int		false	"Target ID"
+//	@Param			from		query		string	false	"RFC3339 lower bound (inclusive)"
+//	@Param			to			query		string	false	"RFC3339 upper bound (exclusive)"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			offset		query		int		false	"Offset"
+//	@Success		200			{array}		store.AuditEvent
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/audit [get]
+func (app *application) getAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, ok := app.parseAuditFilter(w, r, 50)
+	if !ok {
+		return
+	}
+
+	events, err := app.store.Audit.List(r.Context(), filter)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, events); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// ExportAuditEvents godoc
+//
+//	@Summary		Export audit events
+//	@Description	Export audit events as CSV or NDJSON using the same filters as the list endpoint (admin only)
+//	@Tags			Audit
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Param			format	query		string	false	"csv (default) or ndjson"
+//	@Success		200		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/audit/export [get]
+func (app *application) exportAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, ok := app.parseAuditFilter(w, r, auditExportMaxRows)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ndjson" {
+		app.badRequestError(w, r, fmt.Errorf("unsupported export format %q", format))
+		return
+	}
+
+	events, err := app.store.Audit.List(r.Context(), filter)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	switch format {
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, e := range events {
+			if err := enc.Encode(e); err != nil {
+				app.logger.Errorw("error exporting audit events", "error", err)
+				return
+			}
+		}
+	default:
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "actor_id", "action", "target_type", "target_id", "diff", "ip", "request_id", "created_at"})
+		for _, e := range events {
+			actorID := ""
+			if e.ActorID != nil {
+				actorID = strconv.FormatInt(*e.ActorID, 10)
+			}
+			cw.Write([]string{
+				strconv.FormatInt(e.ID, 10),
+				actorID,
+				e.Action,
+				e.TargetType,
+				strconv.FormatInt(e.TargetID, 10),
+				string(e.Diff),
+				e.IP,
+				e.RequestID,
+				e.CreatedAt,
+			})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			app.logger.Errorw("error exporting audit events", "error", err)
+		}
+	}
+}
+
+// maximo de filas que se exportan en un solo request
+const auditExportMaxRows = 10000
+
+func (app *application) parseAuditFilter(w http.ResponseWriter, r *http.Request, limit int) (store.AuditFilter, bool) {
+	filter := store.AuditFilter{ // default values
+		Limit:  limit,
+		Offset: 0,
+	}
+
+	filter, err := filter.ParseURLParams(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return filter, false
+	}
+
+	if err := Validate.Struct(filter); err != nil {
+		app.badRequestError(w, r, err)
+		return filter, false
+	}
+
+	return filter, true
+}
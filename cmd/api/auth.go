@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -13,6 +14,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/marceterrone10/social/internal/mailer"
 	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/store/cache"
 )
 
 type RegisterUserPayload struct {
@@ -87,8 +89,12 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		default:
 			app.internalServerError(w, r, err)
 		}
+		return
 	}
 
+	// el id pudo haber quedado cacheado como inexistente antes de crearse el usuario
+	app.cacheInvalidator.Invalidate(ctx, cache.KindUser, user.ID)
+
 	userWithToken := UserWithToken{
 		User:  user,
 		Token: plainToken,
@@ -105,8 +111,8 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		ActivationURL: activationURL,
 	}
 
-	// enviar email
-	err = app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+	// enviar email. Si el cliente corta la conexion el envio sigue, para no borrar al usuario por eso
+	err = app.mailer.Send(context.WithoutCancel(ctx), mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
 	if err != nil {
 		app.logger.Errorw("error sending email", "error", err)
 
@@ -142,7 +148,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 //	@Router			/users/activate/{token} [put]
 func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 	token := chi.URLParam(r, "token")
-	err := app.store.Users.ActivateUser(r.Context(), token)
+	userID, err := app.store.Users.ActivateUser(r.Context(), token)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
@@ -153,6 +159,9 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// la activacion sube la version del usuario
+	app.cacheInvalidator.Invalidate(r.Context(), cache.KindUser, userID)
+
 	if err := app.writeResponse(w, http.StatusNoContent, "User activated"); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -169,6 +178,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 //	@Param			payload	body		CreateUserTokenPayload	true	"Create token payload"
 //	@Success		200		{string}	string					"Token created successfully"
 //	@Failure		400		{string}	error					"Bad request"
+//	@Failure		403		{string}	error					"Account suspended"
 //	@Failure		500		{string}	error					"Internal server error"
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -196,10 +206,17 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := user.Password.Compare(payload.Password); err != nil {
+		app.recordLogin(r, user.ID, store.AuditActionLoginFailed)
 		app.unauthorizedError(w, r, err)
 		return
 	}
 
+	if user.SuspendedAt != nil {
+		app.recordLogin(r, user.ID, store.AuditActionLoginFailed)
+		app.forbiddenError(w, r, errAccountSuspended)
+		return
+	}
+
 	// generar el token -> jwt
 	claims := jwt.MapClaims{
 		"sub": user.ID,
@@ -215,9 +232,24 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	app.recordLogin(r, user.ID, store.AuditActionLogin)
+
 	// enviarlo al cliente
 	if err := app.writeResponse(w, http.StatusCreated, token); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 }
+
+// recordLogin registra el intento de login en la auditoria. Un error aca no debe impedir el login.
+func (app *application) recordLogin(r *http.Request, userID int64, action string) {
+	event := &store.AuditEvent{
+		ActorID:    &userID,
+		Action:     action,
+		TargetType: store.AuditTargetUser,
+		TargetID:   userID,
+	}
+	if err := app.store.Audit.Create(r.Context(), event); err != nil {
+		app.logger.Errorw("error recording login audit event", "user_id", userID, "error", err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// backgroundWorkers corre las tareas en segundo plano agrupadas en etapas. Al apagar el server las
+// etapas se detienen en el orden en que se crearon y cada una espera a que sus tareas terminen antes
+// de pasar a la siguiente: primero las que generan trabajo, despues las que lo consumen.
+type backgroundWorkers struct {
+	logger *zap.SugaredLogger
+	stages []*workerStage
+}
+
+type workerStage struct {
+	name   string
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newBackgroundWorkers(logger *zap.SugaredLogger) *backgroundWorkers {
+	return &backgroundWorkers{logger: logger}
+}
+
+// Stage agrega una etapa, que se detiene despues de las anteriores
+func (b *backgroundWorkers) Stage(name string) *workerStage {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &workerStage{name: name, ctx: ctx, cancel: cancel}
+	b.stages = append(b.stages, s)
+	return s
+}
+
+// Go corre la tarea hasta que se detenga la etapa
+func (s *workerStage) Go(run func(context.Context)) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		run(s.ctx)
+	}()
+}
+
+// Stop detiene las etapas en orden. Si ctx vence antes, deja de esperar y cancela las que quedan.
+func (b *backgroundWorkers) Stop(ctx context.Context) {
+	for i, s := range b.stages {
+		s.cancel()
+
+		done := make(chan struct{})
+		go func() {
+			s.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			b.logger.Infow("background workers stopped", "stage", s.name)
+		case <-ctx.Done():
+			b.logger.Warnw("timed out stopping background workers", "stage", s.name)
+			for _, rest := range b.stages[i+1:] {
+				rest.cancel()
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/marceterrone10/social/internal/events"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/store/cache"
+)
+
+// BlockUser godoc
+//
+//	@Summary		Block a user
+//	@Description	Hide the user's content from explore for the current user, and the current user's content for them. Blocked users cannot start direct conversations, send direct messages or comment on each other's posts, and get no notifications from each other.
+//	@Tags			Users
+//	@Param			id	path	int	true	"User ID"
+//	@Success		204	"User blocked"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	blockedID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || blockedID < 1 {
+		app.badRequestError(w, r, fmt.Errorf("invalid user id"))
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromCtx(ctx)
+
+	if blockedID == user.ID {
+		app.badRequestError(w, r, fmt.Errorf("you cannot block yourself"))
+		return
+	}
+
+	if err := app.store.Blocks.Block(ctx, user.ID, blockedID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblock a user
+//	@Tags			Users
+//	@Param			id	path	int	true	"User ID"
+//	@Success		204	"User unblocked"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/block [delete]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	blockedID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || blockedID < 1 {
+		app.badRequestError(w, r, fmt.Errorf("invalid user id"))
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Blocks.Unblock(ctx, getUserFromCtx(ctx).ID, blockedID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type UpdateSuspensionPayload struct {
+	Suspended *bool `json:"suspended" validate:"required"`
+}
+
+// UpdateUserSuspension godoc
+//
+//	@Summary		Suspend or reinstate a user
+//	@Description	Suspended users cannot log in or use their tokens, cannot be messaged, and their content is excluded from explore (moderators only). Moderators cannot change their own suspension or that of users with an equal or higher role. The change is recorded in the audit log.
+//	@Tags			Users
+//	@Accept			json
+//	@Param			id		path	int						true	"User ID"
+//	@Param			payload	body	UpdateSuspensionPayload	true	"Suspension payload"
+//	@Success		204		"Suspension updated"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/suspension [put]
+func (app *application) updateUserSuspensionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || userID < 1 {
+		app.badRequestError(w, r, fmt.Errorf("invalid user id"))
+		return
+	}
+
+	var payload UpdateSuspensionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	// un moderador solo puede suspender a usuarios de rol menor, nunca a si mismo
+	caller := getUserFromCtx(r.Context())
+	if caller.ID == userID {
+		app.forbiddenError(w, r, errors.New("you cannot change your own suspension"))
+		return
+	}
+	target, err := app.store.Users.GetById(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if target.Role.Level >= caller.Role.Level {
+		app.forbiddenError(w, r, errors.New("you cannot change the suspension of a user with an equal or higher role"))
+		return
+	}
+
+	if err := app.store.Users.SetSuspended(r.Context(), userID, *payload.Suspended); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// SetSuspended sube la version y el usuario cacheado tiene que ver la suspension enseguida
+	app.cacheInvalidator.Invalidate(r.Context(), cache.KindUser, userID)
+
+	if *payload.Suspended {
+		// trending descuenta lo que sumaron sus posts
+		app.events.Publish(r.Context(), events.Event{Type: events.TypeUserSuspended, ActorID: caller.ID, UserID: userID})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
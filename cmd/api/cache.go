@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/marceterrone10/social/internal/store"
+)
+
+// sharedLoadTimeout limita las cargas compartidas, que no terminan con el request que las empezo
+const sharedLoadTimeout = 5 * time.Second
+
+// loadShared ejecuta load una sola vez para los requests concurrentes con la misma clave. load recibe un
+// contexto que no se cancela con el request que empezo la carga (conserva el logger y la traza): si ese
+// cliente corta la conexion, el resto no recibe un context canceled.
+func (app *application) loadShared(ctx context.Context, key string, load func(ctx context.Context) (any, error)) (any, error) {
+	v, err, _ := app.cacheGroup.Do(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedLoadTimeout)
+		defer cancel()
+		return load(ctx)
+	})
+	return v, err
+}
+
+// getPostFromCache devuelve el post con sus comentarios, adjuntos y quote. Si no esta en cache lo
+// arma desde la DB; los requests concurrentes por el mismo post comparten una sola carga.
+func (app *application) getPostFromCache(ctx context.Context, postID int64) (*store.Post, error) {
+	post, err := app.cacheStorage.Posts.Get(ctx, postID)
+	if err != nil {
+		// si el cache falla se lee de la DB
+		app.logger.Warnw("error reading cached post", "post_id", postID, "error", err)
+	}
+	if post != nil {
+		return post, nil
+	}
+
+	v, err := app.loadShared(ctx, fmt.Sprintf("post-%d", postID), func(ctx context.Context) (any, error) {
+		post, err := app.loadPost(ctx, postID)
+		if err != nil {
+			return nil, err
+		}
+		if err := app.cacheStorage.Posts.Set(ctx, post); err != nil {
+			app.logger.Warnw("error caching post", "post_id", postID, "error", err)
+		}
+		return post, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*store.Post), nil
+}
+
+func (app *application) loadPost(ctx context.Context, postID int64) (*store.Post, error) {
+	post, err := app.store.Posts.GetById(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := app.store.Comments.GetByPostId(ctx, post.ID)
+	if err != nil {
+		return nil, err
+	}
+	post.Comments = *comments
+
+	if post.Attachments, err = app.store.Attachments.GetByPostId(ctx, post.ID); err != nil {
+		return nil, err
+	}
+
+	if err := app.attachQuotes(ctx, []*store.Post{post}); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// getFeedFromCache devuelve la primera pagina del feed cronologico del usuario, del cache si esta
+func (app *application) getFeedFromCache(ctx context.Context, userID int64, fq store.PaginatedQuery) ([]*store.PostWithMetadata, error) {
+	posts, err := app.cacheStorage.Feeds.Get(ctx, userID)
+	if err != nil {
+		app.logger.Warnw("error reading cached feed", "user_id", userID, "error", err)
+	}
+	if posts != nil {
+		return posts, nil
+	}
+
+	v, err := app.loadShared(ctx, fmt.Sprintf("feed-%d", userID), func(ctx context.Context) (any, error) {
+		posts, err := app.loadFeed(ctx, userID, fq)
+		if err != nil {
+			return nil, err
+		}
+		if err := app.cacheStorage.Feeds.Set(ctx, userID, posts); err != nil {
+			app.logger.Warnw("error caching feed", "user_id", userID, "error", err)
+		}
+		return posts, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]*store.PostWithMetadata), nil
+}
+
+func (app *application) loadFeed(ctx context.Context, userID int64, fq store.PaginatedQuery) ([]*store.PostWithMetadata, error) {
+	posts, err := app.timeline.Feed(ctx, userID, fq)
+	if err != nil {
+		return nil, err
+	}
+
+	// los adjuntos traen las URLs de los variants para que el cliente elija segun la densidad de pantalla
+	if err := app.attachToFeed(ctx, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/marceterrone10/social/internal/events"
 	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/store/cache"
 )
 
+var errCommentBlocked = errors.New("you cannot comment on this post")
+
 type CreateCommentPayload struct {
 	Content string `json:"content" validate:"required,max=1000"`
 	PostID  int64  `json:"post_id" validate:"required,min=1"`
-	UserID  int64  `json:"user_id" validate:"required,min=1"`
 }
 
 func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
@@ -24,22 +31,211 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	ctx := r.Context()
+	user := getUserFromCtx(ctx)
+
+	// el autor es siempre el usuario autenticado
 	comment := &store.Comment{
 		Content: payload.Content,
 		PostID:  payload.PostID,
-		UserID:  payload.UserID,
+		UserID:  user.ID,
 	}
 
-	ctx := r.Context()
+	post, err := app.store.Posts.GetById(ctx, payload.PostID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// no se puede comentar un draft o un post programado de otro usuario
+	if !post.IsVisibleTo(user.ID) {
+		app.notFoundError(w, r, store.ErrNotFound)
+		return
+	}
+
+	// si el autor del post bloqueo al usuario, o al reves, no se puede comentar
+	if post.UserID != user.ID {
+		blocked, err := app.store.Blocks.IsBlocked(ctx, user.ID, post.UserID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if blocked {
+			app.forbiddenError(w, r, errCommentBlocked)
+			return
+		}
+	}
 
 	if err := app.store.Comments.Create(ctx, comment); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	app.events.Publish(ctx, events.Event{
+		Type:      events.TypeComment,
+		ActorID:   comment.UserID,
+		UserID:    post.UserID,
+		PostID:    post.ID,
+		CommentID: comment.ID,
+	})
+	app.events.Publish(ctx, events.Mentions(comment.UserID, comment.PostID, comment.ID, comment.MentionedUserIDs)...)
+
 	if err := app.writeResponse(w, http.StatusCreated, comment); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
 }
+
+// GetComment godoc
+//
+//	@Summary		Get a comment by ID
+//	@Description	Get a comment by ID. The ETag header carries the version needed to update it.
+//	@Tags			Comments
+//	@Produce		json
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/{commentID} [get]
+func (app *application) getCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	setETag(w, comment.Version)
+	if err := app.writeResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Update a comment
+//	@Description	Update the content of a comment. Allowed for the author and moderators. The If-Match header must carry the comment ETag.
+//	@Tags			Comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			commentID	path		int						true	"Comment ID"
+//	@Param			If-Match	header		string					true	"ETag of the version being edited"
+//	@Param			payload		body		UpdateCommentPayload	true	"Comment payload"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	user := getUserFromCtx(ctx)
+	if comment.UserID != user.ID {
+		allowed, err := app.checkRole(ctx, user, "moderator")
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	version, ok := app.checkIfMatch(w, r, comment.Version)
+	if !ok {
+		return
+	}
+
+	var payload UpdateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	comment.Content = payload.Content
+	comment.Version = version
+
+	if err := app.store.Comments.Update(ctx, comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrVersionConflict):
+			app.preconditionFailedError(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.cacheInvalidator.Invalidate(ctx, cache.KindPost, comment.PostID)
+	app.events.Publish(ctx, events.Mentions(comment.UserID, comment.PostID, comment.ID, comment.MentionedUserIDs)...)
+
+	setETag(w, comment.Version)
+	if err := app.writeResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) loadComment(w http.ResponseWriter, r *http.Request) (*store.Comment, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil || id < 1 {
+		app.badRequestError(w, r, fmt.Errorf("invalid comment id"))
+		return nil, false
+	}
+
+	comment, err := app.store.Comments.GetById(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	// los comentarios de drafts y posts programados no existen para los demas usuarios
+	post, err := app.store.Posts.GetById(r.Context(), comment.PostID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+	if user := getUserFromCtx(r.Context()); user == nil || !post.IsVisibleTo(user.ID) {
+		app.notFoundError(w, r, store.ErrNotFound)
+		return nil, false
+	}
+	return comment, true
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	conf "github.com/marceterrone10/social/internal/config"
+)
+
+// defaultConfig carga la configuracion por defecto, sin archivo ni variables de entorno que la cambien
+func defaultConfig(t *testing.T) config {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("ENV", "development")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("default config is invalid: %v", err)
+	}
+	return cfg
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *config)
+		wantErr []string
+	}{
+		{name: "development defaults", modify: func(cfg *config) {}},
+		{
+			name: "production with secrets",
+			modify: func(cfg *config) {
+				cfg.env = "production"
+				cfg.auth.token.secret = "s3cret"
+				cfg.auth.basic.password = "a-strong-password"
+			},
+		},
+		{
+			name: "production without token secret",
+			modify: func(cfg *config) {
+				cfg.env = "production"
+				cfg.auth.token.secret = ""
+				cfg.auth.basic.password = "a-strong-password"
+			},
+			wantErr: []string{"TOKEN_SECRET"},
+		},
+		{
+			name: "production with empty basic auth password",
+			modify: func(cfg *config) {
+				cfg.env = "production"
+				cfg.auth.token.secret = "s3cret"
+				cfg.auth.basic.password = ""
+			},
+			wantErr: []string{"BASIC_AUTH_PASSWORD"},
+		},
+		{
+			name: "production with default basic auth password",
+			modify: func(cfg *config) {
+				cfg.env = "production"
+				cfg.auth.token.secret = "s3cret"
+				cfg.auth.basic.password = defaultBasicAuthPassword
+			},
+			wantErr: []string{"BASIC_AUTH_PASSWORD"},
+		},
+		{
+			name: "development allows empty secrets",
+			modify: func(cfg *config) {
+				cfg.auth.token.secret = ""
+				cfg.auth.basic.password = ""
+			},
+		},
+		{
+			name: "trending and suggestions bounds",
+			modify: func(cfg *config) {
+				cfg.trending.Limit = maxTrendingLimit + 1
+				cfg.trending.AggregateInterval = 0
+				cfg.suggestions.MaxAge = cfg.suggestions.StaleAfter - 1
+				cfg.suggestions.PoolSize = 0
+			},
+			wantErr: []string{"TRENDING_LIMIT", "TRENDING_AGGREGATE_INTERVAL", "SUGGESTIONS_MAX_AGE", "SUGGESTIONS_POOL_SIZE"},
+		},
+		{
+			name: "timeline backfill larger than the timeline",
+			modify: func(cfg *config) {
+				cfg.timeline.BackfillSize = cfg.timeline.MaxSize + 1
+			},
+			wantErr: []string{"TIMELINE_BACKFILL_SIZE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig(t)
+			tt.modify(&cfg)
+
+			l, err := conf.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			validateConfig(l, cfg)
+			err = l.Err()
+
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors for %s", strings.Join(tt.wantErr, ", "))
+			}
+			for _, key := range tt.wantErr {
+				if !strings.Contains(err.Error(), key) {
+					t.Errorf("missing error for %s in %v", key, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/stream"
+)
+
+type conversationKey string
+
+const conversationCtx conversationKey = "conversation" // clave para el contexto de la conversacion
+
+const (
+	conversationsDefaultLimit = 20
+	conversationsMaxLimit     = 50
+	messagesDefaultLimit      = 50
+	messagesMaxLimit          = 100
+)
+
+type CreateConversationPayload struct {
+	// destinatarios, sin contar al usuario actual. Con mas de uno se crea un grupo.
+	ParticipantIDs []int64 `json:"participant_ids" validate:"required,min=1,dive,min=1"`
+	Title          *string `json:"title" validate:"omitempty,max=100"`
+}
+
+type SendMessagePayload struct {
+	Content string `json:"content" validate:"required,max=2000"`
+}
+
+type MarkConversationReadPayload struct {
+	// ultimo mensaje leido, si se omite se marca hasta el ultimo
+	MessageID int64 `json:"message_id" validate:"min=0"`
+}
+
+type DMSettingsPayload struct {
+	AllowMessagesFrom string `json:"allow_messages_from" validate:"required,oneof=mutuals everyone"`
+}
+
+type ConversationsPage struct {
+	Conversations []*store.Conversation `json:"conversations"`
+	// vacio cuando no hay mas paginas
+	NextCursor string `json:"next_cursor"`
+}
+
+// CreateConversation godoc
+//
+//	@Summary		Start a conversation
+//	@Description	Start a 1:1 conversation (one participant) or a group (up to 9 participants besides the current user). A 1:1 conversation that already exists is returned as is.
+//	@Description	Every participant must be a mutual follower of the current user, unless their settings allow messages from everyone. Users who blocked (or were blocked by) the current user cannot be added, and suspended users are not found.
+//	@Tags			Conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateConversationPayload	true	"Conversation payload"
+//	@Success		201		{object}	store.Conversation
+//	@Success		200		{object}	store.Conversation	"Existing 1:1 conversation"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations [post]
+func (app *application) createConversationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateConversationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromCtx(ctx)
+
+	recipients := []int64{}
+	for _, id := range payload.ParticipantIDs {
+		if id != user.ID && !slices.Contains(recipients, id) {
+			recipients = append(recipients, id)
+		}
+	}
+	if len(recipients) == 0 {
+		app.badRequestError(w, r, fmt.Errorf("a conversation needs at least one other participant"))
+		return
+	}
+	if len(recipients)+1 > store.MaxConversationParticipants {
+		app.badRequestError(w, r, fmt.Errorf("a conversation can have at most %d participants", store.MaxConversationParticipants))
+		return
+	}
+
+	conversation := &store.Conversation{
+		IsGroup:   len(recipients) > 1,
+		CreatedBy: &user.ID,
+	}
+	if conversation.IsGroup {
+		conversation.Title = payload.Title
+	}
+
+	created, err := app.store.Conversations.Create(ctx, conversation, recipients)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrDMNotAllowed), errors.Is(err, store.ErrDMBlocked):
+			app.forbiddenError(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, fmt.Errorf("participant not found"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	conversation, err = app.store.Conversations.GetForUser(ctx, conversation.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	if err := app.writeResponse(w, status, conversation); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetConversations godoc
+//
+//	@Summary		List conversations
+//	@Description	List the current user's conversations with their last message and unread count, most recent activity first. Pass next_cursor as cursor to get the next page.
+//	@Tags			Conversations
+//	@Produce		json
+//	@Param			cursor	query		string	false	"Cursor returned by the previous page"
+//	@Param			limit	query		int		false	"Page size (max 50)"
+//	@Success		200		{object}	ConversationsPage
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations [get]
+func (app *application) getConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	qs := r.URL.Query()
+
+	limit, err := parseLimit(qs.Get("limit"), conversationsDefaultLimit, conversationsMaxLimit)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var cursor *store.Cursor
+	if c := qs.Get("cursor"); c != "" {
+		cursor, err = store.ParseCursor(c)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	conversations, err := app.store.Conversations.List(ctx, getUserFromCtx(ctx).ID, cursor, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	page := ConversationsPage{Conversations: conversations}
+	if len(conversations) == limit {
+		last := conversations[len(conversations)-1]
+		page.NextCursor = store.Cursor{UpdatedAt: last.UpdatedAt, ID: last.ID}.Encode()
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetUnreadMessagesCount godoc
+//
+//	@Summary		Count unread messages
+//	@Description	Unread messages across all the current user's conversations
+//	@Tags			Conversations
+//	@Produce		json
+//	@Success		200	{object}	map[string]int
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/unread-count [get]
+func (app *application) getUnreadMessagesCountHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	count, err := app.store.Conversations.UnreadCount(ctx, getUserFromCtx(ctx).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, map[string]int{"unread": count}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetConversation godoc
+//
+//	@Summary		Get a conversation
+//	@Description	Get a conversation with its participants and their read receipts
+//	@Tags			Conversations
+//	@Produce		json
+//	@Param			conversationID	path		int	true	"Conversation ID"
+//	@Success		200				{object}	store.Conversation
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID} [get]
+func (app *application) getConversationHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.writeResponse(w, http.StatusOK, getConversationFromCtx(r.Context())); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetMessages godoc
+//
+//	@Summary		List the messages of a conversation
+//	@Description	Newest first. Pass the ID of the oldest message received as before to get the previous page.
+//	@Tags			Conversations
+//	@Produce		json
+//	@Param			conversationID	path		int	true	"Conversation ID"
+//	@Param			before			query		int	false	"Return messages older than this message ID"
+//	@Param			limit			query		int	false	"Page size (max 100)"
+//	@Success		200				{array}		store.Message
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/messages [get]
+func (app *application) getMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	conversation := getConversationFromCtx(r.Context())
+	qs := r.URL.Query()
+
+	limit, err := parseLimit(qs.Get("limit"), messagesDefaultLimit, messagesMaxLimit)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var before int64
+	if b := qs.Get("before"); b != "" {
+		before, err = strconv.ParseInt(b, 10, 64)
+		if err != nil || before < 1 {
+			app.badRequestError(w, r, fmt.Errorf("invalid 'before' message id"))
+			return
+		}
+	}
+
+	messages, err := app.store.Conversations.ListMessages(r.Context(), conversation.ID, before, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, messages); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// SendMessage godoc
+//
+//	@Summary		Send a message
+//	@Description	In a 1:1 conversation the message is rejected if either user blocked the other or the other user is suspended.
+//	@Tags			Conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			conversationID	path		int					true	"Conversation ID"
+//	@Param			payload			body		SendMessagePayload	true	"Message payload"
+//	@Success		201				{object}	store.Message
+//	@Failure		400				{object}	error
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/messages [post]
+func (app *application) sendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SendMessagePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	conversation := getConversationFromCtx(ctx)
+
+	message := &store.Message{
+		ConversationID: conversation.ID,
+		SenderID:       getUserFromCtx(ctx).ID,
+		Content:        payload.Content,
+	}
+
+	if err := app.store.Conversations.CreateMessage(ctx, message); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDMBlocked):
+			app.forbiddenError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.publishToParticipants(ctx, conversation, stream.TypeMessage, message)
+
+	if err := app.writeResponse(w, http.StatusCreated, message); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// MarkConversationRead godoc
+//
+//	@Summary		Mark a conversation as read
+//	@Description	Move the current user's read receipt up to message_id, or to the last message if omitted. Read receipts never move backwards.
+//	@Tags			Conversations
+//	@Accept			json
+//	@Param			conversationID	path	int							true	"Conversation ID"
+//	@Param			payload			body	MarkConversationReadPayload	false	"Last read message"
+//	@Success		204				"Conversation marked as read"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/read [post]
+func (app *application) markConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MarkConversationReadPayload
+	if r.ContentLength != 0 {
+		if err := readJSON(w, r, &payload); err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	conversation := getConversationFromCtx(ctx)
+	user := getUserFromCtx(ctx)
+
+	lastRead, err := app.store.Conversations.MarkRead(ctx, conversation.ID, user.ID, payload.MessageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, fmt.Errorf("message not found in this conversation"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if lastRead > 0 {
+		app.publishToParticipants(ctx, conversation, stream.TypeMessageRead, map[string]int64{
+			"conversation_id": conversation.ID,
+			"user_id":         user.ID,
+			"message_id":      lastRead,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDMSettings godoc
+//
+//	@Summary		Get direct message settings
+//	@Description	Who can start a conversation with the current user: mutuals (mutual followers only) or everyone
+//	@Tags			Conversations
+//	@Produce		json
+//	@Success		200	{object}	DMSettingsPayload
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/settings [get]
+func (app *application) getDMSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	policy, err := app.store.Conversations.GetDMPolicy(ctx, getUserFromCtx(ctx).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, DMSettingsPayload{AllowMessagesFrom: policy}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UpdateDMSettings godoc
+//
+//	@Summary		Update direct message settings
+//	@Tags			Conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		DMSettingsPayload	true	"DM settings"
+//	@Success		200		{object}	DMSettingsPayload
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/settings [put]
+func (app *application) updateDMSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload DMSettingsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Conversations.SetDMPolicy(ctx, getUserFromCtx(ctx).ID, payload.AllowMessagesFrom); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, payload); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// conversationsContextMiddleware carga la conversacion si el usuario participa; si no, responde 404
+func (app *application) conversationsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil || id < 1 {
+			app.badRequestError(w, r, fmt.Errorf("invalid conversation id"))
+			return
+		}
+
+		ctx := r.Context()
+
+		conversation, err := app.store.Conversations.GetForUser(ctx, id, getUserFromCtx(ctx).ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, conversationCtx, conversation)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getConversationFromCtx(ctx context.Context) *store.Conversation {
+	conversation, _ := ctx.Value(conversationCtx).(*store.Conversation)
+
+	return conversation
+}
+
+// publishToParticipants manda el evento al stream de cada participante, incluido quien lo genero
+// para que lo vean sus otras sesiones
+func (app *application) publishToParticipants(ctx context.Context, conversation *store.Conversation, msgType string, data any) {
+	for _, p := range conversation.Participants {
+		if err := app.streamBroker.Publish(ctx, stream.UserTopic(p.UserID), msgType, data); err != nil {
+			app.logger.Errorw("error publishing stream message", "type", msgType, "user_id", p.UserID, "error", err)
+		}
+	}
+}
+
+// parseLimit valida el parametro limit de los listados paginados
+func parseLimit(s string, def, maxLimit int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > maxLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+	return n, nil
+}
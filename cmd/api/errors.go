@@ -4,43 +4,81 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/marceterrone10/social/internal/logging"
 )
 
+// logError registra el error con el logger del request, que ya trae el request ID, el trace ID y el usuario
+func (app *application) logError(r *http.Request, msg string, keysAndValues ...any) {
+	fields := []any{"method", r.Method, "path", r.URL.Path}
+	logging.FromContext(r.Context(), app.logger).Errorw(msg, append(fields, keysAndValues...)...)
+}
+
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("Internal server error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
-	errorJSON(w, http.StatusInternalServerError, err.Error())
+	app.logError(r, "Internal server error", "error", err.Error())
+	writeError(w, r, http.StatusInternalServerError, err.Error())
 }
 
 func (app *application) notFoundError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("Not found error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
-	errorJSON(w, http.StatusNotFound, err.Error())
+	app.logError(r, "Not found error", "error", err.Error())
+	writeError(w, r, http.StatusNotFound, err.Error())
 }
 
 func (app *application) badRequestError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("Bad request error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
-	errorJSON(w, http.StatusBadRequest, err.Error())
+	app.logError(r, "Bad request error", "error", err.Error())
+	writeError(w, r, http.StatusBadRequest, err.Error())
 }
 
 func (app *application) unauthorizedError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("Unauthorized error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
-	errorJSON(w, http.StatusUnauthorized, err.Error())
+	app.logError(r, "Unauthorized error", "error", err.Error())
+	writeError(w, r, http.StatusUnauthorized, err.Error())
 }
 
 func (app *application) unauthorizedBasicError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("Unauthorized basic auth error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	app.logError(r, "Unauthorized basic auth error", "error", err.Error())
 
 	w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
 
-	errorJSON(w, http.StatusUnauthorized, err.Error())
+	writeError(w, r, http.StatusUnauthorized, err.Error())
 }
 
 func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request) {
-	app.logger.Errorw("Forbidden error", "method", r.Method, "path", r.URL.Path)
-	errorJSON(w, http.StatusForbidden, "Forbidden")
+	app.logError(r, "Forbidden error")
+	writeError(w, r, http.StatusForbidden, "Forbidden")
 }
 
 func (app *application) tooManyRequestsError(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
-	app.logger.Errorw("Too many requests error", "method", r.Method, "path", r.URL.Path, "retry_after", retryAfter)
-	w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter.Seconds()))
-	errorJSON(w, http.StatusTooManyRequests, "Too many requests")
+	app.logError(r, "Too many requests error", "retry_after", retryAfter)
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", ceilSeconds(retryAfter)))
+	writeError(w, r, http.StatusTooManyRequests, "Too many requests")
+}
+
+func (app *application) payloadTooLargeError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, "Payload too large error", "error", err.Error())
+	writeError(w, r, http.StatusRequestEntityTooLarge, err.Error())
+}
+
+func (app *application) unsupportedMediaTypeError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, "Unsupported media type error", "error", err.Error())
+	writeError(w, r, http.StatusUnsupportedMediaType, err.Error())
+}
+
+func (app *application) conflictError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, "Conflict error", "error", err.Error())
+	writeError(w, r, http.StatusConflict, err.Error())
+}
+
+func (app *application) preconditionFailedError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, "Precondition failed error", "error", err.Error())
+	writeError(w, r, http.StatusPreconditionFailed, err.Error())
+}
+
+func (app *application) preconditionRequiredError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, "Precondition required error", "error", err.Error())
+	writeError(w, r, http.StatusPreconditionRequired, err.Error())
+}
+
+func (app *application) forbiddenError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, "Forbidden error", "error", err.Error())
+	writeError(w, r, http.StatusForbidden, err.Error())
 }
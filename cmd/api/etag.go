@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	errMissingIfMatch = errors.New("the If-Match header is required")
+	errStaleVersion   = errors.New("the resource was modified, fetch it again and retry")
+)
+
+// setETag expone la version del recurso como ETag. El cliente la devuelve en If-Match al modificarlo.
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
+// checkIfMatch valida el header If-Match contra la version actual y devuelve la version que el cliente
+// espera modificar. Si no es valido escribe la respuesta de error y devuelve false.
+// El store vuelve a chequear la version dentro de la transacción, esto solo evita trabajo de mas.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, current int) (int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		app.preconditionRequiredError(w, r, errMissingIfMatch)
+		return 0, false
+	}
+
+	// "*" matchea cualquier version del recurso
+	if header == "*" {
+		return current, true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		version, err := strconv.Atoi(strings.Trim(tag, `"`))
+		if err != nil {
+			app.badRequestError(w, r, fmt.Errorf("invalid If-Match header"))
+			return 0, false
+		}
+		if version == current {
+			return version, true
+		}
+	}
+
+	app.preconditionFailedError(w, r, errStaleVersion)
+	return 0, false
+}
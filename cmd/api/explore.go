@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/trending"
+)
+
+const trendingDefaultLimit = 10
+
+type TrendingPost struct {
+	*store.PostWithMetadata
+	Score float64 `json:"score"`
+}
+
+type TrendingResponse struct {
+	Window     string              `json:"window"`
+	Tags       []store.TrendingTag `json:"tags"`
+	Posts      []TrendingPost      `json:"posts"`
+	ComputedAt time.Time           `json:"computed_at"`
+}
+
+// GetTrending godoc
+//
+//	@Summary		Trending hashtags and posts
+//	@Description	Hashtags and posts with the fastest growing activity in the window. Recent interactions weigh more than older ones. Content from suspended users is excluded, and posts from users blocked by (or blocking) the current user are hidden and do not count towards the hashtags.
+//	@Tags			Explore
+//	@Produce		json
+//	@Param			window	query		string	false	"1h, 24h (default) or 7d"
+//	@Param			limit	query		int		false	"Number of tags and posts (max TRENDING_LIMIT, 50 by default)"
+//	@Success		200		{object}	TrendingResponse
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/explore/trending [get]
+func (app *application) getTrendingHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	name := qs.Get("window")
+	if name == "" {
+		name = "24h"
+	}
+	window, ok := trending.WindowByName(name)
+	if !ok {
+		names := make([]string, len(trending.Windows))
+		for i, w := range trending.Windows {
+			names[i] = w.Name
+		}
+		app.badRequestError(w, r, fmt.Errorf("window must be one of %s", strings.Join(names, ", ")))
+		return
+	}
+
+	limit, err := parseLimit(qs.Get("limit"), trendingDefaultLimit, app.config.trending.Limit)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	result, err := app.trending.Trending(ctx, window)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// el resultado es el mismo para todos, los bloqueos se aplican por usuario despues del cache
+	blocked, err := app.store.Blocks.BlockedIDs(ctx, getUserFromCtx(ctx).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// los tags tampoco cuentan la actividad de los posts de usuarios bloqueados
+	tags, err := app.trending.WithoutAuthors(ctx, window, result.Tags, blocked)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ids := make([]int64, len(result.Posts))
+	scores := make(map[int64]float64, len(result.Posts))
+	for i, ps := range result.Posts {
+		ids[i] = ps.PostID
+		scores[ps.PostID] = ps.Score
+	}
+
+	posts := []*store.PostWithMetadata{}
+	if len(ids) > 0 {
+		posts, err = app.store.Posts.GetWithMetadataByIds(ctx, ids)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	posts = slices.DeleteFunc(posts, func(p *store.PostWithMetadata) bool {
+		return slices.Contains(blocked, p.UserID)
+	})
+	posts = posts[:min(limit, len(posts))]
+
+	if err := app.attachToFeed(ctx, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	// la cita de un post de un usuario bloqueado queda como tombstone
+	for _, p := range posts {
+		if p.QuotedPost != nil && slices.Contains(blocked, p.QuotedPost.UserID) {
+			p.QuotedPost = store.NewQuotedPost(p.QuotedPost.ID, nil)
+		}
+	}
+
+	response := TrendingResponse{
+		Window:     result.Window,
+		Tags:       tags[:min(limit, len(tags))],
+		Posts:      make([]TrendingPost, len(posts)),
+		ComputedAt: result.ComputedAt,
+	}
+	for i, p := range posts {
+		response.Posts[i] = TrendingPost{PostWithMetadata: p, Score: scores[p.ID]}
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(app.config.trending.CacheTTL.Seconds())))
+	if err := app.writeResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
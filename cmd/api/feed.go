@@ -1,32 +1,47 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/marceterrone10/social/internal/ranking"
 	"github.com/marceterrone10/social/internal/store"
 )
 
+// Modos del feed
+const (
+	feedModeChronological = "chronological"
+	feedModeRanked        = "ranked"
+)
+
+var defaultFeedQuery = store.PaginatedQuery{
+	Limit:  10,
+	Offset: 0,
+	Sort:   "desc",
+}
+
 // GetFeed godoc
 //
 //	@Summary		Get a feed of posts
-//	@Description	Get a feed of posts for the current user
+//	@Description	Get a feed of posts for the current user. A post reposted by several followed users appears once, with reposted_by listing who shared it.
+//	@Description	mode=ranked returns the "For You" feed instead: posts from followed users and second-degree connections ordered by score, each one with a ranking explanation (see RankedPost). sort does not apply to it.
 //	@Tags			Feed
 //	@Accept			json
 //	@Produce		json
+//	@Param			mode	query		string		false	"chronological (default) or ranked"
 //	@Param			limit	query		int			false	"Limit the number of posts returned"
 //	@Param			offset	query		int			false	"Offset the number of posts returned"
 //	@Param			sort	query		string		false	"Sort the posts by created_at in ascending or descending order"
-//	@Success		200		{array}		store.Post	"Feed of posts"
+//	@Success		200		{array}		store.PostWithMetadata	"Feed of posts"
 //	@Failure		400		{object}	error		"Bad request"
 //	@Failure		500		{object}	error		"Internal server error"
+//	@Security		ApiKeyAuth
 //	@Router			/users/feed [get]
 func (app *application) getFeedHandler(w http.ResponseWriter, r *http.Request) {
 
-	fq := store.PaginatedQuery{ // default values
-		Limit:  20,
-		Offset: 0,
-		Sort:   "desc",
-	}
+	fq := defaultFeedQuery
 
 	fq, err := fq.ParseURLParams(r) // parseo los parametros de la url y los reemplazo en fq
 	if err != nil {
@@ -40,8 +55,25 @@ func (app *application) getFeedHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	user := getUserFromCtx(ctx)
+
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", feedModeChronological:
+	case feedModeRanked:
+		app.getRankedFeed(w, r, user.ID, fq)
+		return
+	default:
+		app.badRequestError(w, r, fmt.Errorf("invalid feed mode %q", mode))
+		return
+	}
 
-	posts, err := app.store.Posts.GetFeed(ctx, int64(1), fq)
+	// solo se cachea la primera pagina con los parametros por defecto, la que se pide al abrir la app
+	load := app.loadFeed
+	if fq == defaultFeedQuery {
+		load = app.getFeedFromCache
+	}
+
+	posts, err := load(ctx, user.ID, fq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -52,3 +84,91 @@ func (app *application) getFeedHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// RankedPost es un post del feed rankeado con la explicacion de su score
+type RankedPost struct {
+	*store.PostWithMetadata
+	Ranking ranking.Explanation `json:"ranking"`
+}
+
+func (app *application) getRankedFeed(w http.ResponseWriter, r *http.Request, userID int64, fq store.PaginatedQuery) {
+	ctx := r.Context()
+	now := time.Now()
+	cfg := app.config.ranking
+
+	candidates, err := app.store.Ranking.GetCandidates(ctx, userID, now.Add(-cfg.Window), cfg.PoolSize)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	profile, err := app.store.Ranking.GetInteractionProfile(ctx, userID, now.Add(-cfg.ProfileWindow))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ranked := app.ranker.Rank(candidates, profile, now)
+
+	page := []RankedPost{}
+	if fq.Offset < len(ranked) {
+		ranked = ranked[fq.Offset:min(fq.Offset+fq.Limit, len(ranked))]
+
+		ids := make([]int64, len(ranked))
+		for i, rp := range ranked {
+			ids[i] = rp.PostID
+		}
+
+		posts, err := app.store.Posts.GetWithMetadataByIds(ctx, ids)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if err := app.attachToFeed(ctx, posts); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		byID := make(map[int64]*store.PostWithMetadata, len(posts))
+		for _, p := range posts {
+			byID[p.ID] = p
+		}
+		for _, rp := range ranked {
+			if p, ok := byID[rp.PostID]; ok {
+				page = append(page, RankedPost{PostWithMetadata: p, Ranking: rp.Explanation})
+			}
+		}
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) attachToFeed(ctx context.Context, posts []*store.PostWithMetadata) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	byPost, err := app.store.Attachments.GetByPostIds(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	quoting := make([]*store.Post, len(posts))
+	for i, p := range posts {
+		p.Attachments = byPost[p.ID]
+		if p.Attachments == nil {
+			p.Attachments = []store.Attachment{}
+		}
+		quoting[i] = &p.Post
+	}
+
+	return app.attachQuotes(ctx, quoting)
+}
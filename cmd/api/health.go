@@ -1,27 +1,244 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"sync"
+	"time"
+
+	"github.com/marceterrone10/social/internal/db"
+)
+
+// estados de cada chequeo del healthcheck
+const (
+	checkStatusUp       = "up"
+	checkStatusDown     = "down"
+	checkStatusDisabled = "disabled"
+	// la dependencia responde pero la configuracion esta incompleta
+	checkStatusMisconfigured = "misconfigured"
 )
 
+// tiempo maximo de cada probe, un probe colgado no puede bloquear al load balancer
+const healthProbeTimeout = 2 * time.Second
+
+// cuanto se reutiliza el resultado de los probes de /readyz: el endpoint es publico y sin rate limit,
+// asi no golpea la DB y Redis en cada request
+const readinessCacheTTL = time.Second
+
+type readinessCache struct {
+	mu     sync.Mutex
+	checks map[string]HealthCheck
+	at     time.Time
+}
+
+// HealthCheck es el resultado del chequeo de una dependencia
+type HealthCheck struct {
+	Status string `json:"status"`
+	// solo los chequeos criticos afectan la readiness
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	Details   any     `json:"details,omitempty"`
+}
+
+type HealthReport struct {
+	Status      string                 `json:"status"`
+	Environment string                 `json:"environment"`
+	Version     string                 `json:"version"`
+	Ready       bool                   `json:"ready"`
+	Checks      map[string]HealthCheck `json:"checks"`
+}
+
 // Healthcheck godoc
 //
 //	@Summary		Healthcheck the API
-//	@Description	Check if the API is running and get system information
+//	@Description	Detailed report of the API and its dependencies: database (with pool stats), Redis, migration version and mailer configuration, with the latency of each probe.
+//	@Description	Returns 503 when a critical dependency is down.
 //	@Tags			Health
 //	@Produce		json
-//	@Success		200	{object}	map[string]string	"Returns status, environment, and version"
-//	@Failure		500	{object}	error				"Internal server error"
+//	@Success		200	{object}	HealthReport	"Every critical dependency is up"
+//	@Failure		503	{object}	HealthReport	"Some critical dependency is down"
 //	@Router			/healthcheck [get]
 func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	checks := app.runHealthChecks(r.Context(), true)
+
+	report := HealthReport{
+		Status:      "available",
+		Environment: app.config.env,
+		Version:     version,
+		Ready:       app.ready.Load(),
+		Checks:      checks,
+	}
+
+	status := http.StatusOK
+	if !healthy(checks) {
+		report.Status = "degraded"
+		status = http.StatusServiceUnavailable
+	}
+
+	if err := writeJSON(w, status, report); err != nil {
+		errorJSON(w, http.StatusInternalServerError, err.Error())
+	}
+}
 
-	data := map[string]string{
-		"status":      "available",
-		"environment": app.config.env,
-		"version":     version,
+// Livez godoc
+//
+//	@Summary		Liveness probe
+//	@Description	Returns 200 while the process is able to serve requests, regardless of its dependencies
+//	@Tags			Health
+//	@Produce		json
+//	@Success		200	{object}	map[string]string
+//	@Router			/livez [get]
+func (app *application) livezHandler(w http.ResponseWriter, r *http.Request) {
+	if err := writeJSON(w, http.StatusOK, map[string]string{"status": "alive"}); err != nil {
+		errorJSON(w, http.StatusInternalServerError, err.Error())
 	}
+}
 
-	if err := writeJSON(w, http.StatusOK, data); err != nil {
+// Readyz godoc
+//
+//	@Summary		Readiness probe
+//	@Description	Returns 503 while the server is starting, draining connections during shutdown or when a critical dependency (database, Redis) is down
+//	@Tags			Health
+//	@Produce		json
+//	@Success		200	{object}	map[string]any
+//	@Failure		503	{object}	map[string]any
+//	@Router			/readyz [get]
+func (app *application) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.ready.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "not ready"})
+		return
+	}
+
+	// el endpoint es publico, solo se informa el estado de cada chequeo sin errores ni detalles
+	checks := app.readinessChecks(r.Context())
+	statuses := make(map[string]string, len(checks))
+	for name, c := range checks {
+		statuses[name] = c.Status
+	}
+
+	data := map[string]any{"status": "ready", "checks": statuses}
+	status := http.StatusOK
+	if !healthy(checks) {
+		data["status"] = "not ready"
+		status = http.StatusServiceUnavailable
+	}
+
+	if err := writeJSON(w, status, data); err != nil {
 		errorJSON(w, http.StatusInternalServerError, err.Error())
 	}
 }
+
+// readinessChecks devuelve el resultado de los probes criticos, reutilizando el ultimo por readinessCacheTTL.
+// Los requests concurrentes esperan al mismo probe.
+func (app *application) readinessChecks(ctx context.Context) map[string]HealthCheck {
+	app.readiness.mu.Lock()
+	defer app.readiness.mu.Unlock()
+
+	if app.readiness.checks != nil && time.Since(app.readiness.at) < readinessCacheTTL {
+		return app.readiness.checks
+	}
+
+	// el resultado se comparte: un cliente que corta la conexion no puede dejar cacheado un probe fallido
+	app.readiness.checks = app.runHealthChecks(context.WithoutCancel(ctx), false)
+	app.readiness.at = time.Now()
+	return app.readiness.checks
+}
+
+// runHealthChecks corre los probes de las dependencias. Con detailed agrega los que no afectan la readiness.
+func (app *application) runHealthChecks(ctx context.Context, detailed bool) map[string]HealthCheck {
+	checks := map[string]HealthCheck{
+		"database": app.probe(ctx, true, app.checkDatabase),
+		"redis":    app.probe(ctx, true, app.checkRedis),
+	}
+	if detailed {
+		checks["migrations"] = app.probe(ctx, false, app.checkMigrations)
+		checks["mailer"] = app.probe(ctx, false, app.checkMailer)
+	}
+	return checks
+}
+
+// healthy es false si algun chequeo critico esta caido
+func healthy(checks map[string]HealthCheck) bool {
+	for _, c := range checks {
+		if c.Critical && c.Status == checkStatusDown {
+			return false
+		}
+	}
+	return true
+}
+
+// probe corre el chequeo con timeout y mide cuanto tarda
+func (app *application) probe(ctx context.Context, critical bool, check func(context.Context) (string, any, error)) HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	status, details, err := check(ctx)
+	hc := HealthCheck{
+		Status:    status,
+		Critical:  critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+	if err != nil {
+		hc.Status = checkStatusDown
+		hc.Error = err.Error()
+	}
+	return hc
+}
+
+func (app *application) checkDatabase(ctx context.Context) (string, any, error) {
+	err := app.db.PingContext(ctx)
+
+	stats := app.db.Stats()
+	details := map[string]any{
+		"max_open_connections": stats.MaxOpenConnections,
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"wait_count":           stats.WaitCount,
+		"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+		"max_idle_closed":      stats.MaxIdleClosed,
+		"max_lifetime_closed":  stats.MaxLifetimeClosed,
+	}
+	return checkStatusUp, details, err
+}
+
+func (app *application) checkRedis(ctx context.Context) (string, any, error) {
+	if app.redis == nil {
+		return checkStatusDisabled, nil, nil
+	}
+	return checkStatusUp, nil, app.redis.Ping(ctx).Err()
+}
+
+func (app *application) checkMigrations(ctx context.Context) (string, any, error) {
+	version, dirty, err := db.MigrationVersion(ctx, app.db)
+	if err != nil {
+		return "", nil, err
+	}
+
+	details := map[string]any{"version": version, "dirty": dirty}
+	if dirty {
+		// una migracion quedo a medias, hay que arreglarla a mano con migrate force
+		return checkStatusMisconfigured, details, nil
+	}
+	return checkStatusUp, details, nil
+}
+
+// checkMailer solo revisa la configuracion, no manda nada a SendGrid
+func (app *application) checkMailer(ctx context.Context) (string, any, error) {
+	cfg := app.config.mail
+	details := map[string]any{
+		"provider":       "sendgrid",
+		"from_email":     cfg.fromEmail,
+		"api_key_set":    cfg.sendGrid.apiKey != "",
+		"sandbox":        app.config.env != "production",
+		"invitation_exp": cfg.exp.String(),
+	}
+
+	if cfg.fromEmail == "" || cfg.sendGrid.apiKey == "" {
+		return checkStatusMisconfigured, details, nil
+	}
+	return checkStatusUp, details, nil
+}
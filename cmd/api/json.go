@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/marceterrone10/social/internal/tracing"
 )
 
 var Validate *validator.Validate // singleton para validar los payloads
@@ -41,6 +42,15 @@ func errorJSON(w http.ResponseWriter, status int, message string) error {
 	return writeJSON(w, status, envelope{Error: message})
 }
 
+// writeError es errorJSON con el trace ID del request, que el cliente puede informar al reportar el error
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) error {
+	type envelope struct {
+		Error   string `json:"error"`
+		TraceID string `json:"trace_id,omitempty"`
+	}
+	return writeJSON(w, status, envelope{Error: message, TraceID: tracing.TraceID(r.Context())})
+}
+
 func (app *application) writeResponse(w http.ResponseWriter, status int, data any) error {
 	type envelope struct {
 		Data any `json:"data"`
@@ -3,64 +3,133 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/marceterrone10/social/internal/logging"
 	"github.com/marceterrone10/social/internal/store"
 )
 
+type authUserKey string
+
+// el usuario que ya autentico RateLimiterMiddleware, para no volver a buscarlo
+const authUserCtx authUserKey = "authUser"
+
+var errAccountSuspended = errors.New("the account is suspended")
+
 func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			app.logger.Debugw("missing authorization header", "path", r.URL.Path)
-			app.unauthorizedError(w, r, fmt.Errorf("Missing authorization header"))
-			return
-		}
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			app.logger.Debugw("invalid authorization header format", "path", r.URL.Path, "header", authHeader)
-			app.unauthorizedError(w, r, fmt.Errorf("Invalid authorization header"))
-			return
+		ctx := r.Context()
+
+		user, ok := ctx.Value(authUserCtx).(*store.User)
+		if !ok {
+			var err error
+			if user, err = app.authenticate(r); err != nil {
+				app.unauthorizedError(w, r, err)
+				return
+			}
 		}
-		token := parts[1]
-		jwtToken, err := app.authenticator.ValidateToken(token)
-		if err != nil {
-			app.unauthorizedError(w, r, fmt.Errorf("Token is invalid"))
+
+		// los tokens de un usuario suspendido dejan de servir hasta que se levante la suspension
+		if user.SuspendedAt != nil {
+			app.forbiddenError(w, r, errAccountSuspended)
 			return
 		}
 
-		claims, _ := jwtToken.Claims.(jwt.MapClaims)
+		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = logging.SetUser(ctx, app.logger, user.ID)
 
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
-		if err != nil {
-			app.unauthorizedError(w, r, err)
-			return
-		}
+		// el usuario autenticado es el actor de los eventos de auditoria del request
+		ac := store.AuditContextFrom(ctx)
+		ac.ActorID = user.ID
+		ctx = store.WithAuditContext(ctx, ac)
 
-		ctx := r.Context()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		user, err := app.getUserFromCache(ctx, userID)
-		if err != nil {
-			app.unauthorizedError(w, r, err)
-			return
-		}
+// authenticate valida el bearer token del request y devuelve el usuario del token
+func (app *application) authenticate(r *http.Request) (*store.User, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		app.logger.Debugw("missing authorization header", "path", r.URL.Path)
+		return nil, fmt.Errorf("Missing authorization header")
+	}
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		app.logger.Debugw("invalid authorization header format", "path", r.URL.Path)
+		return nil, fmt.Errorf("Invalid authorization header")
+	}
+	jwtToken, err := app.authenticator.ValidateToken(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("Token is invalid")
+	}
 
-		ctx = context.WithValue(ctx, userCtx, user)
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.getUserFromCache(r.Context(), userID)
+}
+
+// AuditContextMiddleware adjunta la IP y el request ID al contexto para los eventos de auditoria
+func (app *application) AuditContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := store.WithAuditContext(r.Context(), store.AuditContext{
+			IP:        clientIP(r),
+			RequestID: middleware.GetReqID(r.Context()),
+		})
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// RequireRoleMiddleware solo deja pasar a usuarios con un nivel de rol mayor o igual al requerido
+func (app *application) RequireRoleMiddleware(requiredRole string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getUserFromCtx(r.Context())
+
+			allowed, err := app.checkRole(r.Context(), user, requiredRole)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
+	return app.basicAuth(app.config.auth.basic)
+}
+
+// basicAuth valida las credenciales del header contra expected
+func (app *application) basicAuth(expected basicAuthConfig) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// nunca se loguea el header ni las credenciales decodificadas
+			log := logging.FromContext(r.Context(), app.logger)
+
 			// leer el header de auth
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				app.logger.Debugw("Basic auth: missing authorization header", "path", r.URL.Path)
+				log.Debugw("Basic auth: missing authorization header", "path", r.URL.Path)
 				app.unauthorizedBasicError(w, r, fmt.Errorf("Missing authorization header"))
 				return
 			}
@@ -68,7 +137,7 @@ func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 			// parseaarlo => obtener el base64
 			headerParts := strings.Split(authHeader, " ")
 			if len(headerParts) != 2 || headerParts[0] != "Basic" {
-				app.logger.Debugw("Basic auth: invalid authorization header format", "path", r.URL.Path, "header", authHeader)
+				log.Debugw("Basic auth: invalid authorization header format", "path", r.URL.Path)
 				app.unauthorizedBasicError(w, r, fmt.Errorf("Invalid authorization header"))
 				return
 			}
@@ -76,32 +145,29 @@ func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 			// decodificar el base64
 			decoded, err := base64.StdEncoding.DecodeString(headerParts[1])
 			if err != nil {
-				app.logger.Debugw("Basic auth: failed to decode base64", "path", r.URL.Path, "error", err)
+				log.Debugw("Basic auth: failed to decode base64", "path", r.URL.Path, "error", err)
 				app.unauthorizedBasicError(w, r, fmt.Errorf("Invalid authorization header"))
 				return
 			}
 
 			// checkear credenciales
-			username := app.config.auth.basic.username
-			password := app.config.auth.basic.password
+			username := expected.username
+			password := expected.password
 
 			creds := strings.SplitN(string(decoded), ":", 2)
 			if len(creds) != 2 {
-				app.logger.Debugw("Basic auth: invalid credentials format", "path", r.URL.Path, "decoded", string(decoded))
+				log.Debugw("Basic auth: invalid credentials format", "path", r.URL.Path)
 				app.unauthorizedBasicError(w, r, fmt.Errorf("Invalid credentials"))
 				return
 			}
 
 			if creds[0] != username || creds[1] != password {
-				app.logger.Debugw("Basic auth: invalid credentials",
-					"path", r.URL.Path,
-					"provided_username", creds[0],
-					"expected_username", username)
+				log.Debugw("Basic auth: invalid credentials", "path", r.URL.Path, "provided_username", creds[0])
 				app.unauthorizedBasicError(w, r, fmt.Errorf("Invalid credentials"))
 				return
 			}
 
-			app.logger.Debugw("Basic auth: authentication successful", "path", r.URL.Path, "username", username)
+			log.Debugw("Basic auth: authentication successful", "path", r.URL.Path, "username", username)
 			next.ServeHTTP(w, r)
 		})
 	}
@@ -112,7 +178,7 @@ func (app *application) CheckPostOwnership(requiredRole string, next http.Handle
 		user := getUserFromCtx(r.Context())
 		post := getPostFromCtx(r.Context())
 		// chequear si el usuario es el propietario del post
-		if post.UserID != user.ID {
+		if post.UserID == user.ID {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -133,6 +199,15 @@ func (app *application) CheckPostOwnership(requiredRole string, next http.Handle
 	})
 }
 
+// clientIP devuelve la IP del cliente sin el puerto (realip ya reemplazo RemoteAddr si el request vino de un proxy de confianza)
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func (app *application) checkRole(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := app.store.Roles.GetByName(ctx, roleName)
 	if err != nil {
@@ -141,35 +216,84 @@ func (app *application) checkRole(ctx context.Context, user *store.User, roleNam
 	return user.Role.Level >= role.Level, nil
 }
 
+// getUserFromCache devuelve el usuario del cache o, si no esta, de la DB. Los usuarios que no existen
+// tambien se cachean, asi los ids invalidos no llegan a la DB en cada request.
 func (app *application) getUserFromCache(ctx context.Context, userID int64) (*store.User, error) {
 	user, err := app.cacheStorage.Users.Get(ctx, userID)
-	if err != nil {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
 		return nil, err
+	case err != nil:
+		// si el cache falla se lee de la DB
+		app.logger.Warnw("error reading cached user", "user_id", userID, "error", err)
+	case user != nil:
+		return user, nil
 	}
 
-	if user == nil {
+	v, err := app.loadShared(ctx, fmt.Sprintf("user-%d", userID), func(ctx context.Context) (any, error) {
 		user, err := app.store.Users.GetById(ctx, userID)
+		if errors.Is(err, store.ErrNotFound) {
+			if err := app.cacheStorage.Users.SetNotFound(ctx, userID); err != nil {
+				app.logger.Warnw("error caching missing user", "user_id", userID, "error", err)
+			}
+		}
 		if err != nil {
 			return nil, err
 		}
 
 		if err := app.cacheStorage.Users.Set(ctx, user); err != nil {
-			return nil, err
+			app.logger.Warnw("error caching user", "user_id", userID, "error", err)
 		}
-
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return user, nil
+	return v.(*store.User), nil
 }
 
+// RateLimiterMiddleware aplica la policy de la ruta. Los requests autenticados se limitan por usuario
+// (con la cuota de su rol) y los anonimos por IP. Todas las respuestas llevan los headers RateLimit-*.
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.rateLimiter.Enabled {
-			if allow, retryAfter := app.rateLimiter.Allow(r.RemoteAddr); !allow {
-				app.tooManyRequestsError(w, r, retryAfter)
-				return
-			}
+		// los probes de salud no pueden quedar limitados, los manda el load balancer muy seguido
+		if !app.config.rateLimiter.Enabled || r.URL.Path == "/livez" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
 		}
-		next.ServeHTTP(w, r)
+
+		ctx := r.Context()
+		policy := app.rateLimiter.Match(r.Method, r.URL.Path)
+
+		key, role := "ip:"+clientIP(r), ""
+		// un token invalido no corta el request aca, se limita por IP y lo rechaza AuthTokenMiddleware
+		if user, err := app.authenticate(r); err == nil {
+			key, role = fmt.Sprintf("user:%d", user.ID), user.Role.Name
+			ctx = context.WithValue(ctx, authUserCtx, user)
+		}
+
+		res, err := app.rateLimiter.Allow(ctx, policy, role, key)
+		if err != nil {
+			// si el backend del limiter falla se deja pasar el request
+			app.logger.Errorw("rate limiter error", "policy", policy.Name, "error", err)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+		if !res.Allowed {
+			app.metrics.RateLimited(policy.Name)
+			app.tooManyRequestsError(w, r, res.RetryAfter)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// ceilSeconds redondea para arriba, asi un cliente que espera lo indicado no vuelve a ser rechazado
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
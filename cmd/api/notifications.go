@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/marceterrone10/social/internal/store"
+)
+
+const (
+	notificationsDefaultLimit = 20
+	notificationsMaxLimit     = 50
+)
+
+type NotificationsPage struct {
+	Notifications []*store.Notification `json:"notifications"`
+	// vacio cuando no hay mas paginas
+	NextCursor string `json:"next_cursor"`
+}
+
+// GetNotifications godoc
+//
+//	@Summary		List notifications
+//	@Description	List the current user's notifications, newest first. Events of the same kind on the same object are grouped while unread. Pass next_cursor as cursor to get the next page.
+//	@Description	Notifications are ordered by their last activity: a group that gains an actor while paginating moves back to the top, so it is not repeated in the following pages and shows up on the first page of the next load.
+//	@Tags			Notifications
+//	@Produce		json
+//	@Param			cursor	query		string	false	"Cursor returned by the previous page"
+//	@Param			limit	query		int		false	"Page size (max 50)"
+//	@Success		200		{object}	NotificationsPage
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications [get]
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromCtx(ctx)
+	qs := r.URL.Query()
+
+	limit, err := parseLimit(qs.Get("limit"), notificationsDefaultLimit, notificationsMaxLimit)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var cursor *store.Cursor
+	if c := qs.Get("cursor"); c != "" {
+		cursor, err = store.ParseCursor(c)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	notifications, err := app.store.Notifications.List(ctx, user.ID, cursor, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	page := NotificationsPage{Notifications: notifications}
+	if len(notifications) == limit {
+		last := notifications[len(notifications)-1]
+		page.NextCursor = store.Cursor{UpdatedAt: last.UpdatedAt, ID: last.ID}.Encode()
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetUnreadNotificationsCount godoc
+//
+//	@Summary		Count unread notifications
+//	@Tags			Notifications
+//	@Produce		json
+//	@Success		200	{object}	map[string]int
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/unread-count [get]
+func (app *application) getUnreadNotificationsCountHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	count, err := app.store.Notifications.UnreadCount(ctx, getUserFromCtx(ctx).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, map[string]int{"unread": count}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// MarkNotificationRead godoc
+//
+//	@Summary		Mark a notification as read
+//	@Tags			Notifications
+//	@Param			notificationID	path	int	true	"Notification ID"
+//	@Success		204				"Notification marked as read"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/{notificationID}/read [patch]
+func (app *application) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "notificationID"), 10, 64)
+	if err != nil || id < 1 {
+		app.badRequestError(w, r, fmt.Errorf("invalid notification id"))
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Notifications.MarkRead(ctx, getUserFromCtx(ctx).ID, id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllNotificationsRead godoc
+//
+//	@Summary		Mark every notification as read
+//	@Tags			Notifications
+//	@Success		204	"Notifications marked as read"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/read-all [post]
+func (app *application) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := app.store.Notifications.MarkAllRead(ctx, getUserFromCtx(ctx).ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetNotificationPreferences godoc
+//
+//	@Summary		Get notification preferences
+//	@Description	Whether each notification type (follow, comment, mention, reaction) is enabled
+//	@Tags			Notifications
+//	@Produce		json
+//	@Success		200	{object}	map[string]bool
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/preferences [get]
+func (app *application) getNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	prefs, err := app.store.Notifications.GetPreferences(ctx, getUserFromCtx(ctx).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, prefs); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UpdateNotificationPreferences godoc
+//
+//	@Summary		Update notification preferences
+//	@Description	Enable or disable notification types. Types not included keep their current setting.
+//	@Tags			Notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		map[string]bool	true	"Preferences by type"
+//	@Success		200		{object}	map[string]bool
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/preferences [put]
+func (app *application) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	var payload map[string]bool
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	for t := range payload {
+		if !slices.Contains(store.NotificationTypes, t) {
+			app.badRequestError(w, r, fmt.Errorf("unknown notification type %q", t))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	userID := getUserFromCtx(ctx).ID
+
+	if err := app.store.Notifications.SetPreferences(ctx, userID, payload); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	prefs, err := app.store.Notifications.GetPreferences(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, prefs); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
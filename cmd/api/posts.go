@@ -3,11 +3,15 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/marceterrone10/social/internal/events"
 	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/store/cache"
 )
 
 type postKey string
@@ -15,15 +19,19 @@ type postKey string
 const postCtx postKey = "post" // clave para el contexto del post
 
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title     string     `json:"title" validate:"required,max=100"`
+	Content   string     `json:"content" validate:"required,max=1000"`
+	Tags      []string   `json:"tags" validate:"omitempty,dive,max=100"`
+	Status    string     `json:"status" validate:"omitempty,oneof=draft published"`
+	PublishAt *time.Time `json:"publish_at"`
+	// si se manda, el post es un quote del post indicado
+	QuotedPostID *int64 `json:"quoted_post_id" validate:"omitempty,min=1"`
 }
 
 // CreatePost godoc
 //
 //	@Summary		Create a new post
-//	@Description	Create a new post with a title, content, and tags
+//	@Description	Create a new post with a title, content, and tags. Use status "draft" to save it without publishing, or a future publish_at to schedule it. Set quoted_post_id to quote another post.
 //	@Tags			Posts
 //	@Accept			json
 //	@Produce		json
@@ -45,6 +53,11 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	} // validamos el payload
 
+	if err := validateTags(payload.Tags); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
 	user := getUserFromCtx(r.Context())
 
 	post := &store.Post{
@@ -52,15 +65,44 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		Content: payload.Content,
 		Tags:    payload.Tags,
 		UserID:  user.ID,
+		Status:  store.PostStatusPublished,
+	}
+
+	if payload.Status == store.PostStatusDraft {
+		post.Status = store.PostStatusDraft
+	}
+
+	if payload.PublishAt != nil {
+		if err := schedulePost(post, *payload.PublishAt); err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
 	}
 
 	ctx := r.Context()
 
+	if payload.QuotedPostID != nil {
+		original, err := app.store.Posts.GetById(ctx, *payload.QuotedPostID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if original == nil || original.Status != store.PostStatusPublished {
+			app.badRequestError(w, r, fmt.Errorf("quoted post not found"))
+			return
+		}
+		post.QuotedPostID = &original.ID
+		post.QuotedPost = store.NewQuotedPost(original.ID, original)
+	}
+
 	if err := app.store.Posts.Create(ctx, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	} // creamos el post en la base de datos
 
+	app.publishPostEvents(ctx, post, true)
+
+	setETag(w, post.Version)
 	if err := app.writeResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -81,15 +123,10 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 //	@Failure		500	{object}	error		"Internal server error"
 //	@Router			/posts/{id} [get]
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
+	// en los GET postsContextMiddleware ya carga el post con comentarios, adjuntos y quote
 	post := getPostFromCtx(r.Context())
 
-	comments, err := app.store.Comments.GetByPostId(r.Context(), post.ID) // obtenemos los comentarios del post
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-	post.Comments = *comments // asignamos los comentarios al post
-
+	setETag(w, post.Version)
 	if err := app.writeResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -119,7 +156,13 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 	} // parseamos el id del post
 	ctx := r.Context()
 
-	_, err = app.store.Posts.Delete(ctx, id)
+	attachments, err := app.store.Attachments.GetByPostId(ctx, id)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	post, err := app.store.Posts.Delete(ctx, id)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
@@ -130,29 +173,49 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	} // eliminamos el post
 
+	// las filas de los adjuntos se borran en cascada, los archivos hay que borrarlos a mano
+	app.deleteBlobs(attachments...)
+
+	app.events.Publish(ctx, events.Event{Type: events.TypePostDeleted, ActorID: post.UserID, PostID: post.ID})
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 type UpdatePostPayload struct {
-	Title   *string `json:"title" validate:"omitempty,max=100"`
-	Content *string `json:"content" validate:"omitempty,max=1000"`
+	Title     *string    `json:"title" validate:"omitempty,max=100"`
+	Content   *string    `json:"content" validate:"omitempty,max=1000"`
+	Tags      *[]string  `json:"tags" validate:"omitempty,dive,max=100"`
+	Status    *string    `json:"status" validate:"omitempty,oneof=draft published"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 // UpdatePost godoc
 //
 //	@Summary		Update a post by ID
-//	@Description	Update a post by ID
+//	@Description	Update a post by ID. Tags can be replaced, hashtags in the title and content are always added. Drafts can be scheduled with publish_at, published with status "published" or unscheduled with status "draft".
+//	@Description	The If-Match header must carry the ETag returned by GET /posts/{id}.
 //	@Tags			Posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int					true	"Post ID"
-//	@Param			payload	body		UpdatePostPayload	true	"Post payload"
-//	@Success		200		{object}	store.Post			"Post updated successfully"
-//	@Failure		400		{object}	error				"Bad request"
-//	@Failure		500		{object}	error				"Internal server error"
+//	@Param			id			path		int					true	"Post ID"
+//	@Param			If-Match	header		string				true	"ETag of the version being edited"
+//	@Param			payload		body		UpdatePostPayload	true	"Post payload"
+//	@Success		200			{object}	store.Post			"Post updated successfully"
+//	@Failure		400			{object}	error				"Bad request"
+//	@Failure		409			{object}	error				"Published posts cannot go back to draft"
+//	@Failure		412			{object}	error				"The post was modified by another request"
+//	@Failure		428			{object}	error				"Missing If-Match header"
+//	@Failure		500			{object}	error				"Internal server error"
 //	@Router			/posts/{id} [patch]
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r.Context())
+	wasPublished := post.Status == store.PostStatusPublished
+
+	version, ok := app.checkIfMatch(w, r, post.Version)
+	if !ok {
+		return
+	}
+	post.Version = version
 
 	var payload UpdatePostPayload
 	if err := readJSON(w, r, &payload); err != nil {
@@ -171,13 +234,49 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
+	if payload.Tags != nil {
+		if err := validateTags(*payload.Tags); err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+		post.Tags = *payload.Tags
+	}
+
+	if payload.Status != nil {
+		post.Status = *payload.Status
+		post.PublishAt = nil
+	}
+
+	if payload.PublishAt != nil {
+		if post.Status == store.PostStatusPublished {
+			app.badRequestError(w, r, fmt.Errorf("publish_at can only be set on drafts"))
+			return
+		}
+		if err := schedulePost(post, *payload.PublishAt); err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
 
 	post, err := app.store.Posts.Update(r.Context(), post)
 	if err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrPostAlreadyPublished):
+			app.conflictError(w, r, err)
+		case errors.Is(err, store.ErrVersionConflict):
+			app.preconditionFailedError(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	app.cacheInvalidator.Invalidate(r.Context(), cache.KindPost, post.ID)
+	app.publishPostEvents(r.Context(), post, !wasPublished)
+
+	setETag(w, post.Version)
 	if err := app.writeResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -196,7 +295,13 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 
 		ctx := r.Context()
 
-		post, err := app.store.Posts.GetById(ctx, id)
+		// las lecturas usan el cache; las escrituras necesitan la version actual para el If-Match
+		var post *store.Post
+		if r.Method == http.MethodGet {
+			post, err = app.getPostFromCache(ctx, id)
+		} else {
+			post, err = app.store.Posts.GetById(ctx, id)
+		}
 		if err != nil {
 			switch {
 			case errors.Is(err, store.ErrNotFound):
@@ -207,13 +312,89 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			return
 		} // obtenemos el post por id
 
+		// los drafts y posts programados no existen para los demas usuarios
+		if user := getUserFromCtx(ctx); user == nil || !post.IsVisibleTo(user.ID) {
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// publishPostEvents avisa a los usuarios mencionados en el post y, si se acaba de publicar, a los
+// seguidores del autor. Las menciones de un draft se avisan recien cuando se publica.
+func (app *application) publishPostEvents(ctx context.Context, post *store.Post, justPublished bool) {
+	if post.Status != store.PostStatusPublished {
+		return
+	}
+	app.events.Publish(ctx, events.Mentions(post.UserID, post.ID, 0, post.MentionedUserIDs)...)
+
+	if justPublished {
+		app.events.Publish(ctx, events.Event{Type: events.TypePostPublished, ActorID: post.UserID, PostID: post.ID})
+	}
+}
+
 func getPostFromCtx(ctx context.Context) *store.Post {
 	post, _ := ctx.Value(postCtx).(*store.Post)
 
 	return post
 } // obtenemos el post del contexto
+
+// schedulePost programa la publicacion del post. publish_at tiene que estar en el futuro.
+func schedulePost(post *store.Post, publishAt time.Time) error {
+	if !publishAt.After(time.Now()) {
+		return fmt.Errorf("publish_at must be in the future")
+	}
+
+	post.Status = store.PostStatusScheduled
+	post.PublishAt = &publishAt
+	return nil
+}
+
+// GetDrafts godoc
+//
+//	@Summary		List your drafts
+//	@Description	List the drafts and scheduled posts of the current user
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			limit	query		int			false	"Limit"
+//	@Param			offset	query		int			false	"Offset"
+//	@Param			sort	query		string		false	"Sort by updated_at, asc or desc"
+//	@Success		200		{array}		store.Post	"Drafts"
+//	@Failure		400		{object}	error		"Bad request"
+//	@Failure		500		{object}	error		"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/drafts [get]
+func (app *application) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedQuery{ // default values
+		Limit:  10,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.ParseURLParams(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r.Context())
+
+	drafts, err := app.store.Posts.GetDrafts(r.Context(), user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, drafts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
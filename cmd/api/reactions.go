@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/marceterrone10/social/internal/events"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/store/cache"
+)
+
+type ReactPayload struct {
+	Kind string `json:"kind" validate:"required,oneof=like love laugh wow sad angry"`
+}
+
+// ReactToPost godoc
+//
+//	@Summary		React to a post
+//	@Description	Set the current user's reaction to a published post (one of like, love, laugh, wow, sad, angry). A new reaction replaces the previous one.
+//	@Tags			Posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int				true	"Post ID"
+//	@Param			payload	body	ReactPayload	true	"Reaction payload"
+//	@Success		204		"Reaction saved"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/reactions [put]
+func (app *application) reactToPostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	post := getPostFromCtx(ctx)
+	user := getUserFromCtx(ctx)
+
+	var payload ReactPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if post.Status != store.PostStatusPublished {
+		app.badRequestError(w, r, errors.New("only published posts can receive reactions"))
+		return
+	}
+
+	created, err := app.store.Reactions.Set(ctx, user.ID, post.ID, strings.ToLower(payload.Kind))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// cambiar el tipo de reaccion no vuelve a notificar
+	if !created {
+		app.cacheInvalidator.Invalidate(ctx, cache.KindPost, post.ID)
+	} else {
+		app.events.Publish(ctx, events.Event{
+			Type:    events.TypeReaction,
+			ActorID: user.ID,
+			UserID:  post.UserID,
+			PostID:  post.ID,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveReaction godoc
+//
+//	@Summary		Remove a reaction
+//	@Description	Remove the current user's reaction to a post
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			id	path	int	true	"Post ID"
+//	@Success		204	"Reaction removed"
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/reactions [delete]
+func (app *application) removeReactionHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	post := getPostFromCtx(ctx)
+	user := getUserFromCtx(ctx)
+
+	if err := app.store.Reactions.Delete(ctx, user.ID, post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.cacheInvalidator.Invalidate(ctx, cache.KindPost, post.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/marceterrone10/social/internal/events"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/store/cache"
+)
+
+// Repost godoc
+//
+//	@Summary		Repost a post
+//	@Description	Share a published post with your followers. Reposting the same post twice has no effect.
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			id	path	int	true	"Post ID"
+//	@Success		204	"Post reposted"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/repost [put]
+func (app *application) repostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	post := getPostFromCtx(ctx)
+	user := getUserFromCtx(ctx)
+
+	if post.Status != store.PostStatusPublished {
+		app.badRequestError(w, r, fmt.Errorf("only published posts can be reposted"))
+		return
+	}
+
+	if err := app.store.Reposts.Create(ctx, user.ID, post.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.events.Publish(ctx, events.Event{Type: events.TypeRepost, ActorID: user.ID, UserID: post.UserID, PostID: post.ID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UndoRepost godoc
+//
+//	@Summary		Undo a repost
+//	@Description	Remove the current user's repost of a post
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			id	path	int	true	"Post ID"
+//	@Success		204	"Repost removed"
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/repost [delete]
+func (app *application) undoRepostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	post := getPostFromCtx(ctx)
+	user := getUserFromCtx(ctx)
+
+	if err := app.store.Reposts.Delete(ctx, user.ID, post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.cacheInvalidator.Invalidate(ctx, cache.KindPost, post.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// attachQuotes carga los posts citados por los quotes. Si el original ya no existe queda un tombstone.
+func (app *application) attachQuotes(ctx context.Context, posts []*store.Post) error {
+	ids := []int64{}
+	for _, p := range posts {
+		if p.QuotedPostID != nil {
+			ids = append(ids, *p.QuotedPostID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	originals, err := app.store.Posts.GetByIds(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		if p.QuotedPostID != nil {
+			p.QuotedPost = store.NewQuotedPost(*p.QuotedPostID, originals[*p.QuotedPostID])
+		}
+	}
+	return nil
+}
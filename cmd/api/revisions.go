@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/store/cache"
+	"github.com/marceterrone10/social/internal/textdiff"
+)
+
+// GetPostRevisions godoc
+//
+//	@Summary		List the revisions of a post
+//	@Description	List every stored revision of a post with its editor, newest first
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		200	{array}		store.PostRevision
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/revisions [get]
+func (app *application) getPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r.Context())
+
+	revisions, err := app.store.Revisions.GetByPostId(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, revisions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type RevisionDiff struct {
+	From    int             `json:"from"`
+	To      int             `json:"to"`
+	Title   []textdiff.Line `json:"title"`
+	Content []textdiff.Line `json:"content"`
+}
+
+// GetPostRevisionDiff godoc
+//
+//	@Summary		Diff two revisions of a post
+//	@Description	Line-level diff of the title and content between two revisions
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			id		path		int	true	"Post ID"
+//	@Param			from	query		int	true	"Base revision"
+//	@Param			to		query		int	true	"Target revision"
+//	@Success		200		{object}	RevisionDiff
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/revisions/diff [get]
+func (app *application) getPostRevisionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r.Context())
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil || from < 1 {
+		app.badRequestError(w, r, fmt.Errorf("invalid 'from' revision"))
+		return
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil || to < 1 {
+		app.badRequestError(w, r, fmt.Errorf("invalid 'to' revision"))
+		return
+	}
+
+	ctx := r.Context()
+
+	revisions := make([]*store.PostRevision, 2)
+	for i, n := range []int{from, to} {
+		rev, err := app.store.Revisions.Get(ctx, post.ID, n)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, fmt.Errorf("revision %d: %w", n, err))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		revisions[i] = rev
+	}
+
+	diff := RevisionDiff{
+		From:    from,
+		To:      to,
+		Title:   textdiff.Lines(revisions[0].Title, revisions[1].Title),
+		Content: textdiff.Lines(revisions[0].Content, revisions[1].Content),
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, diff); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RevertPostRevision godoc
+//
+//	@Summary		Revert a post to a previous revision
+//	@Description	Restore the title, content and tags of a revision. The revert is stored as a new revision. Allowed for the author and moderators. The If-Match header must carry the post ETag.
+//	@Tags			Posts
+//	@Produce		json
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			revision	path		int		true	"Revision number"
+//	@Param			If-Match	header		string	true	"ETag of the version being reverted"
+//	@Success		200			{object}	store.Post
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/revisions/{revision}/revert [post]
+func (app *application) revertPostRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r.Context())
+
+	number, err := strconv.Atoi(chi.URLParam(r, "revision"))
+	if err != nil || number < 1 {
+		app.badRequestError(w, r, fmt.Errorf("invalid revision"))
+		return
+	}
+
+	// igual que el PATCH, el revert no puede pisar una edicion que el cliente no vio
+	version, ok := app.checkIfMatch(w, r, post.Version)
+	if !ok {
+		return
+	}
+	post.Version = version
+
+	ctx := r.Context()
+
+	rev, err := app.store.Revisions.Get(ctx, post.ID, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	post.Title = rev.Title
+	post.Content = rev.Content
+	post.Tags = rev.Tags
+
+	post, err = app.store.Posts.Update(ctx, post)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrVersionConflict):
+			app.preconditionFailedError(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.cacheInvalidator.Invalidate(ctx, cache.KindPost, post.ID)
+	app.publishPostEvents(ctx, post, false)
+
+	setETag(w, post.Version)
+	if err := app.writeResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
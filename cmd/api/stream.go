@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/stream"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	streamHeartbeatInterval = 15 * time.Second
+	streamWriteTimeout      = 10 * time.Second
+	// cantidad maxima de posts cuya actividad se puede seguir en una conexion
+	streamMaxPosts = 20
+)
+
+// StreamSSE godoc
+//
+//	@Summary		Real-time event stream (Server-Sent Events)
+//	@Description	Pushes new feed items, notifications and, for the posts listed in posts, new comments. Send Last-Event-ID (header or last_event_id query) to resume after a disconnect.
+//	@Description	Browsers that cannot set headers can pass the token as access_token.
+//	@Tags			Stream
+//	@Produce		text/event-stream
+//	@Param			posts			query	string	false	"Comma separated post IDs to follow"
+//	@Param			last_event_id	query	string	false	"ID of the last event received"
+//	@Success		200				"Event stream"
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream [get]
+func (app *application) streamSSEHandler(w http.ResponseWriter, r *http.Request) {
+	topics, postIDs, lastID, err := streamParams(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := app.checkPostsVisible(r.Context(), postIDs); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// la conexion dura mas que el WriteTimeout del server: cada escritura tiene su propio plazo, asi un
+	// cliente que deja de leer no bloquea el handler y su suscripcion para siempre
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(format string, args ...any) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	send := func(msg stream.Message) error {
+		return write("id: %s\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, msg.Data)
+	}
+	heartbeat := func() error { return write(": heartbeat\n\n") }
+
+	if err := write("retry: 3000\n\n"); err != nil {
+		return
+	}
+
+	// si una escritura falla runStream vuelve y se descarta el cliente
+	err = app.runStream(r.Context(), topics, lastID, send, heartbeat)
+	if err == errStreamOverflow {
+		// el cliente se reconecta solo con Last-Event-ID y recupera lo que se perdio
+		write("event: overflow\ndata: {}\n\n")
+	}
+}
+
+// StreamWebSocket godoc
+//
+//	@Summary		Real-time event stream (WebSocket)
+//	@Description	Same events as /stream, sent as JSON messages {id, topic, type, data}. Heartbeats are sent as {"type":"heartbeat"}.
+//	@Tags			Stream
+//	@Param			posts			query	string	false	"Comma separated post IDs to follow"
+//	@Param			last_event_id	query	string	false	"ID of the last event received"
+//	@Success		101				"Switching protocols"
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream/ws [get]
+func (app *application) streamWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	topics, postIDs, lastID, err := streamParams(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := app.checkPostsVisible(r.Context(), postIDs); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	server := websocket.Server{Handler: func(conn *websocket.Conn) {
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		// el cliente no manda nada, solo leemos para enterarnos cuando cierra la conexion
+		conn.SetReadDeadline(time.Time{})
+		go func() {
+			defer cancel()
+			var discard string
+			for websocket.Message.Receive(conn, &discard) == nil {
+			}
+		}()
+
+		write := func(v any) error {
+			// un cliente que no lee bloquea la escritura, con el deadline se corta en vez de acumular
+			if err := conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+				return err
+			}
+			return websocket.JSON.Send(conn, v)
+		}
+		send := func(msg stream.Message) error { return write(msg) }
+		heartbeat := func() error { return write(map[string]string{"type": "heartbeat"}) }
+
+		if err := app.runStream(ctx, topics, lastID, send, heartbeat); err == errStreamOverflow {
+			write(map[string]string{"type": "overflow"})
+		}
+	}}
+	server.ServeHTTP(w, r)
+}
+
+var errStreamOverflow = fmt.Errorf("stream subscriber too slow")
+
+// runStream manda los mensajes pendientes desde lastID y despues los nuevos hasta que se corte la conexion
+func (app *application) runStream(ctx context.Context, topics []string, lastID string, send func(stream.Message) error, heartbeat func() error) error {
+	// al apagar el server se cortan las conexiones, Shutdown no las espera
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(app.streamsCtx, cancel)
+	defer stop()
+
+	// primero la suscripcion y despues el historial, asi no se pierde nada en el medio
+	sub := app.streamBroker.Subscribe(topics...)
+	defer sub.Close()
+
+	// ultimo ID enviado por topic, para no repetir los mensajes que llegan por las dos vias
+	sent := map[string]string{}
+
+	if lastID != "" {
+		var pending []stream.Message
+		for _, t := range topics {
+			msgs, err := app.streamBroker.Since(ctx, t, lastID)
+			if err != nil {
+				app.logger.Errorw("error loading stream history", "topic", t, "error", err)
+				return err
+			}
+			pending = append(pending, msgs...)
+		}
+		slices.SortFunc(pending, func(a, b stream.Message) int { return stream.CompareIDs(a.ID, b.ID) })
+
+		for _, msg := range pending {
+			if err := send(msg); err != nil {
+				return err
+			}
+			sent[msg.Topic] = msg.ID
+		}
+	}
+
+	ticker := time.NewTicker(streamHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sub.Dropped:
+			return errStreamOverflow
+		case <-ticker.C:
+			if err := heartbeat(); err != nil {
+				return err
+			}
+		case msg := <-sub.C:
+			if last, ok := sent[msg.Topic]; ok && stream.CompareIDs(msg.ID, last) <= 0 {
+				continue
+			}
+			if err := send(msg); err != nil {
+				return err
+			}
+			sent[msg.Topic] = msg.ID
+		}
+	}
+}
+
+// streamParams arma los topics de la conexion: el del usuario y los de los posts pedidos
+func streamParams(r *http.Request) ([]string, []int64, string, error) {
+	user := getUserFromCtx(r.Context())
+	topics := []string{stream.UserTopic(user.ID)}
+	postIDs := []int64{}
+
+	if posts := r.URL.Query().Get("posts"); posts != "" {
+		ids := strings.Split(posts, ",")
+		if len(ids) > streamMaxPosts {
+			return nil, nil, "", fmt.Errorf("at most %d posts can be followed", streamMaxPosts)
+		}
+		for _, s := range ids {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil || id < 1 {
+				return nil, nil, "", fmt.Errorf("invalid post id %q", s)
+			}
+			topics = append(topics, stream.PostTopic(id))
+			postIDs = append(postIDs, id)
+		}
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	if lastID != "" && !stream.ValidID(lastID) {
+		return nil, nil, "", fmt.Errorf("invalid Last-Event-ID")
+	}
+
+	return topics, postIDs, lastID, nil
+}
+
+// checkPostsVisible devuelve ErrNotFound si alguno de los posts no existe o es un draft o programado
+// de otro usuario, asi no se puede seguir en vivo un post que todavia no se publico
+func (app *application) checkPostsVisible(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	posts, err := app.store.Posts.GetByIds(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	user := getUserFromCtx(ctx)
+	for _, id := range ids {
+		if post, ok := posts[id]; !ok || !post.IsVisibleTo(user.ID) {
+			return fmt.Errorf("post %d: %w", id, store.ErrNotFound)
+		}
+	}
+	return nil
+}
+
+// streamTokenMiddleware permite mandar el token como access_token, porque EventSource y WebSocket
+// en el navegador no pueden mandar el header Authorization
+func (app *application) streamTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			if token := r.URL.Query().Get("access_token"); token != "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
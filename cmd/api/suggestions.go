@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/marceterrone10/social/internal/store"
+)
+
+const suggestionsDefaultLimit = 10
+
+// GetSuggestions godoc
+//
+//	@Summary		Who to follow
+//	@Description	Users the current user may want to follow: followed by people they follow (with the number of mutual followers), engaging with the same posts, or posting in the same tags. Followed, blocked, suspended and dismissed users are excluded.
+//	@Tags			Users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Number of suggestions (max SUGGESTIONS_POOL_SIZE, 50 by default)"
+//	@Success		200		{array}		store.SuggestionCandidate
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/suggestions [get]
+func (app *application) getSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query().Get("limit"), suggestionsDefaultLimit, app.config.suggestions.PoolSize)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	suggestions, err := app.suggestions.Suggestions(ctx, getUserFromCtx(ctx).ID, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, suggestions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DismissSuggestion godoc
+//
+//	@Summary		Dismiss a suggestion
+//	@Description	The user will not be suggested again
+//	@Tags			Users
+//	@Param			suggestedID	path	int	true	"Suggested user ID"
+//	@Success		204			"Suggestion dismissed"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/suggestions/{suggestedID} [delete]
+func (app *application) dismissSuggestionHandler(w http.ResponseWriter, r *http.Request) {
+	suggestedID, err := strconv.ParseInt(chi.URLParam(r, "suggestedID"), 10, 64)
+	if err != nil || suggestedID < 1 {
+		app.badRequestError(w, r, fmt.Errorf("invalid user id"))
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.suggestions.Dismiss(ctx, getUserFromCtx(ctx).ID, suggestedID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
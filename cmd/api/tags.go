@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/marceterrone10/social/internal/richtext"
+	"github.com/marceterrone10/social/internal/store"
+)
+
+// GetTagPosts godoc
+//
+//	@Summary		Get the timeline of a hashtag
+//	@Description	Published posts tagged with the hashtag, newest first. The tag is case insensitive and may include the leading #.
+//	@Tags			Tags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Hashtag"
+//	@Param			limit	query		int		false	"Limit the number of posts returned"
+//	@Param			offset	query		int		false	"Offset the number of posts returned"
+//	@Param			sort	query		string	false	"Sort the posts by published_at in ascending or descending order"
+//	@Success		200		{array}		store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := richtext.NormalizeTag(chi.URLParam(r, "tag"))
+	if !ok {
+		app.badRequestError(w, r, fmt.Errorf("invalid tag"))
+		return
+	}
+
+	fq := store.PaginatedQuery{
+		Limit:  10,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.ParseURLParams(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	posts, err := app.store.Posts.GetByTag(ctx, tag, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.attachToFeed(ctx, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// validateTags chequea que los tags enviados por el autor sean hashtags validos
+func validateTags(tags []string) error {
+	for _, t := range tags {
+		if _, ok := richtext.NormalizeTag(t); !ok {
+			return fmt.Errorf("invalid tag %q: only letters, numbers and _ are allowed", t)
+		}
+	}
+	return nil
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/marceterrone10/social/internal/events"
 	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/store/cache"
 )
 
 type userKey string
@@ -37,10 +40,16 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	user, err := app.getUserFromCache(ctx, userID) // obtenemos el usuario de la cache
 	if err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	setETag(w, user.Version)
 	if err := app.writeResponse(w, http.StatusOK, user); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -63,7 +72,7 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 //	@Router			/users/{userID}/follow [put]
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
 	followerUser := getUserFromCtx(r.Context())
-	followedID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	followedID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
 		app.badRequestError(w, r, err)
 		return
@@ -77,6 +86,12 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	app.events.Publish(ctx, events.Event{
+		Type:    events.TypeFollow,
+		ActorID: followerUser.ID,
+		UserID:  followedID,
+	})
+
 	if err := app.writeResponse(w, http.StatusOK, "User followed successfully"); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -100,7 +115,7 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 //	@Router			/users/{userID}/unfollow [put]
 func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
 	followerUser := getUserFromCtx(r.Context())
-	followedID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	followedID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
 		app.badRequestError(w, r, err)
 		return
@@ -114,6 +129,12 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	app.events.Publish(ctx, events.Event{
+		Type:    events.TypeUnfollow,
+		ActorID: followerUser.ID,
+		UserID:  followedID,
+	})
+
 	if err := app.writeResponse(w, http.StatusOK, "User unfollowed successfully"); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -148,3 +169,227 @@ func getUserFromCtx(ctx context.Context) *store.User {
 	user, _ := ctx.Value(userCtx).(*store.User)
 	return user
 }
+
+type UpdateUserProfilePayload struct {
+	Username string `json:"username" validate:"required,min=3,max=255"`
+}
+
+// UpdateUserProfile godoc
+//
+//	@Summary		Update a user's profile
+//	@Description	Update the profile of a user. Allowed for the user and admins. The If-Match header must carry the ETag returned by GET /users/{id}.
+//	@Tags			Users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int							true	"User ID"
+//	@Param			If-Match	header		string						true	"ETag of the version being edited"
+//	@Param			payload		body		UpdateUserProfilePayload	true	"Profile payload"
+//	@Success		200			{object}	store.User
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id} [patch]
+func (app *application) updateUserProfileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || userID < 1 {
+		app.badRequestError(w, r, fmt.Errorf("invalid user id"))
+		return
+	}
+
+	ctx := r.Context()
+
+	current := getUserFromCtx(ctx)
+	if current.ID != userID {
+		allowed, err := app.checkRole(ctx, current, "admin")
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	// la version del usuario cacheado puede estar vieja, la leemos de la DB
+	user, err := app.store.Users.GetById(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	version, ok := app.checkIfMatch(w, r, user.Version)
+	if !ok {
+		return
+	}
+
+	var payload UpdateUserProfilePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user.Username = payload.Username
+	user.Version = version
+
+	if err := app.store.Users.UpdateProfile(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, store.ErrVersionConflict):
+			app.preconditionFailedError(w, r, err)
+		case errors.Is(err, store.ErrDuplicateUsername):
+			app.conflictError(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.cacheInvalidator.Invalidate(ctx, cache.KindUser, userID)
+
+	setETag(w, user.Version)
+	if err := app.writeResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type UpdateUserRolePayload struct {
+	Role string `json:"role" validate:"required,oneof=user moderator admin"`
+}
+
+// UpdateUserRole godoc
+//
+//	@Summary		Change a user's role
+//	@Description	Change the role of a user (admin only). The change is recorded in the audit log.
+//	@Tags			Users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"User ID"
+//	@Param			payload	body		UpdateUserRolePayload	true	"Role payload"
+//	@Success		204		{string}	string					"Role updated"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/role [patch]
+func (app *application) updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || userID < 1 {
+		app.badRequestError(w, r, fmt.Errorf("invalid user id"))
+		return
+	}
+
+	var payload UpdateUserRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.UpdateRole(ctx, userID, payload.Role); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrRoleNotFound):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// el usuario cacheado tiene el rol viejo
+	app.cacheInvalidator.Invalidate(ctx, cache.KindUser, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type UpdatePasswordPayload struct {
+	CurrentPassword string `json:"current_password" validate:"required,min=8,max=72"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+// UpdatePassword godoc
+//
+//	@Summary		Change the current user's password
+//	@Description	Change the password of the authenticated user. The change is recorded in the audit log.
+//	@Tags			Users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdatePasswordPayload	true	"Password payload"
+//	@Success		204		{string}	string					"Password updated"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/password [put]
+func (app *application) updatePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var payload UpdatePasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	// el usuario cacheado no tiene el hash del password, lo buscamos en la DB
+	user, err := app.store.Users.GetById(ctx, getUserFromCtx(ctx).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := user.Password.Compare(payload.CurrentPassword); err != nil {
+		app.unauthorizedError(w, r, fmt.Errorf("Invalid credentials"))
+		return
+	}
+
+	if err := user.Password.Set(payload.NewPassword); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.UpdatePassword(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// UpdatePassword sube la version, el ETag cacheado ya no sirve
+	app.cacheInvalidator.Invalidate(ctx, cache.KindUser, user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id bigserial PRIMARY KEY,
+    actor_id bigint,
+    action varchar(100) NOT NULL,
+    target_type varchar(50) NOT NULL,
+    target_id bigint NOT NULL,
+    diff jsonb NOT NULL DEFAULT '{}'::jsonb,
+    ip varchar(64) NOT NULL DEFAULT '',
+    request_id varchar(255) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update_delete
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
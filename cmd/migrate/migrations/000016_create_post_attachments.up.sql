@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS post_attachments (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    position int NOT NULL,
+    kind varchar(20) NOT NULL,
+    mime_type varchar(100) NOT NULL,
+    storage_key text NOT NULL,
+    url text NOT NULL,
+    size_bytes bigint NOT NULL,
+    width int NOT NULL DEFAULT 0,
+    height int NOT NULL DEFAULT 0,
+    alt_text varchar(1000) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    UNIQUE (post_id, position)
+);
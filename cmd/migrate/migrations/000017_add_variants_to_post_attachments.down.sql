@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_post_attachments_pending;
+
+ALTER TABLE post_attachments
+DROP COLUMN IF EXISTS variants,
+DROP COLUMN IF EXISTS blurhash,
+DROP COLUMN IF EXISTS processing_error,
+DROP COLUMN IF EXISTS processing_started_at,
+DROP COLUMN IF EXISTS processing_status;
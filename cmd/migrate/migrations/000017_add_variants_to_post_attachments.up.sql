@@ -0,0 +1,9 @@
+ALTER TABLE post_attachments
+ADD COLUMN processing_status varchar(20) NOT NULL DEFAULT 'pending',
+ADD COLUMN processing_started_at timestamp(0) with time zone,
+ADD COLUMN processing_error text NOT NULL DEFAULT '',
+ADD COLUMN blurhash varchar(100) NOT NULL DEFAULT '',
+ADD COLUMN variants jsonb NOT NULL DEFAULT '[]'::jsonb;
+
+CREATE INDEX IF NOT EXISTS idx_post_attachments_pending ON post_attachments (created_at)
+WHERE processing_status IN ('pending', 'processing');
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_posts_user_status;
+DROP INDEX IF EXISTS idx_posts_scheduled;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS published_at,
+DROP COLUMN IF EXISTS publish_at,
+DROP COLUMN IF EXISTS status;
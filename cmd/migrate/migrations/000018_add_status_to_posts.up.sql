@@ -0,0 +1,9 @@
+ALTER TABLE posts
+ADD COLUMN status varchar(20) NOT NULL DEFAULT 'published',
+ADD COLUMN publish_at timestamp(0) with time zone,
+ADD COLUMN published_at timestamp(0) with time zone;
+
+UPDATE posts SET published_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled ON posts (publish_at) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_posts_user_status ON posts (user_id, status);
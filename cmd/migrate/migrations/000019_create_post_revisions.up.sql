@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    revision int NOT NULL,
+    title text NOT NULL,
+    content text NOT NULL,
+    tags VARCHAR(100)[],
+    editor_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    UNIQUE (post_id, revision)
+);
+
+ALTER TABLE posts
+ADD COLUMN edited_at timestamp(0) with time zone;
+
+-- la version actual de cada post existente es su primera revision
+INSERT INTO post_revisions (post_id, revision, title, content, tags, editor_id, created_at)
+SELECT id, 1, title, content, tags, user_id, created_at FROM posts;
@@ -0,0 +1,6 @@
+ALTER TABLE users DROP COLUMN IF EXISTS version;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE comments DROP COLUMN IF EXISTS version;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS version;
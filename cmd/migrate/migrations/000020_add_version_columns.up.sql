@@ -0,0 +1,10 @@
+-- version para control de concurrencia optimista: se incrementa en cada update
+ALTER TABLE posts
+ADD COLUMN version int NOT NULL DEFAULT 1;
+
+ALTER TABLE comments
+ADD COLUMN version int NOT NULL DEFAULT 1,
+ADD COLUMN updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+
+ALTER TABLE users
+ADD COLUMN version int NOT NULL DEFAULT 1;
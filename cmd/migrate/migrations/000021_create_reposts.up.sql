@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS reposts (
+    user_id bigint NOT NULL,
+    post_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, post_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reposts_post_id ON reposts (post_id);
+
+-- sin FK a proposito: si se borra el original el quote queda apuntando a un post que no existe
+-- y se muestra como tombstone
+ALTER TABLE posts
+ADD COLUMN quoted_post_id bigint;
+
+CREATE INDEX IF NOT EXISTS idx_posts_quoted_post_id ON posts (quoted_post_id) WHERE quoted_post_id IS NOT NULL;
@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS hashtags (
+    id bigserial PRIMARY KEY,
+    name varchar(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS post_hashtags (
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    hashtag_id bigint NOT NULL REFERENCES hashtags(id) ON DELETE CASCADE,
+
+    PRIMARY KEY (post_id, hashtag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_hashtags_hashtag_id ON post_hashtags (hashtag_id);
+
+CREATE TABLE IF NOT EXISTS comment_hashtags (
+    comment_id bigint NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    hashtag_id bigint NOT NULL REFERENCES hashtags(id) ON DELETE CASCADE,
+
+    PRIMARY KEY (comment_id, hashtag_id)
+);
+
+-- comment_id es NULL cuando la mencion esta en el post
+CREATE TABLE IF NOT EXISTS mentions (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id bigint REFERENCES comments(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    author_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mentions_unique ON mentions (post_id, COALESCE(comment_id, 0), user_id);
+CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions (user_id);
+
+-- los tags existentes pasan a la tabla normalizada
+INSERT INTO hashtags (name)
+SELECT DISTINCT lower(ltrim(t.name, '#'))
+FROM posts p CROSS JOIN LATERAL unnest(p.tags) AS t(name)
+WHERE ltrim(t.name, '#') <> ''
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO post_hashtags (post_id, hashtag_id)
+SELECT DISTINCT p.id, h.id
+FROM posts p CROSS JOIN LATERAL unnest(p.tags) AS t(name)
+JOIN hashtags h ON h.name = lower(ltrim(t.name, '#'))
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS post_reactions (
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    kind varchar(20) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_reactions_post_id ON post_reactions (post_id);
+
+-- una notificacion agrupa los eventos del mismo tipo sobre el mismo objeto ("X y 4 mas reaccionaron a tu post")
+-- mientras no se lea. Los actores de cada grupo estan en notification_actors.
+CREATE TABLE IF NOT EXISTS notifications (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type varchar(20) NOT NULL,
+    group_key varchar(100) NOT NULL,
+    post_id bigint REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id bigint REFERENCES comments(id) ON DELETE CASCADE,
+    actor_count int NOT NULL DEFAULT 0,
+    read_at timestamp with time zone,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp with time zone NOT NULL DEFAULT NOW()
+);
+
+-- solo puede haber un grupo sin leer por clave, al leerlo el proximo evento abre un grupo nuevo
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_open_group ON notifications (user_id, group_key) WHERE read_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_notifications_user_updated ON notifications (user_id, updated_at DESC, id DESC);
+
+CREATE TABLE IF NOT EXISTS notification_actors (
+    notification_id bigint NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    actor_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (notification_id, actor_id)
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type varchar(20) NOT NULL,
+    enabled boolean NOT NULL,
+
+    PRIMARY KEY (user_id, type)
+);
@@ -0,0 +1,37 @@
+-- quien puede empezar una conversacion con el usuario: 'mutuals' (solo seguidores mutuos) o 'everyone'
+ALTER TABLE users ADD COLUMN IF NOT EXISTS dm_policy varchar(20) NOT NULL DEFAULT 'mutuals';
+
+CREATE TABLE IF NOT EXISTS conversations (
+    id bigserial PRIMARY KEY,
+    is_group boolean NOT NULL DEFAULT false,
+    title varchar(100),
+    -- "<menor id>:<mayor id>" en las conversaciones 1:1, asi hay una sola por par de usuarios
+    direct_key varchar(50) UNIQUE,
+    created_by bigint REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+    -- fecha del ultimo mensaje (o de creacion), ordena la bandeja de entrada
+    updated_at timestamp with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS conversation_participants (
+    conversation_id bigint NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- confirmacion de lectura: el ultimo mensaje que el usuario leyo
+    last_read_message_id bigint,
+    last_read_at timestamp with time zone,
+    joined_at timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_participants_user_id ON conversation_participants (user_id);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id bigserial PRIMARY KEY,
+    conversation_id bigint NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content text NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages (conversation_id, id DESC);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_posts_user_published;
+DROP INDEX IF EXISTS idx_followers_follower_id;
@@ -0,0 +1,5 @@
+-- seguidores de un usuario (fan-out del timeline y conteo para las cuentas con muchos seguidores)
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id ON followers (follower_id);
+
+-- ultimos posts publicados de un autor (backfill al seguir y merge de las cuentas con muchos seguidores)
+CREATE INDEX IF NOT EXISTS idx_posts_user_published ON posts (user_id, published_at DESC) WHERE status = 'published';
@@ -0,0 +1,12 @@
+-- un usuario suspendido por moderacion sigue existiendo pero su contenido no aparece en explore
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at timestamp with time zone;
+
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks (blocked_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_posts_published_at;
+DROP INDEX IF EXISTS idx_reposts_created_at;
+DROP INDEX IF EXISTS idx_comments_created_at;
+DROP INDEX IF EXISTS idx_post_reactions_created_at;
@@ -0,0 +1,5 @@
+-- agregacion de trending desde Postgres por ventana de tiempo
+CREATE INDEX IF NOT EXISTS idx_post_reactions_created_at ON post_reactions (created_at);
+CREATE INDEX IF NOT EXISTS idx_comments_created_at ON comments (created_at);
+CREATE INDEX IF NOT EXISTS idx_reposts_created_at ON reposts (created_at);
+CREATE INDEX IF NOT EXISTS idx_posts_published_at ON posts (published_at) WHERE status = 'published';
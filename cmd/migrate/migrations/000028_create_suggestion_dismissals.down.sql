@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_comments_post_id;
+
+DROP TABLE IF EXISTS suggestion_dismissals;
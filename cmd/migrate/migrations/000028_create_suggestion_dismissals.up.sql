@@ -0,0 +1,11 @@
+-- sugerencias de "a quien seguir" descartadas por el usuario, no se vuelven a sugerir
+CREATE TABLE IF NOT EXISTS suggestion_dismissals (
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    suggested_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, suggested_id)
+);
+
+-- co-engagement: quienes comentaron los mismos posts
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments (post_id);
@@ -0,0 +1,23 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Storage es la interfaz que implementan los backends de almacenamiento de archivos (filesystem local, S3, etc)
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+type Config struct {
+	Backend string
+	Local   LocalConfig
+	S3      S3Config
+}
@@ -0,0 +1,249 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type S3Config struct {
+	Endpoint     string
+	Region       string
+	Bucket       string
+	AccessKey    string
+	SecretKey    string
+	PublicURL    string
+	UsePathStyle bool
+}
+
+// S3Storage habla con cualquier servicio compatible con S3 (AWS, MinIO, R2...) firmando los requests con SigV4
+type S3Storage struct {
+	cfg    S3Config
+	client *http.Client
+}
+
+func NewS3Storage(cfg S3Config) *S3Storage {
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	cfg.PublicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	return &S3Storage{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	// SigV4 necesita el hash del payload, asi que lo leemos completo
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", contentType)
+
+	res, err := s.do(ctx, http.MethodPut, key, body, headers)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return s.responseError(res)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res.Body, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer res.Body.Close()
+		return nil, s.responseError(res)
+	}
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return s.responseError(res)
+	}
+	return nil
+}
+
+func (s *S3Storage) URL(key string) string {
+	if s.cfg.PublicURL != "" {
+		return s.cfg.PublicURL + "/" + escapePath(key)
+	}
+	return s.objectURL(key).String()
+}
+
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u, _ := url.Parse(s.cfg.Endpoint)
+	if s.cfg.UsePathStyle {
+		u.Path = "/" + s.cfg.Bucket + "/" + key
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	// se manda el path codificado igual que en la firma
+	u.RawPath = escapePath(u.Path)
+	return u
+}
+
+func (s *S3Storage) do(ctx context.Context, method, key string, body []byte, headers http.Header) (*http.Response, error) {
+	u := s.objectURL(key)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	req.ContentLength = int64(len(body))
+
+	s.sign(req, u, body, time.Now().UTC())
+
+	return s.client.Do(req)
+}
+
+// sign agrega la firma AWS Signature Version 4 al request
+func (s *S3Storage) sign(req *http.Request, u *url.URL, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256Hex(body)
+	req.Header.Set("Host", u.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	names := []string{}
+	for k := range req.Header {
+		names = append(names, strings.ToLower(k))
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		// los valores van sin espacios de mas y, si el header se repite, separados por coma
+		values := req.Header.Values(name)
+		for i, v := range values {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(u.Path),
+		canonicalQuery(u.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func (s *S3Storage) responseError(res *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return fmt.Errorf("s3 returned status %d: %s", res.StatusCode, msg)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// escapePath codifica el path como lo espera SigV4, sin tocar las barras
+func escapePath(path string) string {
+	return uriEncode(path, false)
+}
+
+// canonicalQuery ordena los parametros por clave y valor, codificados con uriEncode (url.Values.Encode usa + para los espacios)
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int { return strings.Compare(uriEncode(a, true), uriEncode(b, true)) })
+
+	params := []string{}
+	for _, k := range keys {
+		vs := slices.Clone(values[k])
+		slices.Sort(vs)
+		for _, v := range vs {
+			params = append(params, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// uriEncode deja sin codificar solo los caracteres no reservados (A-Z, a-z, 0-9, -, ., _, ~), como pide SigV4
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Loader lee la configuracion de varias fuentes. Para cada clave el orden es:
+//  1. la variable de entorno (DB_ADDR)
+//  2. el archivo indicado en <clave>_FILE (DB_ADDR_FILE), para los secrets montados como archivo
+//  3. el archivo de configuracion (CONFIG_FILE), donde db.addr o DB_ADDR son la misma clave
+//  4. el valor por defecto
+//
+// Un valor invalido no cae en silencio al valor por defecto: se registra el error y se devuelven todos
+// juntos en Err, asi al arrancar se ven todos los problemas de una vez.
+type Loader struct {
+	file map[string]any
+	errs []error
+}
+
+// New crea el loader leyendo el archivo de CONFIG_FILE, si esta definido (.yaml, .yml o .toml)
+func New() (*Loader, error) {
+	l := &Loader{file: map[string]any{}}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		l.file = values
+	}
+
+	return l, nil
+}
+
+// lookup devuelve el valor crudo de la clave: un string, o un []string si en el archivo es una lista
+func (l *Loader) lookup(key string) (any, bool) {
+	if val, ok := os.LookupEnv(key); ok {
+		return val, true
+	}
+
+	if path, ok := os.LookupEnv(key + "_FILE"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s_FILE: %w", key, err))
+			return nil, false
+		}
+		return strings.TrimSpace(string(data)), true
+	}
+
+	val, ok := l.file[key]
+	return val, ok
+}
+
+// scalar devuelve el valor como string, registrando un error si en el archivo es una lista
+func (l *Loader) scalar(key string) (string, bool) {
+	val, ok := l.lookup(key)
+	if !ok {
+		return "", false
+	}
+	s, ok := val.(string)
+	if !ok {
+		l.errs = append(l.errs, fmt.Errorf("%s: expected a single value, got a list", key))
+		return "", false
+	}
+	return s, true
+}
+
+func (l *Loader) String(key, fallback string) string {
+	if val, ok := l.scalar(key); ok {
+		return val
+	}
+	return fallback
+}
+
+func (l *Loader) Int(key string, fallback int) int {
+	return parse(l, key, fallback, "an integer", strconv.Atoi)
+}
+
+func (l *Loader) Float(key string, fallback float64) float64 {
+	return parse(l, key, fallback, "a number", func(s string) (float64, error) {
+		return strconv.ParseFloat(s, 64)
+	})
+}
+
+func (l *Loader) Bool(key string, fallback bool) bool {
+	return parse(l, key, fallback, "a boolean", strconv.ParseBool)
+}
+
+// Duration acepta el formato de time.ParseDuration, por ejemplo 30s, 5m o 72h
+func (l *Loader) Duration(key string, fallback time.Duration) time.Duration {
+	return parse(l, key, fallback, "a duration (e.g. 30s, 5m, 72h)", time.ParseDuration)
+}
+
+// List acepta una lista separada por comas en el entorno o una lista en el archivo
+func (l *Loader) List(key string, fallback []string) []string {
+	val, ok := l.lookup(key)
+	if !ok {
+		return fallback
+	}
+
+	var items []string
+	switch v := val.(type) {
+	case []string:
+		items = v
+	case string:
+		items = strings.Split(v, ",")
+	}
+
+	list := []string{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func parse[T any](l *Loader, key string, fallback T, kind string, fn func(string) (T, error)) T {
+	val, ok := l.scalar(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := fn(strings.TrimSpace(val))
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not %s", key, val, kind))
+		return fallback
+	}
+	return parsed
+}
+
+// Check registra un error de validacion para la clave si ok es false
+func (l *Loader) Check(ok bool, key, format string, args ...any) {
+	if !ok {
+		l.errs = append(l.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+}
+
+// OneOf valida que el valor de la clave sea uno de los permitidos
+func (l *Loader) OneOf(key, value string, allowed ...string) {
+	l.Check(slices.Contains(allowed, value), key, "%q must be one of %s", value, strings.Join(allowed, ", "))
+}
+
+// Err devuelve todos los errores de carga y validacion juntos, o nil si no hubo
+func (l *Loader) Err() error {
+	if len(l.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration (%d errors):\n%w", len(l.errs), errors.Join(l.errs...))
+}
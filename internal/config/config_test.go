@@ -0,0 +1,254 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile escribe un archivo en un directorio temporal y devuelve su path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newLoader crea un loader con el archivo de configuracion dado, o sin archivo si esta vacio
+func newLoader(t *testing.T, name, content string) *Loader {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	if content != "" {
+		t.Setenv("CONFIG_FILE", writeFile(t, name, content))
+	}
+	l, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func ptr(s string) *string { return &s }
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    *string
+		secret string
+		file   string
+		want   string
+	}{
+		{name: "default", want: "fallback"},
+		{name: "file over default", file: "file", want: "file"},
+		{name: "secret file over config file", secret: "secret\n", file: "file", want: "secret"},
+		{name: "env over everything", env: ptr("env"), secret: "secret", file: "file", want: "env"},
+		{name: "empty env is still set", env: ptr(""), secret: "secret", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := ""
+			if tt.file != "" {
+				content = "test:\n  value: " + tt.file + "\n"
+			}
+			l := newLoader(t, "config.yaml", content)
+
+			if tt.env != nil {
+				t.Setenv("TEST_VALUE", *tt.env)
+			}
+			if tt.secret != "" {
+				t.Setenv("TEST_VALUE_FILE", writeFile(t, "secret", tt.secret))
+			}
+
+			if got := l.String("TEST_VALUE", "fallback"); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if err := l.Err(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSecretFileMissing(t *testing.T) {
+	l := newLoader(t, "", "")
+	t.Setenv("TEST_SECRET_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	if got := l.String("TEST_SECRET", "fallback"); got != "fallback" {
+		t.Errorf("String() = %q, want fallback", got)
+	}
+	err := l.Err()
+	if err == nil || !strings.Contains(err.Error(), "TEST_SECRET_FILE") {
+		t.Errorf("expected an error for TEST_SECRET_FILE, got %v", err)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+db:
+  addr: postgres://db
+  max_open_conns: 30
+rate_limiter:
+  time_frame: 10s
+  enabled: false
+ranking:
+  weights: [recency=1, tags=0.5]
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `
+[db]
+addr = "postgres://db"
+max_open_conns = 30
+
+[rate_limiter]
+time_frame = "10s"
+enabled = false
+
+[ranking]
+weights = ["recency=1", "tags=0.5"]
+`,
+		},
+		{
+			name: "flat keys",
+			file: "config.yml",
+			content: `
+DB_ADDR: postgres://db
+db_max_open_conns: 30
+RATE_LIMITER_TIME_FRAME: 10s
+rate_limiter_enabled: false
+RANKING_WEIGHTS: recency=1,tags=0.5
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLoader(t, tt.file, tt.content)
+
+			if got := l.String("DB_ADDR", ""); got != "postgres://db" {
+				t.Errorf("DB_ADDR = %q", got)
+			}
+			if got := l.Int("DB_MAX_OPEN_CONNS", 0); got != 30 {
+				t.Errorf("DB_MAX_OPEN_CONNS = %d", got)
+			}
+			if got := l.Duration("RATE_LIMITER_TIME_FRAME", 0); got != 10*time.Second {
+				t.Errorf("RATE_LIMITER_TIME_FRAME = %v", got)
+			}
+			if got := l.Bool("RATE_LIMITER_ENABLED", true); got {
+				t.Errorf("RATE_LIMITER_ENABLED = %v", got)
+			}
+			if got := l.List("RANKING_WEIGHTS", nil); !slices.Equal(got, []string{"recency=1", "tags=0.5"}) {
+				t.Errorf("RANKING_WEIGHTS = %q", got)
+			}
+			if err := l.Err(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "unsupported extension", file: "config.json", content: `{"db": {"addr": "x"}}`},
+		{name: "invalid yaml", file: "config.yaml", content: "db: [unclosed"},
+		{name: "list of maps", file: "config.yaml", content: "db:\n  - addr: x\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeFile(t, tt.file, tt.content))
+			if _, err := New(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+		if _, err := New(); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestErrors(t *testing.T) {
+	l := newLoader(t, "config.yaml", "test:\n  list: [a, b]\n")
+	t.Setenv("TEST_INT", "ten")
+	t.Setenv("TEST_DURATION", "5")
+	t.Setenv("TEST_BOOL", "maybe")
+
+	if got := l.Int("TEST_INT", 7); got != 7 {
+		t.Errorf("Int() = %d, want the fallback", got)
+	}
+	l.Duration("TEST_DURATION", time.Second)
+	l.Bool("TEST_BOOL", false)
+	l.String("TEST_LIST", "")
+	l.OneOf("TEST_MODE", "fast", "slow", "safe")
+	l.Check(true, "TEST_OK", "never reported")
+
+	err := l.Err()
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "(5 errors)") {
+		t.Errorf("expected 5 errors, got %s", msg)
+	}
+	for _, key := range []string{"TEST_INT", "TEST_DURATION", "TEST_BOOL", "TEST_LIST", "TEST_MODE"} {
+		if !strings.Contains(msg, key) {
+			t.Errorf("missing error for %s in %s", key, msg)
+		}
+	}
+	if strings.Contains(msg, "TEST_OK") {
+		t.Errorf("unexpected error for TEST_OK in %s", msg)
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want []string
+	}{
+		{name: "comma separated", env: "a, b ,c", want: []string{"a", "b", "c"}},
+		{name: "skips empty items", env: "a,,b,", want: []string{"a", "b"}},
+		{name: "empty", env: "", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLoader(t, "", "")
+			t.Setenv("TEST_LIST", tt.env)
+			if got := l.List("TEST_LIST", nil); !slices.Equal(got, tt.want) || got == nil {
+				t.Errorf("List() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("fallback when unset", func(t *testing.T) {
+		l := newLoader(t, "", "")
+		if got := l.List("TEST_UNSET_LIST", []string{"x"}); !slices.Equal(got, []string{"x"}) {
+			t.Errorf("List() = %q, want the fallback", got)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"go.yaml.in/yaml/v3"
+)
+
+// readFile lee el archivo de configuracion y lo aplana a las mismas claves que las variables de entorno:
+//
+//	db:
+//	  addr: postgres://...   ->  DB_ADDR
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q, use .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	values := map[string]any{}
+	if err := flatten(values, "", raw); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(dst map[string]any, prefix string, src map[string]any) error {
+	for k, v := range src {
+		key := strings.ToUpper(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch val := v.(type) {
+		case map[string]any:
+			if err := flatten(dst, key, val); err != nil {
+				return err
+			}
+		case []any:
+			list := make([]string, len(val))
+			for i, item := range val {
+				if _, ok := item.(map[string]any); ok {
+					return fmt.Errorf("%s: lists can only contain values", key)
+				}
+				list[i] = fmt.Sprint(item)
+			}
+			dst[key] = list
+		case nil:
+		default:
+			dst[key] = fmt.Sprint(val)
+		}
+	}
+	return nil
+}
@@ -3,12 +3,13 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	_ "github.com/lib/pq" // PostgreSQL driver
 )
 
-func New(addr string, maxOpenConns int, maxIdleConns int, maxLifetime string) (*sql.DB, error) {
+func New(addr string, maxOpenConns int, maxIdleConns int, maxLifetime time.Duration) (*sql.DB, error) {
 	db, err := sql.Open("postgres", addr)
 	if err != nil {
 		return nil, err
@@ -17,11 +18,7 @@ func New(addr string, maxOpenConns int, maxIdleConns int, maxLifetime string) (*
 	db.SetMaxOpenConns(maxOpenConns)
 	db.SetMaxIdleConns(maxIdleConns)
 
-	duration, err := time.ParseDuration(maxLifetime)
-	if err != nil {
-		return nil, err
-	}
-	db.SetConnMaxLifetime(duration)
+	db.SetConnMaxLifetime(maxLifetime)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -39,3 +36,13 @@ func New(addr string, maxOpenConns int, maxIdleConns int, maxLifetime string) (*
 
 	return nil, lastErr
 }
+
+// MigrationVersion devuelve la ultima migracion aplicada por golang-migrate. dirty indica que una migracion
+// fallo a la mitad y el schema quedo en un estado intermedio.
+func MigrationVersion(ctx context.Context, db *sql.DB) (version int64, dirty bool, err error) {
+	err = db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
@@ -0,0 +1,182 @@
+// Package events distribuye en el proceso los eventos de dominio (menciones, follows, comentarios...)
+// a quien le interesen, por ejemplo las notificaciones.
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	TypeMention  = "mention"
+	TypeFollow   = "follow"
+	TypeComment  = "comment"
+	TypeReaction = "reaction"
+	TypeUnfollow = "unfollow"
+	TypeRepost   = "repost"
+	// un post se publico (al crearlo, al publicar un draft o por el scheduler)
+	TypePostPublished = "post.published"
+	TypePostDeleted   = "post.deleted"
+	// un moderador suspendio a UserID
+	TypeUserSuspended = "user.suspended"
+)
+
+type Event struct {
+	Type string
+	// ActorID es el usuario que genero el evento y UserID el destinatario
+	ActorID   int64
+	UserID    int64
+	PostID    int64
+	CommentID int64
+	CreatedAt time.Time
+}
+
+type Handler func(context.Context, Event)
+
+type Config struct {
+	// eventos pendientes por worker. Si la cola de un worker esta llena el evento se entrega en el
+	// request que lo publico, asi no se pierde (aunque puede adelantarse a los que estan en la cola).
+	QueueSize int
+	// cantidad de workers que entregan los eventos. Los eventos de un mismo actor van siempre al mismo
+	// worker, asi se entregan en el orden en que se publicaron.
+	Workers int
+}
+
+type queuedEvent struct {
+	ctx   context.Context
+	event Event
+}
+
+// Bus entrega los eventos en segundo plano: Publish los encola y vuelve enseguida, asi la latencia de
+// los handlers (notificaciones, fan-out del timeline, trending...) no se suma a la del request.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+	queues   []chan queuedEvent
+	logger   *zap.SugaredLogger
+
+	// stopMu protege stopped: Publish encola con el read lock y Run lo marca con el write lock, asi
+	// nada se encola despues del ultimo drenado
+	stopMu  sync.RWMutex
+	stopped bool
+}
+
+func NewBus(cfg Config, logger *zap.SugaredLogger) *Bus {
+	queues := make([]chan queuedEvent, max(cfg.Workers, 1))
+	for i := range queues {
+		queues[i] = make(chan queuedEvent, cfg.QueueSize)
+	}
+	return &Bus{queues: queues, logger: logger}
+}
+
+// Subscribe registra un handler que recibe todos los eventos publicados
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish encola los eventos para entregarlos a los handlers en el orden en que se registraron.
+// Los handlers reciben un contexto que no se cancela cuando termina el request (conserva el logger
+// y la traza), asi un cliente que corta la conexion no deja la entrega a medias.
+func (b *Bus) Publish(ctx context.Context, events ...Event) {
+	ctx = context.WithoutCancel(ctx)
+
+	for _, e := range events {
+		if e.CreatedAt.IsZero() {
+			e.CreatedAt = time.Now()
+		}
+
+		if !b.enqueue(ctx, e) {
+			b.dispatch(ctx, e)
+		}
+	}
+}
+
+// enqueue deja el evento en la cola de su worker. Devuelve false si el bus ya se detuvo o la cola esta llena.
+func (b *Bus) enqueue(ctx context.Context, e Event) bool {
+	b.stopMu.RLock()
+	defer b.stopMu.RUnlock()
+	if b.stopped {
+		return false
+	}
+
+	queue := b.queues[int(uint64(e.ActorID)%uint64(len(b.queues)))]
+	select {
+	case queue <- queuedEvent{ctx: ctx, event: e}:
+		return true
+	default:
+		b.logger.Warnw("event queue full, delivering inline", "type", e.Type)
+		return false
+	}
+}
+
+// Run entrega los eventos encolados hasta que se cancele el contexto. Antes de volver entrega los que
+// quedaron en las colas; lo que se publique despues se entrega en el momento.
+func (b *Bus) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, queue := range b.queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case qe := <-queue:
+					b.dispatch(qe.ctx, qe.event)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	b.stopMu.Lock()
+	b.stopped = true
+	b.stopMu.Unlock()
+
+	for _, queue := range b.queues {
+		for len(queue) > 0 {
+			qe := <-queue
+			b.dispatch(qe.ctx, qe.event)
+		}
+	}
+}
+
+func (b *Bus) dispatch(ctx context.Context, e Event) {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		b.handle(ctx, h, e)
+	}
+}
+
+// handle aisla cada handler: un panic ya no lo recupera el middleware del request
+func (b *Bus) handle(ctx context.Context, h Handler, e Event) {
+	defer func() {
+		if err := recover(); err != nil {
+			b.logger.Errorw("panic handling event", "type", e.Type, "error", err)
+		}
+	}()
+	h(ctx, e)
+}
+
+// Mentions arma un evento de mencion por cada usuario mencionado. commentID es 0 si la mencion esta en el post.
+func Mentions(actorID, postID, commentID int64, userIDs []int64) []Event {
+	events := make([]Event, 0, len(userIDs))
+	for _, id := range userIDs {
+		events = append(events, Event{
+			Type:      TypeMention,
+			ActorID:   actorID,
+			UserID:    id,
+			PostID:    postID,
+			CommentID: commentID,
+		})
+	}
+	return events
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// TestBusDeliversEverythingOnStop publica mientras el bus se detiene: cada evento se entrega una vez,
+// encolado antes del ultimo drenado o en el momento despues
+func TestBusDeliversEverythingOnStop(t *testing.T) {
+	for range 50 {
+		bus := NewBus(Config{QueueSize: 10, Workers: 2}, zap.NewNop().Sugar())
+		var delivered atomic.Int64
+		bus.Subscribe(func(context.Context, Event) { delivered.Add(1) })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		done := make(chan struct{})
+		go func() {
+			bus.Run(ctx)
+			close(done)
+		}()
+
+		const publishers, perPublisher = 4, 100
+		var wg sync.WaitGroup
+		for p := range publishers {
+			wg.Go(func() {
+				for i := range perPublisher {
+					if p == 0 && i == perPublisher/2 {
+						cancel()
+					}
+					bus.Publish(context.Background(), Event{Type: TypeFollow, ActorID: int64(i)})
+				}
+			})
+		}
+		wg.Wait()
+		<-done
+
+		if got := delivered.Load(); got != publishers*perPublisher {
+			t.Fatalf("delivered %d events, want %d", got, publishers*perPublisher)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type loggerKey struct{}
+
+// WithLogger guarda el logger del request en el contexto
+func WithLogger(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext devuelve el logger del request (con request ID, trace ID y usuario) o fallback si
+// el contexto no viene de un request, por ejemplo en las tareas en segundo plano
+func FromContext(ctx context.Context, fallback *zap.SugaredLogger) *zap.SugaredLogger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.SugaredLogger); ok {
+		return logger
+	}
+	return fallback
+}
+
+// With agrega campos al logger del request, para que los logs siguientes del request los incluyan
+func With(ctx context.Context, fallback *zap.SugaredLogger, keysAndValues ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx, fallback).With(keysAndValues...))
+}
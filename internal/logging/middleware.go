@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type userKey struct{}
+
+// accessEntry junta los datos del access log que se conocen recien dentro de la cadena de handlers
+type accessEntry struct {
+	userID int64
+}
+
+// SetUser agrega el usuario autenticado al logger del request y al access log
+func SetUser(ctx context.Context, fallback *zap.SugaredLogger, userID int64) context.Context {
+	if entry, ok := ctx.Value(userKey{}).(*accessEntry); ok {
+		entry.userID = userID
+	}
+	return With(ctx, fallback, "user_id", userID)
+}
+
+// Middleware escribe una linea de access log por request y deja en el contexto el logger del request.
+// Va despues de RequestID, realip y el middleware de tracing para tener sus datos.
+func Middleware(logger *zap.SugaredLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ip := clientIP(r)
+
+			fields := []any{"request_id", middleware.GetReqID(r.Context())}
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				fields = append(fields, "trace_id", sc.TraceID().String())
+			}
+			reqLogger := logger.With(fields...)
+
+			entry := &accessEntry{}
+			ctx := context.WithValue(r.Context(), userKey{}, entry)
+			ctx = WithLogger(ctx, reqLogger)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			route := ""
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				route = rctx.RoutePattern()
+			}
+
+			kv := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", route,
+				"status", status,
+				"bytes", ww.BytesWritten(),
+				"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+				"client_ip", ip,
+				"user_agent", r.UserAgent(),
+			}
+			if q := RedactQuery(r.URL.Query()); q != "" {
+				kv = append(kv, "query", q)
+			}
+			if entry.userID != 0 {
+				kv = append(kv, "user_id", entry.userID)
+			}
+
+			switch {
+			case status >= http.StatusInternalServerError:
+				reqLogger.Errorw("request", kv...)
+			default:
+				reqLogger.Infow("request", kv...)
+			}
+		})
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const redacted = "[REDACTED]"
+
+// claves que nunca se escriben en el log, sin importar mayusculas ni guiones
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"setcookie":     true,
+	"token":         true,
+	"accesstoken":   true,
+	"apikey":        true,
+	"credentials":   true,
+}
+
+// IsSensitive indica si el valor de la clave se tiene que ocultar en los logs
+func IsSensitive(key string) bool {
+	k := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+	return sensitiveKeys[k] || strings.Contains(k, "password") || strings.Contains(k, "secret")
+}
+
+// RedactQuery devuelve la query string con los parametros sensibles ocultos (el stream manda el token
+// como access_token)
+func RedactQuery(values url.Values) string {
+	if len(values) == 0 {
+		return ""
+	}
+	clean := make(url.Values, len(values))
+	for k, v := range values {
+		if IsSensitive(k) {
+			v = []string{redacted}
+		}
+		clean[k] = v
+	}
+	return clean.Encode()
+}
+
+// redactCore oculta el valor de los campos sensibles antes de escribirlos, asi un campo como
+// "password" no llega al log aunque se pase por error
+type redactCore struct {
+	zapcore.Core
+}
+
+// RedactCore envuelve el core del logger, se usa con zap.WrapCore
+func RedactCore(core zapcore.Core) zapcore.Core {
+	return &redactCore{Core: core}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		if !IsSensitive(f.Key) {
+			continue
+		}
+		// se copia solo si hay algo que ocultar
+		if out == nil {
+			out = append([]zapcore.Field(nil), fields...)
+		}
+		out[i] = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: redacted}
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
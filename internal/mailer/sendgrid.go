@@ -2,33 +2,37 @@ package mailer
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"html/template"
-	"log"
 	"time"
 
+	"github.com/marceterrone10/social/internal/logging"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"go.uber.org/zap"
 )
 
 type SendGridMailer struct {
 	fromEmail string
 	apiKey    string
 	client    *sendgrid.Client
+	logger    *zap.SugaredLogger
 }
 
-func NewSendGridMailer(fromEmail, apiKey string) *SendGridMailer { // constructor del struct
+func NewSendGridMailer(fromEmail, apiKey string, logger *zap.SugaredLogger) *SendGridMailer { // constructor del struct
 	client := sendgrid.NewSendClient(apiKey)
 
 	return &SendGridMailer{
 		fromEmail: fromEmail,
 		apiKey:    apiKey,
 		client:    client,
+		logger:    logger,
 	}
 }
 
-func (m *SendGridMailer) Send(templateFile, username string, email string, data any, isSandbox bool) error {
+func (m *SendGridMailer) Send(ctx context.Context, templateFile, username string, email string, data any, isSandbox bool) error {
 	from := mail.NewEmail(username, m.fromEmail)
 	to := mail.NewEmail(username, email)
 
@@ -57,20 +61,27 @@ func (m *SendGridMailer) Send(templateFile, username string, email string, data
 		},
 	})
 
+	// logger del request si el envio viene de un handler
+	log := logging.FromContext(ctx, m.logger).With("template", templateFile)
+
 	for i := 0; i < maxRetries; i++ {
-		response, err := m.client.Send(message)
+		response, err := m.client.SendWithContext(ctx, message)
 		if err != nil {
-			log.Printf("Failed to send email (request error): %v", err)
-			time.Sleep(time.Second * time.Duration(i+1))
+			log.Warnw("failed to send email", "attempt", i+1, "error", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second * time.Duration(i+1)):
+			}
 			continue
 		}
 
 		if response.StatusCode < 200 || response.StatusCode >= 300 {
-			log.Printf("SendGrid API error: status=%d body=%s", response.StatusCode, response.Body)
+			log.Errorw("sendgrid API error", "status", response.StatusCode, "body", response.Body)
 			return fmt.Errorf("sendgrid API returned status %d: %s", response.StatusCode, response.Body)
 		}
 
-		log.Printf("Email sent successfully: %v", response.StatusCode)
+		log.Infow("email sent", "status", response.StatusCode)
 		return nil
 	}
 	return errors.New("failed to send email")
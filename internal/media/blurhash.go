@@ -0,0 +1,115 @@
+package media
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	blurhashComponentsX = 4
+	blurhashComponentsY = 3
+	// la imagen se achica antes de calcular el hash, el resultado es practicamente el mismo y es mucho mas rapido
+	blurhashSampleSize = 32
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Blurhash calcula el placeholder compacto (https://blurha.sh) que el cliente muestra mientras carga la imagen
+func Blurhash(img image.Image) string {
+	b := img.Bounds()
+	w, h := blurhashSampleSize, blurhashSampleSize*b.Dy()/max(b.Dx(), 1)
+	if b.Dy() > b.Dx() {
+		w, h = blurhashSampleSize*b.Dx()/max(b.Dy(), 1), blurhashSampleSize
+	}
+	w, h = max(w, 1), max(h, 1)
+
+	small := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, b, draw.Src, nil)
+
+	factors := make([][3]float64, 0, blurhashComponentsX*blurhashComponentsY)
+	for j := 0; j < blurhashComponentsY; j++ {
+		for i := 0; i < blurhashComponentsX; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1.0
+			}
+
+			var r, g, bl float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+					p := small.RGBAAt(x, y)
+					r += basis * srgbToLinear(p.R)
+					g += basis * srgbToLinear(p.G)
+					bl += basis * srgbToLinear(p.B)
+				}
+			}
+			scale := 1.0 / float64(w*h)
+			factors = append(factors, [3]float64{r * scale, g * scale, bl * scale})
+		}
+	}
+
+	var sb strings.Builder
+
+	sizeFlag := (blurhashComponentsX - 1) + (blurhashComponentsY-1)*9
+	sb.WriteString(encode83(sizeFlag, 1))
+
+	maximumValue := 1.0
+	if len(factors) > 1 {
+		actualMax := 0.0
+		for _, f := range factors[1:] {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maximumValue = float64(quantisedMax+1) / 166
+		sb.WriteString(encode83(quantisedMax, 1))
+	} else {
+		sb.WriteString(encode83(0, 1))
+	}
+
+	dc := factors[0]
+	sb.WriteString(encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+
+	for _, f := range factors[1:] {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximumValue, 0.5)*9+9.5))))
+		}
+		sb.WriteString(encode83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+
+	return sb.String()
+}
+
+func encode83(value, length int) string {
+	out := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		out[i-1] = base83Chars[digit]
+	}
+	return string(out)
+}
+
+func srgbToLinear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	c := math.Max(0, math.Min(1, v))
+	if c <= 0.0031308 {
+		return int(c*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(c, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
@@ -0,0 +1,253 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif" // registra el decoder de GIF para DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+const (
+	KindImage = "image"
+	KindVideo = "video"
+
+	MaxImageBytes = 10 << 20 // 10 MB
+	MaxVideoBytes = 50 << 20 // 50 MB
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrTooManyPixels   = errors.New("image dimensions exceed the limit")
+)
+
+// Config limita lo que el pipeline acepta decodificar
+type Config struct {
+	// ancho x alto maximo de una imagen. Un PNG de pocos KB puede declarar un canvas enorme y
+	// decodificarlo reserva 4 bytes por pixel.
+	MaxImagePixels int
+}
+
+type format struct {
+	kind string
+	ext  string
+}
+
+// tipos que aceptamos, detectados a partir del contenido y no del header que manda el cliente
+var allowedTypes = map[string]format{
+	"image/jpeg": {KindImage, ".jpg"},
+	"image/png":  {KindImage, ".png"},
+	"image/gif":  {KindImage, ".gif"},
+	"image/webp": {KindImage, ".webp"},
+	"video/mp4":  {KindVideo, ".mp4"},
+	"video/webm": {KindVideo, ".webm"},
+}
+
+type Info struct {
+	MimeType string
+	Kind     string
+	Ext      string
+	Width    int
+	Height   int
+}
+
+// MaxBytes devuelve el tamaño maximo permitido para el tipo de archivo
+func (i Info) MaxBytes() int64 {
+	if i.Kind == KindVideo {
+		return MaxVideoBytes
+	}
+	return MaxImageBytes
+}
+
+// Inspect detecta el tipo de archivo por su contenido y, si es una imagen, sus dimensiones.
+// Rechaza con ErrTooManyPixels las imagenes de mas de maxPixels.
+func Inspect(data []byte, maxPixels int) (Info, error) {
+	mimeType := http.DetectContentType(data)
+
+	f, ok := allowedTypes[mimeType]
+	if !ok {
+		return Info{}, ErrUnsupportedType
+	}
+
+	info := Info{
+		MimeType: mimeType,
+		Kind:     f.kind,
+		Ext:      f.ext,
+	}
+
+	if f.kind == KindImage {
+		// DecodeConfig no soporta webp en la stdlib, en ese caso quedan las dimensiones en 0
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			if err := checkPixels(cfg, maxPixels); err != nil {
+				return Info{}, err
+			}
+			info.Width = cfg.Width
+			info.Height = cfg.Height
+		}
+	}
+
+	return info, nil
+}
+
+func checkPixels(cfg image.Config, maxPixels int) error {
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return ErrMalformed
+	}
+	// se divide en vez de multiplicar para no desbordar con dimensiones enormes
+	if cfg.Width > maxPixels/cfg.Height {
+		return ErrTooManyPixels
+	}
+	return nil
+}
+
+// StripMetadata elimina EXIF y otros metadatos (ubicacion, camara, etc) de las imagenes sin recodificarlas
+func StripMetadata(mimeType string, data []byte) ([]byte, error) {
+	switch mimeType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	default:
+		return data, nil
+	}
+}
+
+var ErrMalformed = errors.New("malformed image")
+
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrMalformed
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+
+	i := 2
+	for i < len(data) {
+		if i+1 >= len(data) || data[i] != 0xFF {
+			return nil, ErrMalformed
+		}
+		marker := data[i+1]
+
+		// markers sin longitud
+		if marker == 0xD8 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[i : i+2])
+			i += 2
+			continue
+		}
+		if marker == 0xD9 {
+			out.Write(data[i : i+2])
+			return out.Bytes(), nil
+		}
+
+		if i+4 > len(data) {
+			return nil, ErrMalformed
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, ErrMalformed
+		}
+
+		// SOS: a partir de aca son los datos comprimidos, se copian tal cual
+		if marker == 0xDA {
+			out.Write(data[i:])
+			return out.Bytes(), nil
+		}
+
+		// APP1 (EXIF/XMP) y APP13 (IPTC) se descartan
+		if marker != 0xE1 && marker != 0xED {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	return out.Bytes(), nil
+}
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+// chunks de PNG con metadatos que se descartan
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrMalformed
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	i := len(pngSignature)
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, ErrMalformed
+		}
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		chunkType := string(data[i+4 : i+8])
+		end := i + 12 + length // length + type + data + crc
+		if length < 0 || end > len(data) {
+			return nil, ErrMalformed
+		}
+
+		if !pngMetadataChunks[chunkType] {
+			out.Write(data[i:end])
+		}
+		i = end
+
+		if chunkType == "IEND" {
+			break
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrMalformed
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+
+	i := 12
+	for i+8 <= len(data) {
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		end := i + 8 + size + size%2 // los chunks RIFF se alinean a 2 bytes
+		if end > len(data) {
+			return nil, ErrMalformed
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			// se descarta
+		case "VP8X":
+			if size == 0 {
+				return nil, ErrMalformed
+			}
+			chunk := append([]byte(nil), data[i:end]...)
+			chunk[8] &^= 0x08 | 0x04 // limpiamos los flags de EXIF y XMP
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(result)-8))
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"sort"
+
+	"golang.org/x/image/draw"
+)
+
+// VariantWidths son los anchos fijos que se generan para cada imagen. El primero es el thumbnail.
+var VariantWidths = []int{150, 320, 640, 1080}
+
+const variantJPEGQuality = 80
+
+// no hay encoder de WebP en Go puro, asi que los variants son JPEG (o PNG si la imagen tiene transparencia)
+var processableTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type Variant struct {
+	Width    int
+	Height   int
+	MimeType string
+	Ext      string
+	Data     []byte
+}
+
+// CanProcess indica si el pipeline sabe decodificar el tipo de archivo
+func CanProcess(mimeType string) bool {
+	return processableTypes[mimeType]
+}
+
+// ProcessImage decodifica la imagen (el primer frame en el caso de GIF), genera un variant por cada ancho
+// menor al original y calcula el blurhash. Nunca se agranda la imagen: si es mas chica que todos los
+// anchos se genera un unico variant del tamaño original. Las dimensiones se validan contra maxPixels
+// antes de decodificar.
+func ProcessImage(data []byte, widths []int, maxPixels int) ([]Variant, string, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if err := checkPixels(cfg, maxPixels); err != nil {
+		return nil, "", err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW == 0 || srcH == 0 {
+		return nil, "", ErrMalformed
+	}
+
+	targets := []int{}
+	for _, w := range widths {
+		if w < srcW {
+			targets = append(targets, w)
+		}
+	}
+	if len(targets) == 0 {
+		targets = append(targets, srcW)
+	}
+	sort.Ints(targets)
+
+	opaque := isOpaque(img)
+
+	variants := make([]Variant, 0, len(targets))
+	for _, w := range targets {
+		h := max(srcH*w/srcW, 1)
+
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+
+		v := Variant{Width: w, Height: h}
+		buf := new(bytes.Buffer)
+		if opaque {
+			v.MimeType, v.Ext = "image/jpeg", ".jpg"
+			err = jpeg.Encode(buf, dst, &jpeg.Options{Quality: variantJPEGQuality})
+		} else {
+			v.MimeType, v.Ext = "image/png", ".png"
+			err = png.Encode(buf, dst)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		v.Data = buf.Bytes()
+
+		variants = append(variants, v)
+	}
+
+	return variants, Blurhash(img), nil
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
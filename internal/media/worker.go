@@ -0,0 +1,149 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/marceterrone10/social/internal/blob"
+	"github.com/marceterrone10/social/internal/store"
+	"go.uber.org/zap"
+)
+
+const (
+	workerPollInterval = 10 * time.Second
+	workerBatchSize    = 5
+	// si una instancia se cae procesando, otra retoma el adjunto despues de este tiempo
+	workerStaleAfter = 5 * time.Minute
+)
+
+// Worker procesa en background los adjuntos pendientes: genera los variants y el blurhash.
+// Los adjuntos se toman de la DB, asi que sobrevive reinicios y puede correr en varias instancias.
+type Worker struct {
+	attachments store.AttachmentRepository
+	blobs       blob.Storage
+	cfg         Config
+	logger      *zap.SugaredLogger
+	notify      chan struct{}
+}
+
+func NewWorker(attachments store.AttachmentRepository, blobs blob.Storage, cfg Config, logger *zap.SugaredLogger) *Worker {
+	return &Worker{
+		attachments: attachments,
+		blobs:       blobs,
+		cfg:         cfg,
+		logger:      logger,
+		notify:      make(chan struct{}, 1),
+	}
+}
+
+// Notify despierta al worker sin esperar al proximo tick (por ejemplo despues de un upload)
+func (w *Worker) Notify() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run procesa adjuntos hasta que se cancele el contexto
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(workerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.notify:
+		}
+	}
+}
+
+// drain procesa lotes hasta que no queden adjuntos pendientes
+func (w *Worker) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch, err := w.attachments.ClaimPending(ctx, workerBatchSize, workerStaleAfter)
+		if err != nil {
+			w.logger.Errorw("error claiming pending attachments", "error", err)
+			return
+		}
+		if len(batch) == 0 {
+			return
+		}
+
+		for _, a := range batch {
+			if err := w.process(ctx, a); err != nil {
+				w.logger.Errorw("error processing attachment", "attachment_id", a.ID, "error", err)
+				if err := w.attachments.MarkFailed(ctx, a.ID, err.Error()); err != nil {
+					w.logger.Errorw("error marking attachment as failed", "attachment_id", a.ID, "error", err)
+				}
+			}
+		}
+	}
+}
+
+func (w *Worker) process(ctx context.Context, a store.Attachment) error {
+	if !CanProcess(a.MimeType) {
+		return fmt.Errorf("cannot process %s", a.MimeType)
+	}
+
+	r, err := w.blobs.Get(ctx, a.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	variants, hash, err := ProcessImage(data, VariantWidths, w.cfg.MaxImagePixels)
+	if err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(a.StorageKey, path.Ext(a.StorageKey))
+	saved := make([]store.AttachmentVariant, 0, len(variants))
+	for _, v := range variants {
+		key := fmt.Sprintf("%s_w%d%s", base, v.Width, v.Ext)
+		if err := w.blobs.Put(ctx, key, bytes.NewReader(v.Data), int64(len(v.Data)), v.MimeType); err != nil {
+			w.deleteVariants(saved)
+			return err
+		}
+		saved = append(saved, store.AttachmentVariant{
+			Width:      v.Width,
+			Height:     v.Height,
+			MimeType:   v.MimeType,
+			URL:        w.blobs.URL(key),
+			StorageKey: key,
+		})
+	}
+
+	if err := w.attachments.SaveVariants(ctx, a.ID, saved, hash); err != nil {
+		// el adjunto se borro mientras lo procesabamos
+		w.deleteVariants(saved)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	w.logger.Infow("attachment processed", "attachment_id", a.ID, "variants", len(saved))
+	return nil
+}
+
+func (w *Worker) deleteVariants(variants []store.AttachmentVariant) {
+	for _, v := range variants {
+		if err := w.blobs.Delete(context.Background(), v.StorageKey); err != nil {
+			w.logger.Errorw("error deleting variant blob", "key", v.StorageKey, "error", err)
+		}
+	}
+}
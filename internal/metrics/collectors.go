@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/marceterrone10/social/internal/mailer"
+	"github.com/marceterrone10/social/internal/store/cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cacheCollector lee los contadores de cache.Stats en cada scrape, asi el cache no depende de Prometheus
+type cacheCollector struct {
+	stats  *cache.Stats
+	hits   *prometheus.Desc
+	misses *prometheus.Desc
+}
+
+func newCacheCollector(stats *cache.Stats) *cacheCollector {
+	return &cacheCollector{
+		stats:  stats,
+		hits:   prometheus.NewDesc(namespace+"_cache_hits_total", "Cache hits by store.", []string{"store"}, nil),
+		misses: prometheus.NewDesc(namespace+"_cache_misses_total", "Cache misses by store.", []string{"store"}, nil),
+	}
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, s := range c.stats.Snapshot() {
+		ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.Hits), name)
+		ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.Misses), name)
+	}
+}
+
+type instrumentedMailer struct {
+	next    mailer.Client
+	metrics *Metrics
+}
+
+// Mailer envuelve el cliente para contar los envios por template y resultado
+func (m *Metrics) Mailer(next mailer.Client) mailer.Client {
+	return &instrumentedMailer{next: next, metrics: m}
+}
+
+func (m *instrumentedMailer) Send(ctx context.Context, templateFile, username string, email string, data any, isSandbox bool) error {
+	err := m.next.Send(ctx, templateFile, username, email, data, isSandbox)
+
+	result := "sent"
+	if err != nil {
+		result = "failed"
+	}
+	m.metrics.mailSends.WithLabelValues(templateFile, result).Inc()
+	return err
+}
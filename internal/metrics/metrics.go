@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/store/cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "social"
+
+// Metrics junta las metricas de la API en un registry propio, asi /metrics solo expone lo que registramos
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	queryDuration *prometheus.HistogramVec
+	rateLimited   *prometheus.CounterVec
+	mailSends     *prometheus.CounterVec
+}
+
+func New(db *sql.DB, cacheStats *cache.Stats) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route pattern, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route pattern, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "store_query_duration_seconds",
+			Help:      "Latency of the store repository methods.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"repository", "method", "result"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limiter_rejections_total",
+			Help:      "Requests rejected by the rate limiter by policy.",
+		}, []string{"policy"}),
+		mailSends: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mailer_sends_total",
+			Help:      "Emails sent by template and result.",
+		}, []string{"template", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "postgres"),
+		newCacheCollector(cacheStats),
+		m.httpRequests,
+		m.httpDuration,
+		m.queryDuration,
+		m.rateLimited,
+		m.mailSends,
+	)
+
+	return m
+}
+
+// Handler expone las metricas en el formato de texto de Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware mide cada request. Se etiqueta con el patron de la ruta de chi y no con el path,
+// asi /posts/1 y /posts/2 caen en la misma serie.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+		m.httpRequests.With(labels).Inc()
+		m.httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Start implementa store.Observer
+func (m *Metrics) Start(ctx context.Context, repository, method string) (context.Context, func(error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		result := "ok"
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			result = "not_found"
+		case err != nil:
+			result = "error"
+		}
+		m.queryDuration.WithLabelValues(repository, method, result).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RateLimited cuenta un request rechazado por la policy
+func (m *Metrics) RateLimited(policy string) {
+	m.rateLimited.WithLabelValues(policy).Inc()
+}
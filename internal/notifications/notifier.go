@@ -0,0 +1,100 @@
+// Package notifications convierte los eventos de dominio en notificaciones para el usuario.
+package notifications
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/marceterrone10/social/internal/events"
+	"github.com/marceterrone10/social/internal/store"
+	"go.uber.org/zap"
+)
+
+type Notifier struct {
+	notifications store.NotificationRepository
+	blocks        store.BlockRepository
+	logger        *zap.SugaredLogger
+	onNotify      []func(context.Context, store.NotificationEvent)
+}
+
+func NewNotifier(notifications store.NotificationRepository, blocks store.BlockRepository, logger *zap.SugaredLogger) *Notifier {
+	return &Notifier{
+		notifications: notifications,
+		blocks:        blocks,
+		logger:        logger,
+	}
+}
+
+// OnNotify registra una funcion que se llama por cada notificacion entregada (por ejemplo para el stream en tiempo real)
+func (n *Notifier) OnNotify(fn func(context.Context, store.NotificationEvent)) {
+	n.onNotify = append(n.onNotify, fn)
+}
+
+// Handle se suscribe al bus de eventos. Un error al notificar no hace fallar la accion que genero el evento.
+func (n *Notifier) Handle(ctx context.Context, e events.Event) {
+	// nadie se notifica de sus propias acciones
+	if e.UserID == 0 || e.UserID == e.ActorID {
+		return
+	}
+
+	ne, ok := notificationFor(e)
+	if !ok {
+		return
+	}
+
+	// nadie se notifica de las acciones de un usuario que bloqueo o que lo bloqueo
+	blocked, err := n.blocks.IsBlocked(ctx, e.UserID, e.ActorID)
+	if err != nil {
+		n.logger.Errorw("error checking blocks for notification", "type", e.Type, "user_id", e.UserID, "actor_id", e.ActorID, "error", err)
+		return
+	}
+	if blocked {
+		return
+	}
+
+	delivered, err := n.notifications.Create(ctx, ne)
+	if err != nil {
+		n.logger.Errorw("error creating notification", "type", e.Type, "user_id", e.UserID, "actor_id", e.ActorID, "error", err)
+		return
+	}
+	if !delivered {
+		return
+	}
+
+	for _, fn := range n.onNotify {
+		fn(ctx, ne)
+	}
+}
+
+// notificationFor define como se agrupa cada tipo de evento: los follows en un solo grupo, los comentarios
+// y reacciones por post, y las menciones no se agrupan.
+func notificationFor(e events.Event) (store.NotificationEvent, bool) {
+	ne := store.NotificationEvent{
+		UserID:  e.UserID,
+		ActorID: e.ActorID,
+	}
+	if e.PostID != 0 {
+		ne.PostID = &e.PostID
+	}
+	if e.CommentID != 0 {
+		ne.CommentID = &e.CommentID
+	}
+
+	switch e.Type {
+	case events.TypeFollow:
+		ne.Type = store.NotificationTypeFollow
+		ne.GroupKey = "follow"
+	case events.TypeComment:
+		ne.Type = store.NotificationTypeComment
+		ne.GroupKey = fmt.Sprintf("comment:%d", e.PostID)
+	case events.TypeReaction:
+		ne.Type = store.NotificationTypeReaction
+		ne.GroupKey = fmt.Sprintf("reaction:%d", e.PostID)
+	case events.TypeMention:
+		ne.Type = store.NotificationTypeMention
+		ne.GroupKey = fmt.Sprintf("mention:%d:%d", e.PostID, e.CommentID)
+	default:
+		return ne, false
+	}
+	return ne, true
+}
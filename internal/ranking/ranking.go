@@ -0,0 +1,237 @@
+// Package ranking ordena los posts del feed "For You". Cada señal (recencia, engagement, afinidad con
+// el autor, afinidad con los tags, cercania en la red) da un valor entre 0 y 1 y el score es la suma
+// ponderada. Los pesos se configuran y cada post rankeado trae la explicacion de su score.
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marceterrone10/social/internal/store"
+)
+
+// Nombres de las señales, son las claves de Weights
+const (
+	SignalRecency    = "recency"
+	SignalEngagement = "engagement"
+	SignalAuthor     = "author"
+	SignalTags       = "tags"
+	SignalNetwork    = "network"
+)
+
+type Weights map[string]float64
+
+type Config struct {
+	Weights Weights
+	// tiempo en que la señal de recencia cae a la mitad
+	HalfLife time.Duration
+	// antiguedad maxima de los candidatos y cantidad maxima de candidatos por request
+	Window   time.Duration
+	PoolSize int
+	// periodo de interacciones que se usa para la afinidad con autores y tags
+	ProfileWindow time.Duration
+}
+
+var DefaultWeights = Weights{
+	SignalRecency:    1,
+	SignalEngagement: 0.6,
+	SignalAuthor:     0.8,
+	SignalTags:       0.4,
+	SignalNetwork:    0.5,
+}
+
+// ParseWeights lee pesos con el formato "recency=1,engagement=0.5". Las señales que no aparecen
+// mantienen el peso por defecto. Se aceptan tambien los nombres de las señales extra que se le pasan
+// a NewRanker; una señal extra sin peso no suma al score.
+func ParseWeights(s string, extra ...Signal) (Weights, error) {
+	weights := Weights{}
+	for k, v := range DefaultWeights {
+		weights[k] = v
+	}
+
+	known := map[string]bool{}
+	for k := range DefaultWeights {
+		known[k] = true
+	}
+	for _, sig := range extra {
+		known[sig.Name()] = true
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid ranking weight %q", pair)
+		}
+		name = strings.TrimSpace(name)
+		if !known[name] {
+			return nil, fmt.Errorf("unknown ranking signal %q", name)
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight for %q", name)
+		}
+		weights[name] = w
+	}
+	return weights, nil
+}
+
+// Signal es una señal del scoring. Value devuelve un valor entre 0 y 1.
+type Signal interface {
+	Name() string
+	Value(c *store.FeedCandidate, p *store.InteractionProfile, now time.Time) float64
+}
+
+// Component es el aporte de una señal al score de un post
+type Component struct {
+	Signal       string  `json:"signal"`
+	Value        float64 `json:"value"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
+
+// Explanation explica el score de un post, para debug
+type Explanation struct {
+	Score float64 `json:"score"`
+	// "following" o "second_degree"
+	Source     string      `json:"source"`
+	Components []Component `json:"components"`
+}
+
+type Ranked struct {
+	*store.FeedCandidate
+	Explanation Explanation
+}
+
+type Ranker struct {
+	signals []Signal
+	weights Weights
+}
+
+// NewRanker arma el ranker con las señales por defecto. Se pueden sumar otras con extra, con su peso
+// en cfg.Weights (ver ParseWeights).
+func NewRanker(cfg Config, extra ...Signal) *Ranker {
+	signals := []Signal{
+		recency{halfLife: cfg.HalfLife},
+		engagement{},
+		authorAffinity{},
+		tagAffinity{},
+		network{},
+	}
+	return &Ranker{
+		signals: append(signals, extra...),
+		weights: cfg.Weights,
+	}
+}
+
+// Rank calcula el score de cada candidato y los devuelve del mayor al menor
+func (r *Ranker) Rank(candidates []*store.FeedCandidate, profile *store.InteractionProfile, now time.Time) []Ranked {
+	ranked := make([]Ranked, len(candidates))
+	for i, c := range candidates {
+		exp := Explanation{Source: "following", Components: make([]Component, 0, len(r.signals))}
+		if c.Degree > 1 {
+			exp.Source = "second_degree"
+		}
+
+		for _, s := range r.signals {
+			v := s.Value(c, profile, now)
+			w := r.weights[s.Name()]
+			exp.Components = append(exp.Components, Component{
+				Signal:       s.Name(),
+				Value:        round(v),
+				Weight:       w,
+				Contribution: round(v * w),
+			})
+			exp.Score += v * w
+		}
+		exp.Score = round(exp.Score)
+
+		ranked[i] = Ranked{FeedCandidate: c, Explanation: exp}
+	}
+
+	// a igual score va primero el mas reciente
+	slices.SortStableFunc(ranked, func(a, b Ranked) int {
+		if a.Explanation.Score != b.Explanation.Score {
+			if a.Explanation.Score > b.Explanation.Score {
+				return -1
+			}
+			return 1
+		}
+		return b.PublishedAt.Compare(a.PublishedAt)
+	})
+	return ranked
+}
+
+func round(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// saturate lleva un conteo a [0, 1): con x == half da 0.5
+func saturate(x, half float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return x / (x + half)
+}
+
+// recency decae exponencialmente con la antiguedad del post
+type recency struct {
+	halfLife time.Duration
+}
+
+func (recency) Name() string { return SignalRecency }
+
+func (s recency) Value(c *store.FeedCandidate, _ *store.InteractionProfile, now time.Time) float64 {
+	age := max(now.Sub(c.PublishedAt), 0)
+	return math.Exp(-math.Ln2 * float64(age) / float64(s.halfLife))
+}
+
+// engagement cuenta comentarios, reacciones y reposts. Un comentario o un repost pesan mas que una reaccion.
+type engagement struct{}
+
+func (engagement) Name() string { return SignalEngagement }
+
+func (engagement) Value(c *store.FeedCandidate, _ *store.InteractionProfile, _ time.Time) float64 {
+	return saturate(float64(c.Reactions+2*c.Comments+3*c.Reposts), 20)
+}
+
+// authorAffinity crece con las interacciones pasadas del usuario con el autor
+type authorAffinity struct{}
+
+func (authorAffinity) Name() string { return SignalAuthor }
+
+func (authorAffinity) Value(c *store.FeedCandidate, p *store.InteractionProfile, _ time.Time) float64 {
+	return saturate(float64(p.Authors[c.AuthorID]), 5)
+}
+
+// tagAffinity crece con las interacciones pasadas del usuario con los tags del post
+type tagAffinity struct{}
+
+func (tagAffinity) Name() string { return SignalTags }
+
+func (tagAffinity) Value(c *store.FeedCandidate, p *store.InteractionProfile, _ time.Time) float64 {
+	total := 0
+	for _, t := range c.Tags {
+		total += p.Tags[t]
+	}
+	return saturate(float64(total), 5)
+}
+
+// network favorece a los autores seguidos sobre los de segundo grado
+type network struct{}
+
+func (network) Name() string { return SignalNetwork }
+
+func (network) Value(c *store.FeedCandidate, _ *store.InteractionProfile, _ time.Time) float64 {
+	if c.Degree <= 1 {
+		return 1
+	}
+	return 0
+}
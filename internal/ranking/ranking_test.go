@@ -0,0 +1,200 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/marceterrone10/social/internal/store"
+)
+
+// verified suma 1 a los posts de autores verificados, como ejemplo de señal extra
+type verified struct{ ids map[int64]bool }
+
+func (verified) Name() string { return "verified" }
+
+func (s verified) Value(c *store.FeedCandidate, _ *store.InteractionProfile, _ time.Time) float64 {
+	if s.ids[c.AuthorID] {
+		return 1
+	}
+	return 0
+}
+
+func TestParseWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		extra   []Signal
+		want    Weights
+		wantErr bool
+	}{
+		{name: "empty keeps defaults", in: "", want: DefaultWeights},
+		{
+			name: "overrides only the given signals",
+			in:   " recency=2 , tags=0",
+			want: Weights{SignalRecency: 2, SignalEngagement: 0.6, SignalAuthor: 0.8, SignalTags: 0, SignalNetwork: 0.5},
+		},
+		{name: "trailing comma", in: "network=1,", want: Weights{SignalRecency: 1, SignalEngagement: 0.6, SignalAuthor: 0.8, SignalTags: 0.4, SignalNetwork: 1}},
+		{name: "unknown signal", in: "verified=1", wantErr: true},
+		{
+			name:  "extra signal",
+			in:    "verified=0.3",
+			extra: []Signal{verified{}},
+			want:  Weights{SignalRecency: 1, SignalEngagement: 0.6, SignalAuthor: 0.8, SignalTags: 0.4, SignalNetwork: 0.5, "verified": 0.3},
+		},
+		{name: "missing value", in: "recency", wantErr: true},
+		{name: "not a number", in: "recency=high", wantErr: true},
+		{name: "negative", in: "recency=-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWeights(tt.in, tt.extra...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("weight %s = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseWeightsDoesNotModifyDefaults(t *testing.T) {
+	if _, err := ParseWeights("recency=5"); err != nil {
+		t.Fatal(err)
+	}
+	if DefaultWeights[SignalRecency] != 1 {
+		t.Errorf("DefaultWeights was modified: %v", DefaultWeights)
+	}
+}
+
+func TestSignals(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	profile := &store.InteractionProfile{
+		Authors: map[int64]int{7: 5},
+		Tags:    map[string]int{"go": 3, "db": 2},
+	}
+
+	tests := []struct {
+		name   string
+		signal Signal
+		c      store.FeedCandidate
+		want   float64
+	}{
+		{"recency just published", recency{halfLife: 6 * time.Hour}, store.FeedCandidate{PublishedAt: now}, 1},
+		{"recency one half-life", recency{halfLife: 6 * time.Hour}, store.FeedCandidate{PublishedAt: now.Add(-6 * time.Hour)}, 0.5},
+		{"recency two half-lives", recency{halfLife: 6 * time.Hour}, store.FeedCandidate{PublishedAt: now.Add(-12 * time.Hour)}, 0.25},
+		{"recency in the future", recency{halfLife: 6 * time.Hour}, store.FeedCandidate{PublishedAt: now.Add(time.Hour)}, 1},
+		{"engagement none", engagement{}, store.FeedCandidate{}, 0},
+		// 4 reacciones + 2*3 comentarios + 3*2 reposts = 16, 16 / (16 + 20)
+		{"engagement weighted", engagement{}, store.FeedCandidate{Reactions: 4, Comments: 3, Reposts: 2}, 16.0 / 36},
+		{"engagement half", engagement{}, store.FeedCandidate{Reactions: 20}, 0.5},
+		{"author known", authorAffinity{}, store.FeedCandidate{AuthorID: 7}, 0.5},
+		{"author unknown", authorAffinity{}, store.FeedCandidate{AuthorID: 8}, 0},
+		{"tags sum", tagAffinity{}, store.FeedCandidate{Tags: []string{"go", "db", "rust"}}, 0.5},
+		{"tags none", tagAffinity{}, store.FeedCandidate{}, 0},
+		{"network following", network{}, store.FeedCandidate{Degree: 1}, 1},
+		{"network second degree", network{}, store.FeedCandidate{Degree: 2}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.signal.Value(&tt.c, profile, now); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Value = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	profile := &store.InteractionProfile{Authors: map[int64]int{}, Tags: map[string]int{}}
+
+	// solo cuenta la recencia
+	weights := Weights{SignalRecency: 1}
+	r := NewRanker(Config{Weights: weights, HalfLife: time.Hour})
+
+	old := &store.FeedCandidate{PostID: 1, PublishedAt: now.Add(-2 * time.Hour), Degree: 1}
+	fresh := &store.FeedCandidate{PostID: 2, PublishedAt: now, Degree: 1}
+	second := &store.FeedCandidate{PostID: 3, PublishedAt: now.Add(-time.Hour), Degree: 2}
+
+	ranked := r.Rank([]*store.FeedCandidate{old, fresh, second}, profile, now)
+
+	wantOrder := []int64{2, 3, 1}
+	for i, id := range wantOrder {
+		if ranked[i].PostID != id {
+			t.Fatalf("position %d = post %d, want %d", i, ranked[i].PostID, id)
+		}
+	}
+
+	exp := ranked[1].Explanation
+	if exp.Source != "second_degree" || ranked[0].Explanation.Source != "following" {
+		t.Errorf("sources = %q, %q", ranked[0].Explanation.Source, exp.Source)
+	}
+	if exp.Score != 0.5 {
+		t.Errorf("score = %v, want 0.5", exp.Score)
+	}
+	if len(exp.Components) != 5 {
+		t.Fatalf("got %d components, want one per signal", len(exp.Components))
+	}
+	sum := 0.0
+	for _, c := range exp.Components {
+		sum += c.Contribution
+		if c.Signal != SignalRecency && c.Contribution != 0 {
+			t.Errorf("signal %s without weight contributed %v", c.Signal, c.Contribution)
+		}
+	}
+	if sum != exp.Score {
+		t.Errorf("contributions add up to %v, score is %v", sum, exp.Score)
+	}
+}
+
+func TestRankTieBreaksByRecency(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	profile := &store.InteractionProfile{Authors: map[int64]int{}, Tags: map[string]int{}}
+
+	// con peso solo en network los dos posts empatan
+	r := NewRanker(Config{Weights: Weights{SignalNetwork: 1}, HalfLife: time.Hour})
+	a := &store.FeedCandidate{PostID: 1, PublishedAt: now.Add(-time.Hour), Degree: 1}
+	b := &store.FeedCandidate{PostID: 2, PublishedAt: now, Degree: 1}
+
+	ranked := r.Rank([]*store.FeedCandidate{a, b}, profile, now)
+	if ranked[0].PostID != 2 {
+		t.Errorf("expected the newest post first on a tie, got post %d", ranked[0].PostID)
+	}
+}
+
+func TestRankExtraSignal(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	profile := &store.InteractionProfile{Authors: map[int64]int{}, Tags: map[string]int{}}
+
+	weights, err := ParseWeights("recency=0,engagement=0,author=0,tags=0,network=0,verified=2", verified{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRanker(Config{Weights: weights, HalfLife: time.Hour}, verified{ids: map[int64]bool{9: true}})
+
+	plain := &store.FeedCandidate{PostID: 1, AuthorID: 8, PublishedAt: now, Degree: 1}
+	boosted := &store.FeedCandidate{PostID: 2, AuthorID: 9, PublishedAt: now.Add(-time.Hour), Degree: 1}
+
+	ranked := r.Rank([]*store.FeedCandidate{plain, boosted}, profile, now)
+	if ranked[0].PostID != 2 || ranked[0].Explanation.Score != 2 {
+		t.Fatalf("expected the verified author first with score 2, got post %d with %v", ranked[0].PostID, ranked[0].Explanation.Score)
+	}
+	last := ranked[0].Explanation.Components[len(ranked[0].Explanation.Components)-1]
+	if last.Signal != "verified" || last.Weight != 2 {
+		t.Errorf("extra signal component = %+v", last)
+	}
+}
@@ -1,47 +1,66 @@
 package ratelimiter
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
+type fixedWindow struct {
+	count int
+	start time.Time
+}
+
 type FixedWindowLimiter struct {
-	sync.RWMutex
-	clients map[string]int
+	sync.Mutex
+	clients map[string]*fixedWindow
 	limit   int
 	window  time.Duration
+	janitor *janitor
 }
 
 func NewFixedWindowLimiter(limit int, window time.Duration) *FixedWindowLimiter {
-	return &FixedWindowLimiter{
-		clients: make(map[string]int),
+	rl := &FixedWindowLimiter{
+		clients: make(map[string]*fixedWindow),
 		limit:   limit,
 		window:  window,
 	}
+	rl.janitor = startJanitor(window, rl.clean)
+	return rl
 }
 
-func (rl *FixedWindowLimiter) Allow(ip string) (bool, time.Duration) {
-	rl.RLock()
-	count, exists := rl.clients[ip]
-	rl.RUnlock()
+func (rl *FixedWindowLimiter) Allow(_ context.Context, key string) (Result, error) {
+	now := clock()
 
-	if !exists || count < rl.limit {
-		rl.Lock()
-		if !exists {
-			go rl.resetCount(ip)
-		}
-		rl.clients[ip]++
-		rl.Unlock()
-		return true, 0
+	rl.Lock()
+	defer rl.Unlock()
+
+	w, exists := rl.clients[key]
+	if !exists || now.Sub(w.start) >= rl.window {
+		w = &fixedWindow{start: now}
+		rl.clients[key] = w
 	}
 
-	return false, rl.window
+	reset := w.start.Add(rl.window).Sub(now)
+	if w.count >= rl.limit {
+		return Result{Limit: rl.limit, RetryAfter: reset, Reset: reset}, nil
+	}
+
+	w.count++
+	return Result{Allowed: true, Limit: rl.limit, Remaining: rl.limit - w.count, Reset: reset}, nil
+}
+
+// Stop detiene el janitor
+func (rl *FixedWindowLimiter) Stop() {
+	rl.janitor.Stop()
 }
 
-func (rl *FixedWindowLimiter) resetCount(ip string) string {
-	time.Sleep(rl.window)
+func (rl *FixedWindowLimiter) clean(now time.Time) {
 	rl.Lock()
-	delete(rl.clients, ip)
-	rl.Unlock()
-	return ip
+	defer rl.Unlock()
+	for key, w := range rl.clients {
+		if now.Sub(w.start) >= rl.window {
+			delete(rl.clients, key)
+		}
+	}
 }
@@ -0,0 +1,34 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+// janitor limpia periodicamente las claves vencidas de los limiters en memoria, asi no hace falta una
+// goroutine por clave
+type janitor struct {
+	stop chan struct{}
+	once sync.Once
+}
+
+func startJanitor(interval time.Duration, clean func(now time.Time)) *janitor {
+	j := &janitor{stop: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.stop:
+				return
+			case now := <-ticker.C:
+				clean(now)
+			}
+		}
+	}()
+	return j
+}
+
+func (j *janitor) Stop() {
+	j.once.Do(func() { close(j.stop) })
+}
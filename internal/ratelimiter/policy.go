@@ -0,0 +1,122 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Policy es un limite para un grupo de rutas. Los roles de RoleLimits tienen un limite propio
+// (por ejemplo mas alto para moderadores y admins); el resto usa Limit.
+type Policy struct {
+	Name       string
+	Limit      int
+	Window     time.Duration
+	RoleLimits map[string]int
+}
+
+// Rule asigna una policy a los requests con ese metodo (vacio para cualquiera) y path. Un path que
+// termina en "/*" matchea todo lo que esta debajo.
+type Rule struct {
+	Method string
+	Path   string
+	Policy string
+}
+
+func (r Rule) matches(method, path string) bool {
+	if r.Method != "" && r.Method != method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "/*"); ok {
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+	return strings.TrimSuffix(path, "/") == strings.TrimSuffix(r.Path, "/")
+}
+
+// Policies elige la policy de cada request y aplica su limite con un limiter por policy y rol
+type Policies struct {
+	policies map[string]Policy
+	rules    []Rule
+	fallback string
+	limiters map[string]Limiter
+}
+
+// NewPolicies crea un limiter (del algoritmo y backend de cfg) por cada policy y rol. Los requests que
+// no matchean ninguna regla usan la policy fallback.
+func NewPolicies(cfg Config, rdb *redis.Client, policies []Policy, rules []Rule, fallback string) (*Policies, error) {
+	p := &Policies{
+		policies: map[string]Policy{},
+		rules:    rules,
+		fallback: fallback,
+		limiters: map[string]Limiter{},
+	}
+
+	for _, policy := range policies {
+		p.policies[policy.Name] = policy
+
+		limits := map[string]int{"": policy.Limit}
+		for role, limit := range policy.RoleLimits {
+			limits[role] = limit
+		}
+		for role, limit := range limits {
+			c := cfg
+			c.RequestPerTimeFrame = limit
+			c.TimeFrame = policy.Window
+
+			l, err := New(c, rdb, limiterName(policy.Name, role))
+			if err != nil {
+				return nil, err
+			}
+			p.limiters[limiterName(policy.Name, role)] = l
+		}
+	}
+
+	if _, ok := p.policies[fallback]; !ok {
+		return nil, fmt.Errorf("rate limiter: unknown fallback policy %q", fallback)
+	}
+	for _, rule := range rules {
+		if _, ok := p.policies[rule.Policy]; !ok {
+			return nil, fmt.Errorf("rate limiter: unknown policy %q for %s %s", rule.Policy, rule.Method, rule.Path)
+		}
+	}
+	return p, nil
+}
+
+func limiterName(policy, role string) string {
+	if role == "" {
+		return policy
+	}
+	return policy + ":" + role
+}
+
+// Match devuelve la policy de la primera regla que matchea el request
+func (p *Policies) Match(method, path string) Policy {
+	for _, rule := range p.rules {
+		if rule.matches(method, path) {
+			return p.policies[rule.Policy]
+		}
+	}
+	return p.policies[p.fallback]
+}
+
+// Allow aplica la policy al cliente identificado por key. role es el rol del usuario autenticado,
+// vacio para requests anonimos.
+func (p *Policies) Allow(ctx context.Context, policy Policy, role, key string) (Result, error) {
+	name := policy.Name
+	if _, ok := policy.RoleLimits[role]; ok {
+		name = limiterName(policy.Name, role)
+	}
+	return p.limiters[name].Allow(ctx, key)
+}
+
+// Stop detiene los janitors de los limiters en memoria
+func (p *Policies) Stop() {
+	for _, l := range p.limiters {
+		if s, ok := l.(interface{ Stop() }); ok {
+			s.Stop()
+		}
+	}
+}
@@ -1,13 +1,83 @@
 package ratelimiter
 
-import "time"
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Algoritmos y backends que se pueden elegir por configuracion
+const (
+	AlgorithmFixedWindow   = "fixed_window"
+	AlgorithmSlidingWindow = "sliding_window"
+	AlgorithmTokenBucket   = "token_bucket"
+
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Result es la decision del limiter para un request
+type Result struct {
+	Allowed bool
+	Limit   int
+	// requests que quedan disponibles despues de este
+	Remaining int
+	// cuanto falta para poder volver a intentar, 0 si se permitio
+	RetryAfter time.Duration
+	// cuanto falta para que la cuota se recupere por completo
+	Reset time.Duration
+}
+
+// clock es el reloj de los limiters en memoria, los tests lo reemplazan para controlar el tiempo
+var clock = time.Now
 
 type Limiter interface {
-	Allow(ip string) (bool, time.Duration)
+	Allow(ctx context.Context, key string) (Result, error)
 }
 
 type Config struct {
 	RequestPerTimeFrame int
 	TimeFrame           time.Duration
 	Enabled             bool
+	Algorithm           string
+	Backend             string
+}
+
+// New crea el limiter del algoritmo y backend de cfg. El backend redis comparte la cuota entre todas las
+// instancias de la API; prefix separa las claves de limiters distintos.
+func New(cfg Config, rdb *redis.Client, prefix string) (Limiter, error) {
+	if cfg.RequestPerTimeFrame < 1 || cfg.TimeFrame <= 0 {
+		return nil, fmt.Errorf("rate limiter %q: limit and time frame must be positive", prefix)
+	}
+
+	switch cfg.Backend {
+	case BackendRedis:
+		if rdb == nil {
+			return nil, fmt.Errorf("rate limiter %q: redis backend requires redis to be enabled", prefix)
+		}
+		switch cfg.Algorithm {
+		case AlgorithmSlidingWindow:
+			return NewRedisSlidingWindowLimiter(rdb, prefix, cfg.RequestPerTimeFrame, cfg.TimeFrame), nil
+		case AlgorithmTokenBucket:
+			return NewRedisTokenBucketLimiter(rdb, prefix, cfg.RequestPerTimeFrame, cfg.TimeFrame), nil
+		}
+	case BackendMemory:
+		switch cfg.Algorithm {
+		case AlgorithmFixedWindow:
+			return NewFixedWindowLimiter(cfg.RequestPerTimeFrame, cfg.TimeFrame), nil
+		case AlgorithmSlidingWindow:
+			return NewSlidingWindowLimiter(cfg.RequestPerTimeFrame, cfg.TimeFrame), nil
+		case AlgorithmTokenBucket:
+			return NewTokenBucketLimiter(cfg.RequestPerTimeFrame, cfg.TimeFrame), nil
+		}
+	default:
+		return nil, fmt.Errorf("rate limiter %q: unknown backend %q", prefix, cfg.Backend)
+	}
+	return nil, fmt.Errorf("rate limiter %q: unsupported algorithm %q for backend %q", prefix, cfg.Algorithm, cfg.Backend)
+}
+
+func redisKey(prefix, key string) string {
+	return fmt.Sprintf("ratelimit:%s:%s", prefix, key)
 }
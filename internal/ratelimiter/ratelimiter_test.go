@@ -0,0 +1,332 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeClock fija el reloj de los limiters en epoch y devuelve una funcion para avanzarlo
+func fakeClock(t *testing.T) func(d time.Duration) {
+	t.Helper()
+	now := epoch
+	clock = func() time.Time { return now }
+	t.Cleanup(func() { clock = time.Now })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+// step es un request hecho after despues del anterior y la decision esperada
+type step struct {
+	after      time.Duration
+	key        string
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	reset      time.Duration
+}
+
+func run(t *testing.T, l Limiter, steps []step) {
+	t.Helper()
+	advance := fakeClock(t)
+	for i, s := range steps {
+		advance(s.after)
+		key := s.key
+		if key == "" {
+			key = "client"
+		}
+		res, err := l.Allow(context.Background(), key)
+		if err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		if res.Allowed != s.allowed || res.Remaining != s.remaining || res.RetryAfter != s.retryAfter || res.Reset != s.reset {
+			t.Errorf("step %d: got allowed=%v remaining=%d retry=%v reset=%v, want allowed=%v remaining=%d retry=%v reset=%v",
+				i, res.Allowed, res.Remaining, res.RetryAfter, res.Reset, s.allowed, s.remaining, s.retryAfter, s.reset)
+		}
+	}
+}
+
+func TestFixedWindowLimiter(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "blocks after the limit until the window ends",
+			steps: []step{
+				{allowed: true, remaining: 2, reset: 10 * time.Second},
+				{after: time.Second, allowed: true, remaining: 1, reset: 9 * time.Second},
+				{after: time.Second, allowed: true, remaining: 0, reset: 8 * time.Second},
+				{after: time.Second, allowed: false, remaining: 0, retryAfter: 7 * time.Second, reset: 7 * time.Second},
+			},
+		},
+		{
+			name: "last instant of the window is still blocked",
+			steps: []step{
+				{allowed: true, remaining: 2, reset: 10 * time.Second},
+				{allowed: true, remaining: 1, reset: 10 * time.Second},
+				{allowed: true, remaining: 0, reset: 10 * time.Second},
+				{after: 10*time.Second - time.Millisecond, allowed: false, retryAfter: time.Millisecond, reset: time.Millisecond},
+			},
+		},
+		{
+			name: "a new window starts exactly at the edge",
+			steps: []step{
+				{allowed: true, remaining: 2, reset: 10 * time.Second},
+				{allowed: true, remaining: 1, reset: 10 * time.Second},
+				{allowed: true, remaining: 0, reset: 10 * time.Second},
+				{after: 10 * time.Second, allowed: true, remaining: 2, reset: 10 * time.Second},
+			},
+		},
+		{
+			name: "keys have separate quotas",
+			steps: []step{
+				{key: "a", allowed: true, remaining: 2, reset: 10 * time.Second},
+				{key: "a", allowed: true, remaining: 1, reset: 10 * time.Second},
+				{key: "a", allowed: true, remaining: 0, reset: 10 * time.Second},
+				{key: "b", allowed: true, remaining: 2, reset: 10 * time.Second},
+				{key: "a", allowed: false, retryAfter: 10 * time.Second, reset: 10 * time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewFixedWindowLimiter(3, 10*time.Second)
+			defer l.Stop()
+			run(t, l, tt.steps)
+		})
+	}
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "retry after waits for the oldest request to leave the window",
+			steps: []step{
+				{allowed: true, remaining: 2, reset: 10 * time.Second},
+				{after: 2 * time.Second, allowed: true, remaining: 1, reset: 10 * time.Second},
+				{after: 2 * time.Second, allowed: true, remaining: 0, reset: 10 * time.Second},
+				{after: 2 * time.Second, allowed: false, retryAfter: 4 * time.Second, reset: 8 * time.Second},
+			},
+		},
+		{
+			name: "no burst of twice the limit at a fixed window edge",
+			steps: []step{
+				{after: 9 * time.Second, allowed: true, remaining: 2, reset: 10 * time.Second},
+				{allowed: true, remaining: 1, reset: 10 * time.Second},
+				{allowed: true, remaining: 0, reset: 10 * time.Second},
+				{after: time.Second, allowed: false, retryAfter: 9 * time.Second, reset: 9 * time.Second},
+			},
+		},
+		{
+			name: "a request leaves the window exactly window later",
+			steps: []step{
+				{allowed: true, remaining: 2, reset: 10 * time.Second},
+				{after: 5 * time.Second, allowed: true, remaining: 1, reset: 10 * time.Second},
+				{allowed: true, remaining: 0, reset: 10 * time.Second},
+				{after: 5*time.Second - time.Millisecond, allowed: false, retryAfter: time.Millisecond, reset: 5*time.Second + time.Millisecond},
+				{after: time.Millisecond, allowed: true, remaining: 0, reset: 10 * time.Second},
+				{allowed: false, retryAfter: 5 * time.Second, reset: 10 * time.Second},
+			},
+		},
+		{
+			name: "keys have separate quotas",
+			steps: []step{
+				{key: "a", allowed: true, remaining: 2, reset: 10 * time.Second},
+				{key: "a", allowed: true, remaining: 1, reset: 10 * time.Second},
+				{key: "a", allowed: true, remaining: 0, reset: 10 * time.Second},
+				{key: "b", allowed: true, remaining: 2, reset: 10 * time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewSlidingWindowLimiter(3, 10*time.Second)
+			defer l.Stop()
+			run(t, l, tt.steps)
+		})
+	}
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	// 4 tokens cada 2s: se recupera un token cada 500ms
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "allows a burst of the capacity",
+			steps: []step{
+				{allowed: true, remaining: 3, reset: 500 * time.Millisecond},
+				{allowed: true, remaining: 2, reset: time.Second},
+				{allowed: true, remaining: 1, reset: 1500 * time.Millisecond},
+				{allowed: true, remaining: 0, reset: 2 * time.Second},
+				{allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond, reset: 2 * time.Second},
+			},
+		},
+		{
+			name: "refills one token per interval",
+			steps: []step{
+				{allowed: true, remaining: 3, reset: 500 * time.Millisecond},
+				{allowed: true, remaining: 2, reset: time.Second},
+				{allowed: true, remaining: 1, reset: 1500 * time.Millisecond},
+				{allowed: true, remaining: 0, reset: 2 * time.Second},
+				{after: 200 * time.Millisecond, allowed: false, retryAfter: 300 * time.Millisecond, reset: 1800 * time.Millisecond},
+				{after: 300 * time.Millisecond, allowed: true, remaining: 0, reset: 2 * time.Second},
+				{after: 500 * time.Millisecond, allowed: true, remaining: 0, reset: 2 * time.Second},
+			},
+		},
+		{
+			name: "refill does not exceed the capacity",
+			steps: []step{
+				{allowed: true, remaining: 3, reset: 500 * time.Millisecond},
+				{after: time.Minute, allowed: true, remaining: 3, reset: 500 * time.Millisecond},
+				{allowed: true, remaining: 2, reset: time.Second},
+			},
+		},
+		{
+			name: "keys have separate buckets",
+			steps: []step{
+				{key: "a", allowed: true, remaining: 3, reset: 500 * time.Millisecond},
+				{key: "a", allowed: true, remaining: 2, reset: time.Second},
+				{key: "b", allowed: true, remaining: 3, reset: 500 * time.Millisecond},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewTokenBucketLimiter(4, 2*time.Second)
+			defer l.Stop()
+			run(t, l, tt.steps)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "memory fixed window", cfg: Config{RequestPerTimeFrame: 1, TimeFrame: time.Second, Backend: BackendMemory, Algorithm: AlgorithmFixedWindow}},
+		{name: "memory sliding window", cfg: Config{RequestPerTimeFrame: 1, TimeFrame: time.Second, Backend: BackendMemory, Algorithm: AlgorithmSlidingWindow}},
+		{name: "memory token bucket", cfg: Config{RequestPerTimeFrame: 1, TimeFrame: time.Second, Backend: BackendMemory, Algorithm: AlgorithmTokenBucket}},
+		{name: "zero limit", cfg: Config{TimeFrame: time.Second, Backend: BackendMemory, Algorithm: AlgorithmFixedWindow}, wantErr: true},
+		{name: "zero window", cfg: Config{RequestPerTimeFrame: 1, Backend: BackendMemory, Algorithm: AlgorithmFixedWindow}, wantErr: true},
+		{name: "unknown algorithm", cfg: Config{RequestPerTimeFrame: 1, TimeFrame: time.Second, Backend: BackendMemory, Algorithm: "leaky"}, wantErr: true},
+		{name: "unknown backend", cfg: Config{RequestPerTimeFrame: 1, TimeFrame: time.Second, Backend: "memcached", Algorithm: AlgorithmFixedWindow}, wantErr: true},
+		{name: "redis without client", cfg: Config{RequestPerTimeFrame: 1, TimeFrame: time.Second, Backend: BackendRedis, Algorithm: AlgorithmSlidingWindow}, wantErr: true},
+		{name: "redis fixed window", cfg: Config{RequestPerTimeFrame: 1, TimeFrame: time.Second, Backend: BackendRedis, Algorithm: AlgorithmFixedWindow}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := New(tt.cfg, nil, "test")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+			if s, ok := l.(interface{ Stop() }); ok {
+				s.Stop()
+			}
+		})
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		rule   Rule
+		method string
+		path   string
+		want   bool
+	}{
+		{Rule{Path: "/v1/posts"}, "GET", "/v1/posts", true},
+		{Rule{Path: "/v1/posts"}, "GET", "/v1/posts/", true},
+		{Rule{Path: "/v1/posts"}, "GET", "/v1/posts/1", false},
+		{Rule{Method: "POST", Path: "/v1/posts"}, "GET", "/v1/posts", false},
+		{Rule{Path: "/v1/auth/*"}, "POST", "/v1/auth", true},
+		{Rule{Path: "/v1/auth/*"}, "POST", "/v1/auth/token", true},
+		{Rule{Path: "/v1/auth/*"}, "POST", "/v1/authors", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rule.matches(tt.method, tt.path); got != tt.want {
+			t.Errorf("%+v matches(%s %s) = %v, want %v", tt.rule, tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	fakeClock(t)
+	cfg := Config{Backend: BackendMemory, Algorithm: AlgorithmFixedWindow}
+	policies := []Policy{
+		{Name: "default", Limit: 2, Window: time.Minute},
+		{Name: "writes", Limit: 1, Window: time.Minute, RoleLimits: map[string]int{"moderator": 3}},
+	}
+	rules := []Rule{{Method: "POST", Path: "/v1/posts/*", Policy: "writes"}}
+
+	p, err := NewPolicies(cfg, nil, policies, rules, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	if got := p.Match("POST", "/v1/posts/1/comments").Name; got != "writes" {
+		t.Errorf("POST match = %q, want writes", got)
+	}
+	if got := p.Match("GET", "/v1/posts/1").Name; got != "default" {
+		t.Errorf("GET match = %q, want default", got)
+	}
+
+	// cuenta cuantos requests seguidos permite la policy para el rol
+	allowed := func(policy Policy, role, key string) int {
+		n := 0
+		for range 10 {
+			res, err := p.Allow(context.Background(), policy, role, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.Allowed {
+				break
+			}
+			n++
+		}
+		return n
+	}
+
+	writes := p.Match("POST", "/v1/posts")
+	tests := []struct {
+		name   string
+		policy Policy
+		role   string
+		key    string
+		want   int
+	}{
+		{name: "anonymous uses the policy limit", policy: writes, role: "", key: "anon", want: 1},
+		{name: "role without its own limit uses the policy limit", policy: writes, role: "user", key: "user", want: 1},
+		{name: "role with its own limit", policy: writes, role: "moderator", key: "mod", want: 3},
+		{name: "policy without role limits", policy: p.Match("GET", "/"), role: "moderator", key: "mod", want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allowed(tt.policy, tt.role, tt.key); got != tt.want {
+				t.Errorf("allowed %d requests, want %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown policies are rejected", func(t *testing.T) {
+		if _, err := NewPolicies(cfg, nil, policies, rules, "missing"); err == nil {
+			t.Error("expected error for unknown fallback")
+		}
+		if _, err := NewPolicies(cfg, nil, policies, []Rule{{Path: "/", Policy: "missing"}}, "default"); err == nil {
+			t.Error("expected error for unknown rule policy")
+		}
+	})
+}
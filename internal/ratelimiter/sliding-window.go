@@ -0,0 +1,153 @@
+package ratelimiter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SlidingWindowLimiter guarda el momento de cada request permitido y deja pasar hasta limit en
+// cualquier intervalo de duracion window (sliding window log). A diferencia de la ventana fija no
+// permite rafagas de 2*limit en el borde entre dos ventanas.
+type SlidingWindowLimiter struct {
+	sync.Mutex
+	clients map[string][]time.Time
+	limit   int
+	window  time.Duration
+	janitor *janitor
+}
+
+func NewSlidingWindowLimiter(limit int, window time.Duration) *SlidingWindowLimiter {
+	rl := &SlidingWindowLimiter{
+		clients: make(map[string][]time.Time),
+		limit:   limit,
+		window:  window,
+	}
+	rl.janitor = startJanitor(window, rl.clean)
+	return rl
+}
+
+func (rl *SlidingWindowLimiter) Allow(_ context.Context, key string) (Result, error) {
+	now := clock()
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	log := expire(rl.clients[key], now.Add(-rl.window))
+
+	allowed := len(log) < rl.limit
+	if allowed {
+		log = append(log, now)
+	}
+	rl.clients[key] = log
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     rl.limit,
+		Remaining: rl.limit - len(log),
+		Reset:     log[len(log)-1].Add(rl.window).Sub(now),
+	}
+	if !allowed {
+		res.RetryAfter = log[0].Add(rl.window).Sub(now)
+	}
+	return res, nil
+}
+
+// Stop detiene el janitor
+func (rl *SlidingWindowLimiter) Stop() {
+	rl.janitor.Stop()
+}
+
+func (rl *SlidingWindowLimiter) clean(now time.Time) {
+	rl.Lock()
+	defer rl.Unlock()
+	for key, log := range rl.clients {
+		if log = expire(log, now.Add(-rl.window)); len(log) == 0 {
+			delete(rl.clients, key)
+		} else {
+			rl.clients[key] = log
+		}
+	}
+}
+
+// expire descarta del log los requests anteriores a since
+func expire(log []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(log) && !log[i].After(since) {
+		i++
+	}
+	return log[i:]
+}
+
+// slidingWindow es el sliding window log en un sorted set: el score es el momento del request en ms.
+// Usa el reloj de Redis para que todas las instancias vean el mismo tiempo.
+// Devuelve {permitido, restantes, retry after ms, reset ms}.
+var slidingWindow = redis.NewScript(`
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[3])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+
+local retry = 0
+if allowed == 0 then
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	retry = tonumber(oldest[2]) + window - now
+end
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+local reset = tonumber(newest[2]) + window - now
+
+return {allowed, limit - count, retry, reset}
+`)
+
+// RedisSlidingWindowLimiter es el sliding window log en Redis, la cuota se comparte entre instancias
+type RedisSlidingWindowLimiter struct {
+	rdb    *redis.Client
+	prefix string
+	limit  int
+	window time.Duration
+}
+
+func NewRedisSlidingWindowLimiter(rdb *redis.Client, prefix string, limit int, window time.Duration) *RedisSlidingWindowLimiter {
+	return &RedisSlidingWindowLimiter{rdb: rdb, prefix: prefix, limit: limit, window: window}
+}
+
+func (rl *RedisSlidingWindowLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	// dos requests en el mismo ms necesitan miembros distintos en el sorted set
+	member := make([]byte, 8)
+	if _, err := rand.Read(member); err != nil {
+		return Result{}, err
+	}
+
+	vals, err := slidingWindow.Run(ctx, rl.rdb, []string{redisKey(rl.prefix, key)},
+		rl.window.Milliseconds(), rl.limit, hex.EncodeToString(member)).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return scriptResult(vals, rl.limit), nil
+}
+
+// scriptResult arma el Result con la respuesta {permitido, restantes, retry after ms, reset ms} de un script
+func scriptResult(vals []int64, limit int) Result {
+	return Result{
+		Allowed:    vals[0] == 1,
+		Limit:      limit,
+		Remaining:  int(vals[1]),
+		RetryAfter: time.Duration(vals[2]) * time.Millisecond,
+		Reset:      time.Duration(vals[3]) * time.Millisecond,
+	}
+}
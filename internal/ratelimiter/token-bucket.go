@@ -0,0 +1,139 @@
+package ratelimiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TokenBucketLimiter le da a cada clave un balde de limit tokens que se rellena a razon de limit por
+// window. Cada request consume un token: permite rafagas de hasta limit y despues un ritmo constante.
+type TokenBucketLimiter struct {
+	sync.Mutex
+	buckets map[string]*bucket
+	limit   int
+	window  time.Duration
+	janitor *janitor
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucketLimiter(limit int, window time.Duration) *TokenBucketLimiter {
+	rl := &TokenBucketLimiter{
+		buckets: make(map[string]*bucket),
+		limit:   limit,
+		window:  window,
+	}
+	rl.janitor = startJanitor(window, rl.clean)
+	return rl
+}
+
+// rate devuelve los tokens que se recuperan por segundo
+func (rl *TokenBucketLimiter) rate() float64 {
+	return float64(rl.limit) / rl.window.Seconds()
+}
+
+func (rl *TokenBucketLimiter) Allow(_ context.Context, key string) (Result, error) {
+	now := clock()
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	b, exists := rl.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(rl.limit), last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(rl.limit), b.tokens+now.Sub(b.last).Seconds()*rl.rate())
+	b.last = now
+
+	res := Result{Limit: rl.limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = rl.refill(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = rl.refill(float64(rl.limit) - b.tokens)
+	return res, nil
+}
+
+// refill devuelve cuanto tarda en recuperarse la cantidad de tokens
+func (rl *TokenBucketLimiter) refill(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / rl.rate() * float64(time.Second)))
+}
+
+// Stop detiene el janitor
+func (rl *TokenBucketLimiter) Stop() {
+	rl.janitor.Stop()
+}
+
+// clean descarta los baldes que ya se llenaron: una clave nueva empieza con el balde lleno
+func (rl *TokenBucketLimiter) clean(now time.Time) {
+	rl.Lock()
+	defer rl.Unlock()
+	for key, b := range rl.buckets {
+		if now.Sub(b.last) >= rl.refill(float64(rl.limit)-b.tokens) {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// tokenBucket guarda el balde en un hash {tokens, ts}. Usa el reloj de Redis para que todas las
+// instancias vean el mismo tiempo. La clave expira cuando el balde se llenaria.
+// Devuelve {permitido, restantes, retry after ms, reset ms}.
+var tokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local rate = capacity / window
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or capacity
+local ts = tonumber(b[2]) or now
+tokens = math.min(capacity, tokens + math.max(now - ts, 0) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// RedisTokenBucketLimiter es el token bucket en Redis, la cuota se comparte entre instancias
+type RedisTokenBucketLimiter struct {
+	rdb    *redis.Client
+	prefix string
+	limit  int
+	window time.Duration
+}
+
+func NewRedisTokenBucketLimiter(rdb *redis.Client, prefix string, limit int, window time.Duration) *RedisTokenBucketLimiter {
+	return &RedisTokenBucketLimiter{rdb: rdb, prefix: prefix, limit: limit, window: window}
+}
+
+func (rl *RedisTokenBucketLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	vals, err := tokenBucket.Run(ctx, rl.rdb, []string{redisKey(rl.prefix, key)},
+		rl.limit, rl.window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return scriptResult(vals, rl.limit), nil
+}
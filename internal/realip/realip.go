@@ -0,0 +1,107 @@
+package realip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Trusted es la lista de proxies (el load balancer, el ingress) cuyos headers X-Forwarded-For y
+// X-Real-IP se aceptan. Un cliente cualquiera puede mandar esos headers, asi que solo valen si el
+// request llega desde uno de estos proxies.
+type Trusted []*net.IPNet
+
+// ParseTrusted parsea una lista de CIDRs o IPs sueltas, por ejemplo "10.0.0.0/8,127.0.0.1"
+func ParseTrusted(list []string) (Trusted, error) {
+	var trusted Trusted
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", s)
+		}
+		trusted = append(trusted, ipNet)
+	}
+	return trusted, nil
+}
+
+// contains dice si ip es uno de los proxies de confianza
+func (t Trusted) contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range t {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP devuelve la IP del cliente. Si el request viene de un proxy de confianza recorre
+// X-Forwarded-For de derecha a izquierda salteando los proxies de confianza, o usa X-Real-IP; si no,
+// usa la IP de la conexion.
+func (t Trusted) ClientIP(r *http.Request) string {
+	remote := hostOnly(r.RemoteAddr)
+	if !t.contains(remote) {
+		return remote
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				// un valor invalido corta la cadena: lo que sigue a la izquierda no es confiable
+				break
+			}
+			if !t.contains(hop) || i == 0 {
+				return hop
+			}
+		}
+	}
+	if xrip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xrip) != nil {
+		return xrip
+	}
+	return remote
+}
+
+// Middleware reemplaza RemoteAddr por la IP del cliente segun ClientIP, asi el rate limiter, el
+// access log y la auditoria ven la IP real. Reemplaza a middleware.RealIP de chi, que confia en los
+// headers de cualquier cliente.
+func Middleware(trusted Trusted) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := trusted.ClientIP(r); ip != hostOnly(r.RemoteAddr) {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hostOnly saca el puerto de una direccion host:port
+func hostOnly(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,110 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrusted(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []string
+		want    int
+		wantErr bool
+	}{
+		{name: "empty", in: nil, want: 0},
+		{name: "cidrs and ips", in: []string{"10.0.0.0/8", " 127.0.0.1 ", "::1", ""}, want: 3},
+		{name: "invalid ip", in: []string{"10.0.0"}, wantErr: true},
+		{name: "invalid cidr", in: []string{"10.0.0.0/33"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTrusted(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("got %d networks, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrusted([]string{"10.0.0.0/8", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{name: "no headers", remote: "203.0.113.7:5555", want: "203.0.113.7"},
+		{
+			name:    "untrusted client spoofing forwarded for",
+			remote:  "203.0.113.7:5555",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "untrusted client spoofing real ip",
+			remote:  "203.0.113.7:5555",
+			headers: map[string]string{"X-Real-IP": "1.2.3.4"},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "trusted proxy",
+			remote:  "10.0.0.2:5555",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "spoofed hop left of the client is ignored",
+			remote:  "10.0.0.2:5555",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "chain of trusted proxies",
+			remote:  "127.0.0.1:5555",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1, 10.1.1.1, 10.0.0.3"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "only trusted hops",
+			remote:  "127.0.0.1:5555",
+			headers: map[string]string{"X-Forwarded-For": "10.1.1.1, 10.0.0.3"},
+			want:    "10.1.1.1",
+		},
+		{
+			name:    "invalid hop stops the chain",
+			remote:  "10.0.0.2:5555",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4, garbage", "X-Real-IP": "198.51.100.9"},
+			want:    "198.51.100.9",
+		},
+		{
+			name:    "trusted proxy with real ip",
+			remote:  "10.0.0.2:5555",
+			headers: map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := trusted.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
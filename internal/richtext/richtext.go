@@ -0,0 +1,80 @@
+// Package richtext extrae las @menciones y los #hashtags del texto de posts y comentarios.
+package richtext
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const MaxTagLength = 100
+
+var (
+	// la mencion o el hashtag no pueden estar pegados a otra palabra (por ejemplo un email)
+	mentionRe = regexp.MustCompile(`(?:^|[^\w@])@([\w.\-]+)`)
+	hashtagRe = regexp.MustCompile(`(?:^|[^\w#&])#([\p{L}\p{N}_]+)`)
+)
+
+// Mentions devuelve los usernames mencionados, sin duplicados y en orden de aparicion
+func Mentions(text string) []string {
+	var usernames []string
+	seen := map[string]bool{}
+	for _, m := range mentionRe.FindAllStringSubmatch(text, -1) {
+		// el punto o guion final es puntuacion ("hola @juan.")
+		username := strings.TrimRight(m[1], ".-")
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
+
+// Hashtags devuelve los hashtags normalizados, sin duplicados y en orden de aparicion
+func Hashtags(text string) []string {
+	var tags []string
+	for _, m := range hashtagRe.FindAllStringSubmatch(text, -1) {
+		tags = appendTag(tags, m[1])
+	}
+	return tags
+}
+
+// NormalizeTag pasa el tag a minusculas y le saca el # inicial. Devuelve false si no es un tag valido.
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" || len(tag) > MaxTagLength {
+		return "", false
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '_' {
+			return "", false
+		}
+	}
+	return tag, true
+}
+
+// MergeTags une los tags explicitos con los hashtags del texto. Los tags invalidos se descartan.
+func MergeTags(tags []string, text string) []string {
+	merged := []string{}
+	for _, t := range tags {
+		merged = appendTag(merged, t)
+	}
+	for _, t := range Hashtags(text) {
+		merged = appendTag(merged, t)
+	}
+	return merged
+}
+
+func appendTag(tags []string, tag string) []string {
+	tag, ok := NormalizeTag(tag)
+	if !ok {
+		return tags
+	}
+	for _, t := range tags {
+		if t == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
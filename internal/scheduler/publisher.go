@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/marceterrone10/social/internal/store"
+	"go.uber.org/zap"
+)
+
+const (
+	publisherPollInterval = 15 * time.Second
+	publisherBatchSize    = 100
+)
+
+// Publisher publica los posts programados cuando llega su publish_at. Puede correr en todas las
+// instancias de la API a la vez: PublishDue usa SKIP LOCKED, asi que cada post se publica una sola vez.
+type Publisher struct {
+	posts     store.PostRepository
+	logger    *zap.SugaredLogger
+	onPublish []func(context.Context, *store.Post)
+}
+
+func NewPublisher(posts store.PostRepository, logger *zap.SugaredLogger) *Publisher {
+	return &Publisher{
+		posts:  posts,
+		logger: logger,
+	}
+}
+
+// OnPublish registra una funcion que se llama por cada post publicado por el scheduler
+func (p *Publisher) OnPublish(fn func(context.Context, *store.Post)) {
+	p.onPublish = append(p.onPublish, fn)
+}
+
+// Run publica los posts vencidos hasta que se cancele el contexto
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(publisherPollInterval)
+	defer ticker.Stop()
+
+	for {
+		p.publishDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Publisher) publishDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		posts, err := p.posts.PublishDue(ctx, publisherBatchSize)
+		if err != nil {
+			p.logger.Errorw("error publishing scheduled posts", "error", err)
+			return
+		}
+
+		for _, post := range posts {
+			p.logger.Infow("scheduled post published", "post_id", post.ID, "publish_at", post.PublishAt)
+			for _, fn := range p.onPublish {
+				fn(ctx, post)
+			}
+		}
+
+		if len(posts) < publisherBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Estados del procesamiento de imagenes de un adjunto
+const (
+	AttachmentStatusPending    = "pending"
+	AttachmentStatusProcessing = "processing"
+	AttachmentStatusReady      = "ready"
+	AttachmentStatusFailed     = "failed"
+	AttachmentStatusSkipped    = "skipped"
+)
+
+type Attachment struct {
+	ID         int64               `json:"id"`
+	PostID     int64               `json:"post_id"`
+	Position   int                 `json:"position"`
+	Kind       string              `json:"kind"`
+	MimeType   string              `json:"mime_type"`
+	StorageKey string              `json:"-"`
+	URL        string              `json:"url"`
+	SizeBytes  int64               `json:"size_bytes"`
+	Width      int                 `json:"width"`
+	Height     int                 `json:"height"`
+	AltText    string              `json:"alt_text"`
+	Status     string              `json:"status"`
+	Blurhash   string              `json:"blurhash"`
+	Variants   []AttachmentVariant `json:"variants"`
+	CreatedAt  string              `json:"created_at"`
+}
+
+// AttachmentVariant es una version redimensionada de la imagen. Estan ordenados por ancho
+// para que el cliente elija segun la densidad de pantalla.
+type AttachmentVariant struct {
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	MimeType   string `json:"mime_type"`
+	URL        string `json:"url"`
+	StorageKey string `json:"-"`
+}
+
+// variantRecord es como se guarda el variant en la columna jsonb (incluye la key del blob)
+type variantRecord struct {
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	MimeType   string `json:"mime_type"`
+	URL        string `json:"url"`
+	StorageKey string `json:"storage_key"`
+}
+
+const attachmentColumns = `id, post_id, position, kind, mime_type, storage_key, url, size_bytes, width, height, alt_text,
+	processing_status, blurhash, variants, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAttachment(row rowScanner) (*Attachment, error) {
+	var a Attachment
+	var variants []byte
+	err := row.Scan(
+		&a.ID, &a.PostID, &a.Position, &a.Kind, &a.MimeType, &a.StorageKey, &a.URL, &a.SizeBytes, &a.Width, &a.Height, &a.AltText,
+		&a.Status, &a.Blurhash, &variants, &a.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []variantRecord{}
+	if err := json.Unmarshal(variants, &records); err != nil {
+		return nil, err
+	}
+	a.Variants = make([]AttachmentVariant, len(records))
+	for i, v := range records {
+		a.Variants[i] = AttachmentVariant(v)
+	}
+
+	return &a, nil
+}
+
+type AttachmentsStore struct {
+	db *sql.DB
+}
+
+// Create agrega los adjuntos al final de la lista ordenada del post
+func (s *AttachmentsStore) Create(ctx context.Context, postID int64, attachments []*Attachment) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// bloqueamos el post para que dos uploads concurrentes no calculen la misma posicion
+		var locked int64
+		if err := tx.QueryRowContext(ctx, `SELECT id FROM posts WHERE id = $1 FOR UPDATE`, postID).Scan(&locked); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		var next int
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(position) + 1, 0) FROM post_attachments WHERE post_id = $1`, postID).Scan(&next)
+		if err != nil {
+			return err
+		}
+
+		query := `
+		INSERT INTO post_attachments (post_id, position, kind, mime_type, storage_key, url, size_bytes, width, height, alt_text, processing_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id, created_at
+		`
+		for _, a := range attachments {
+			a.PostID = postID
+			a.Position = next
+			next++
+			if a.Status == "" {
+				a.Status = AttachmentStatusPending
+			}
+			if a.Variants == nil {
+				a.Variants = []AttachmentVariant{}
+			}
+
+			err := tx.QueryRowContext(
+				ctx,
+				query,
+				a.PostID,
+				a.Position,
+				a.Kind,
+				a.MimeType,
+				a.StorageKey,
+				a.URL,
+				a.SizeBytes,
+				a.Width,
+				a.Height,
+				a.AltText,
+				a.Status,
+			).Scan(&a.ID, &a.CreatedAt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *AttachmentsStore) GetByPostId(ctx context.Context, postID int64) ([]Attachment, error) {
+	byPost, err := s.GetByPostIds(ctx, []int64{postID})
+	if err != nil {
+		return nil, err
+	}
+
+	if attachments, ok := byPost[postID]; ok {
+		return attachments, nil
+	}
+	return []Attachment{}, nil
+}
+
+// GetByPostIds trae los adjuntos de varios posts en una sola query (para el feed)
+func (s *AttachmentsStore) GetByPostIds(ctx context.Context, postIDs []int64) (map[int64][]Attachment, error) {
+	query := `SELECT ` + attachmentColumns + `
+	FROM post_attachments
+	WHERE post_id = ANY($1)
+	ORDER BY post_id, position
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byPost := map[int64][]Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		byPost[a.PostID] = append(byPost[a.PostID], *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return byPost, nil
+}
+
+func (s *AttachmentsStore) Delete(ctx context.Context, postID, attachmentID int64) (*Attachment, error) {
+	query := `DELETE FROM post_attachments WHERE id = $1 AND post_id = $2 RETURNING ` + attachmentColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	a, err := scanAttachment(s.db.QueryRowContext(ctx, query, attachmentID, postID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// ClaimPending marca como "processing" hasta limit adjuntos pendientes y los devuelve. Con SKIP LOCKED
+// varias instancias pueden procesar en paralelo sin tomar el mismo adjunto. Los que quedaron en
+// "processing" mas de staleAfter (por ejemplo porque se cayo la instancia) se vuelven a tomar.
+func (s *AttachmentsStore) ClaimPending(ctx context.Context, limit int, staleAfter time.Duration) ([]Attachment, error) {
+	query := `
+	UPDATE post_attachments SET processing_status = 'processing', processing_started_at = NOW()
+	WHERE id IN (
+		SELECT id FROM post_attachments
+		WHERE processing_status = 'pending'
+			OR (processing_status = 'processing' AND processing_started_at < NOW() - make_interval(secs => $2))
+		ORDER BY created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + attachmentColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit, staleAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// SaveVariants guarda el resultado del procesamiento y deja el adjunto como "ready"
+func (s *AttachmentsStore) SaveVariants(ctx context.Context, attachmentID int64, variants []AttachmentVariant, blurhash string) error {
+	records := make([]variantRecord, len(variants))
+	for i, v := range variants {
+		records[i] = variantRecord(v)
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE post_attachments
+	SET variants = $1, blurhash = $2, processing_status = 'ready', processing_error = ''
+	WHERE id = $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, data, blurhash, attachmentID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *AttachmentsStore) MarkFailed(ctx context.Context, attachmentID int64, reason string) error {
+	query := `UPDATE post_attachments SET processing_status = 'failed', processing_error = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, reason, attachmentID)
+	return err
+}
+
+// Reorder cambia el orden de los adjuntos segun la lista de ids recibida
+func (s *AttachmentsStore) Reorder(ctx context.Context, postID int64, ids []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var count int
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM post_attachments WHERE post_id = $1`, postID).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count != len(ids) {
+			return ErrInvalidAttachmentOrder
+		}
+
+		// movemos las posiciones a negativo para no chocar con el UNIQUE (post_id, position)
+		if _, err := tx.ExecContext(ctx, `UPDATE post_attachments SET position = -position - 1 WHERE post_id = $1`, postID); err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			res, err := tx.ExecContext(ctx, `UPDATE post_attachments SET position = $1 WHERE id = $2 AND post_id = $3`, i, id, postID)
+			if err != nil {
+				return err
+			}
+			if n, _ := res.RowsAffected(); n != 1 {
+				return ErrInvalidAttachmentOrder
+			}
+		}
+		return nil
+	})
+}
+
+var ErrInvalidAttachmentOrder = errors.New("attachment order must list every attachment of the post exactly once")
@@ -0,0 +1,267 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Acciones auditadas
+const (
+	AuditActionPostUpdate     = "post.update"
+	AuditActionPostDelete     = "post.delete"
+	AuditActionUserRoleChange = "user.role_change"
+	AuditActionUserPassword   = "user.password_change"
+	AuditActionUserSuspend    = "user.suspend"
+	AuditActionUserUnsuspend  = "user.unsuspend"
+	AuditActionLogin          = "auth.login"
+	AuditActionLoginFailed    = "auth.login_failed"
+)
+
+// Tipos de entidades auditadas
+const (
+	AuditTargetPost = "post"
+	AuditTargetUser = "user"
+)
+
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	ActorID    *int64          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int64           `json:"target_id"`
+	Diff       json.RawMessage `json:"diff" swaggertype:"object"`
+	IP         string          `json:"ip"`
+	RequestID  string          `json:"request_id"`
+	CreatedAt  string          `json:"created_at"`
+}
+
+// AuditDiff guarda solo los campos que cambiaron entre el estado anterior y el nuevo
+type AuditDiff struct {
+	Before map[string]any `json:"before"`
+	After  map[string]any `json:"after"`
+}
+
+// NewAuditDiff compara los campos de before y after y devuelve solo los que difieren
+func NewAuditDiff(before, after map[string]any) AuditDiff {
+	diff := AuditDiff{Before: map[string]any{}, After: map[string]any{}}
+	for k, v := range before {
+		if fmt.Sprint(v) != fmt.Sprint(after[k]) {
+			diff.Before[k] = v
+		}
+	}
+	for k, v := range after {
+		if fmt.Sprint(v) != fmt.Sprint(before[k]) {
+			diff.After[k] = v
+		}
+	}
+	return diff
+}
+
+// AuditContext son los datos del request que se adjuntan a cada evento de auditoria
+type AuditContext struct {
+	ActorID   int64
+	IP        string
+	RequestID string
+}
+
+type auditKey string
+
+const auditCtx auditKey = "audit"
+
+func WithAuditContext(ctx context.Context, ac AuditContext) context.Context {
+	return context.WithValue(ctx, auditCtx, ac)
+}
+
+func AuditContextFrom(ctx context.Context) AuditContext {
+	ac, _ := ctx.Value(auditCtx).(AuditContext)
+	return ac
+}
+
+type AuditFilter struct {
+	ActorID    int64      `json:"actor_id" validate:"gte=0"`
+	Action     string     `json:"action" validate:"max=100"`
+	TargetType string     `json:"target_type" validate:"max=50"`
+	TargetID   int64      `json:"target_id" validate:"gte=0"`
+	From       *time.Time `json:"from"`
+	To         *time.Time `json:"to"`
+	Limit      int        `json:"limit" validate:"gte=1,lte=10000"`
+	Offset     int        `json:"offset" validate:"gte=0"`
+}
+
+func (f *AuditFilter) ParseURLParams(r *http.Request) (AuditFilter, error) {
+	qs := r.URL.Query()
+
+	for key, dst := range map[string]*int64{"actor_id": &f.ActorID, "target_id": &f.TargetID} {
+		if v := qs.Get(key); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return *f, err
+			}
+			*dst = n
+		}
+	}
+
+	for key, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := qs.Get(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return *f, err
+			}
+			*dst = n
+		}
+	}
+
+	for key, dst := range map[string]**time.Time{"from": &f.From, "to": &f.To} {
+		if v := qs.Get(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return *f, err
+			}
+			*dst = &t
+		}
+	}
+
+	if v := qs.Get("action"); v != "" {
+		f.Action = v
+	}
+	if v := qs.Get("target_type"); v != "" {
+		f.TargetType = v
+	}
+
+	return *f, nil
+}
+
+type AuditStore struct {
+	db *sql.DB
+}
+
+// Create registra un evento que no acompaña a ningun cambio en la DB (por ejemplo un login)
+func (s *AuditStore) Create(ctx context.Context, event *AuditEvent) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return insertAuditEvent(ctx, tx, event)
+	})
+}
+
+func (s *AuditStore) List(ctx context.Context, f AuditFilter) ([]*AuditEvent, error) {
+	where := []string{}
+	args := []any{}
+
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.ActorID > 0 {
+		add("actor_id = $%d", f.ActorID)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID > 0 {
+		add("target_id = $%d", f.TargetID)
+	}
+	if f.From != nil {
+		add("created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("created_at < $%d", *f.To)
+	}
+
+	query := `SELECT id, actor_id, action, target_type, target_id, diff, ip, request_id, created_at FROM audit_events`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit, f.Offset)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+	for rows.Next() {
+		var e AuditEvent
+		var actorID sql.NullInt64
+		var diff []byte
+		if err := rows.Scan(&e.ID, &actorID, &e.Action, &e.TargetType, &e.TargetID, &diff, &e.IP, &e.RequestID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if actorID.Valid {
+			e.ActorID = &actorID.Int64
+		}
+		e.Diff = diff
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// insertAuditEvent escribe el evento dentro de la transacción del cambio que describe.
+// Los datos del request (actor, IP, request ID) se toman del contexto si el evento no los trae.
+func insertAuditEvent(ctx context.Context, tx *sql.Tx, event *AuditEvent) error {
+	ac := AuditContextFrom(ctx)
+	if event.ActorID == nil && ac.ActorID > 0 {
+		event.ActorID = &ac.ActorID
+	}
+	if event.IP == "" {
+		event.IP = ac.IP
+	}
+	if event.RequestID == "" {
+		event.RequestID = ac.RequestID
+	}
+	if len(event.Diff) == 0 {
+		event.Diff = json.RawMessage(`{}`)
+	}
+
+	query := `
+	INSERT INTO audit_events (actor_id, action, target_type, target_id, diff, ip, request_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		event.ActorID,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		[]byte(event.Diff),
+		event.IP,
+		event.RequestID,
+	).Scan(&event.ID, &event.CreatedAt)
+}
+
+// recordAudit arma el evento con el diff de before/after y lo inserta en la transacción
+func recordAudit(ctx context.Context, tx *sql.Tx, action, targetType string, targetID int64, before, after map[string]any) error {
+	diff, err := json.Marshal(NewAuditDiff(before, after))
+	if err != nil {
+		return err
+	}
+
+	return insertAuditEvent(ctx, tx, &AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Diff:       diff,
+	})
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type BlocksStore struct {
+	db *sql.DB
+}
+
+// Block hace que blockerID deje de ver el contenido de blockedID. Bloquear dos veces no hace nada.
+// Devuelve ErrNotFound si blockedID no existe.
+func (s *BlocksStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	query := `INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503": // foreign_key_violation
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BlocksStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// BlockedIDs devuelve los usuarios cuyo contenido no se le muestra a userID: los que bloqueo y los que lo bloquearon
+func (s *BlocksStore) BlockedIDs(ctx context.Context, userID int64) ([]int64, error) {
+	query := `
+	SELECT blocked_id FROM user_blocks WHERE blocker_id = $1
+	UNION
+	SELECT blocker_id FROM user_blocks WHERE blocked_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// IsBlocked dice si alguno de los dos usuarios bloqueo al otro
+func (s *BlocksStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM user_blocks
+		WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+	)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var blocked bool
+	err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked)
+	return blocked, err
+}
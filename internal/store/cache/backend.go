@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend guarda valores serializados por clave. Hay un backend en Redis (compartido entre instancias),
+// uno en memoria (LRU por instancia) y uno de dos niveles que combina ambos.
+type Backend interface {
+	// Get devuelve false si la clave no esta
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// ttlBackend es un Backend que tambien puede devolver cuanto le queda a la clave
+type ttlBackend interface {
+	// GetWithTTL devuelve false si la clave no esta. El TTL es 0 si la clave no expira.
+	GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool, error)
+}
+
+type RedisBackend struct {
+	rdb *redis.Client
+}
+
+func NewRedisBackend(rdb *redis.Client) *RedisBackend {
+	return &RedisBackend{rdb: rdb}
+}
+
+func (b *RedisBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := b.rdb.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (b *RedisBackend) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool, error) {
+	// en una transaccion: la clave no puede borrarse entre el GET y el PTTL
+	pipe := b.rdb.TxPipeline()
+	get := pipe.Get(ctx, key)
+	pttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, 0, false, err
+	}
+
+	data, err := get.Bytes()
+	if err == redis.Nil {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	// PTTL devuelve -1 si la clave no expira
+	return data, max(pttl.Val(), 0), true, nil
+}
+
+func (b *RedisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.rdb.SetEx(ctx, key, value, ttl).Err()
+}
+
+func (b *RedisBackend) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return b.rdb.Del(ctx, keys...).Err()
+}
+
+// getJSON lee la clave y la decodifica en v. Registra el hit o miss con el nombre del store.
+func getJSON(ctx context.Context, b Backend, m Metrics, name, key string, v any) (bool, error) {
+	data, ok, err := b.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		m.Miss(name)
+		return false, nil
+	}
+	m.Hit(name)
+	return true, json.Unmarshal(data, v)
+}
+
+func setJSON(ctx context.Context, b Backend, key string, v any, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Set(ctx, key, data, ttl)
+}
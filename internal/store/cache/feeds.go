@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/marceterrone10/social/internal/store"
+)
+
+// los contadores de comentarios y reacciones no invalidan el feed, quedan viejos como mucho este tiempo
+const FeedExpDuration = 30 * time.Second
+
+// FeedsStore cachea la primera pagina del feed cronologico de cada usuario, la que se pide al abrir la app
+type FeedsStore struct {
+	backend Backend
+	metrics Metrics
+}
+
+func feedKey(userID int64) string {
+	return fmt.Sprintf("feed-%v", userID)
+}
+
+// Get devuelve nil si la pagina no esta en cache
+func (s *FeedsStore) Get(ctx context.Context, userID int64) ([]*store.PostWithMetadata, error) {
+	posts := []*store.PostWithMetadata{}
+	ok, err := getJSON(ctx, s.backend, s.metrics, "feeds", feedKey(userID), &posts)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (s *FeedsStore) Set(ctx context.Context, userID int64, posts []*store.PostWithMetadata) error {
+	return setJSON(ctx, s.backend, feedKey(userID), posts, FeedExpDuration)
+}
+
+func (s *FeedsStore) Delete(ctx context.Context, userIDs ...int64) error {
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = feedKey(id)
+	}
+	return s.backend.Delete(ctx, keys...)
+}
@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sync"
+
+	"github.com/marceterrone10/social/internal/events"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// canal de pub/sub por el que las instancias se avisan que entradas invalidar
+const invalidationChannel = "cache:invalidate"
+
+// autores cuyos seguidores esperan que se invaliden sus feeds; si la cola se llena los feeds expiran con su TTL
+const fanoutQueueSize = 1000
+
+// Tipos de entradas que se invalidan
+const (
+	KindUser = "user"
+	KindPost = "post"
+	KindFeed = "feed"
+)
+
+type invalidation struct {
+	// instancia que invalido, no necesita aplicar su propio mensaje
+	Origin string  `json:"origin"`
+	Kind   string  `json:"kind"`
+	IDs    []int64 `json:"ids"`
+}
+
+// Invalidator borra entradas del cache cuando cambian los datos. Con Redis avisa al resto de las
+// instancias por pub/sub para que cada una borre su copia en memoria (L1).
+type Invalidator struct {
+	rdb     *redis.Client
+	storage Storage
+	follows store.FollowRepository
+	logger  *zap.SugaredLogger
+	id      string
+
+	// autores con feeds de seguidores por invalidar, los procesa Run fuera del request
+	fanout chan int64
+}
+
+func NewInvalidator(rdb *redis.Client, storage Storage, follows store.FollowRepository, logger *zap.SugaredLogger) *Invalidator {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return &Invalidator{
+		rdb:     rdb,
+		storage: storage,
+		follows: follows,
+		logger:  logger,
+		id:      hex.EncodeToString(id),
+		fanout:  make(chan int64, fanoutQueueSize),
+	}
+}
+
+// Invalidate borra las entradas y avisa al resto de las instancias. Los errores solo se loguean:
+// la entrada igual expira con su TTL.
+func (i *Invalidator) Invalidate(ctx context.Context, kind string, ids ...int64) {
+	if len(ids) == 0 {
+		return
+	}
+
+	if err := i.delete(ctx, kind, ids); err != nil {
+		i.logger.Errorw("error invalidating cache", "kind", kind, "ids", ids, "error", err)
+	}
+
+	if i.rdb == nil {
+		return
+	}
+
+	msg, err := json.Marshal(invalidation{Origin: i.id, Kind: kind, IDs: ids})
+	if err != nil {
+		return
+	}
+	if err := i.rdb.Publish(ctx, invalidationChannel, msg).Err(); err != nil {
+		i.logger.Errorw("error publishing cache invalidation", "kind", kind, "error", err)
+	}
+}
+
+func (i *Invalidator) delete(ctx context.Context, kind string, ids []int64) error {
+	switch kind {
+	case KindUser:
+		for _, id := range ids {
+			if err := i.storage.Users.Delete(ctx, id); err != nil {
+				return err
+			}
+		}
+	case KindPost:
+		return i.storage.Posts.Delete(ctx, ids...)
+	case KindFeed:
+		return i.storage.Feeds.Delete(ctx, ids...)
+	}
+	return nil
+}
+
+// Run invalida los feeds de los seguidores encolados y aplica las invalidaciones de las otras instancias
+// hasta que se cancele el contexto. Sin Redis hay una sola instancia y solo procesa los feeds.
+func (i *Invalidator) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Go(func() { i.runFanout(ctx) })
+	if i.rdb != nil {
+		i.subscribe(ctx)
+	}
+	wg.Wait()
+}
+
+func (i *Invalidator) runFanout(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case userID := <-i.fanout:
+			i.invalidateFollowerFeeds(ctx, userID)
+		}
+	}
+}
+
+// subscribe aplica las invalidaciones que publican las otras instancias
+func (i *Invalidator) subscribe(ctx context.Context) {
+	sub := i.rdb.Subscribe(ctx, invalidationChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var inv invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				i.logger.Warnw("invalid cache invalidation message", "error", err)
+				continue
+			}
+			if inv.Origin == i.id {
+				continue
+			}
+			if err := i.delete(ctx, inv.Kind, inv.IDs); err != nil {
+				i.logger.Errorw("error applying cache invalidation", "kind", inv.Kind, "error", err)
+			}
+		}
+	}
+}
+
+// HandleEvent se suscribe al bus de eventos e invalida los posts y feeds afectados. Los feeds de los
+// seguidores se invalidan en Run: un autor con muchos seguidores no demora la entrega del resto de los eventos.
+func (i *Invalidator) HandleEvent(ctx context.Context, e events.Event) {
+	switch e.Type {
+	case events.TypeComment, events.TypeReaction:
+		i.Invalidate(ctx, KindPost, e.PostID)
+	case events.TypePostPublished, events.TypePostDeleted, events.TypeRepost:
+		i.Invalidate(ctx, KindPost, e.PostID)
+		i.invalidateFeeds(ctx, e.ActorID)
+	case events.TypeFollow, events.TypeUnfollow:
+		i.Invalidate(ctx, KindFeed, e.ActorID)
+	}
+}
+
+// invalidateFeeds invalida el feed del usuario en el momento y encola los de sus seguidores
+func (i *Invalidator) invalidateFeeds(ctx context.Context, userID int64) {
+	i.Invalidate(ctx, KindFeed, userID)
+
+	select {
+	case i.fanout <- userID:
+	default:
+		i.logger.Warnw("feed invalidation queue full, skipping followers", "user_id", userID)
+	}
+}
+
+// invalidateFollowerFeeds invalida el feed de los seguidores del usuario
+func (i *Invalidator) invalidateFollowerFeeds(ctx context.Context, userID int64) {
+	followers, err := i.follows.Followers(ctx, userID)
+	if err != nil {
+		i.logger.Errorw("error loading followers to invalidate feeds", "user_id", userID, "error", err)
+		return
+	}
+
+	// en tandas para no mandar mensajes enormes por pub/sub
+	for chunk := range slices.Chunk(followers, 500) {
+		i.Invalidate(ctx, KindFeed, chunk...)
+	}
+}
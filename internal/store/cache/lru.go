@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU es un cache en memoria con una cantidad maxima de entradas. Al llenarse descarta la usada hace
+// mas tiempo; las entradas vencidas se descartan al leerlas.
+type LRU struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+func NewLRU(maxEntries int) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (c *LRU) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*lruEntry)
+	if time.Now().After(e.expiresAt) {
+		c.remove(el)
+		return nil, false, nil
+	}
+	c.ll.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (c *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value, e.expiresAt = value, expiresAt
+		c.ll.MoveToFront(el)
+		return nil
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.ll.Len() > c.maxEntries {
+		c.remove(c.ll.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+// Len devuelve la cantidad de entradas, incluidas las vencidas que todavia no se leyeron
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
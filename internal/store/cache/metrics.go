@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Metrics recibe cada hit y miss del cache, name es el store ("users", "posts", "feeds")
+type Metrics interface {
+	Hit(name string)
+	Miss(name string)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) Hit(string)  {}
+func (noopMetrics) Miss(string) {}
+
+type counters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// Stats cuenta hits y misses por store
+type Stats struct {
+	stores sync.Map
+}
+
+func NewStats() *Stats {
+	return &Stats{}
+}
+
+func (s *Stats) counters(name string) *counters {
+	c, _ := s.stores.LoadOrStore(name, &counters{})
+	return c.(*counters)
+}
+
+func (s *Stats) Hit(name string)  { s.counters(name).hits.Add(1) }
+func (s *Stats) Miss(name string) { s.counters(name).misses.Add(1) }
+
+// StoreStats es el resumen de un store
+type StoreStats struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// Snapshot devuelve los contadores de cada store
+func (s *Stats) Snapshot() map[string]StoreStats {
+	snapshot := map[string]StoreStats{}
+	s.stores.Range(func(k, v any) bool {
+		c := v.(*counters)
+		st := StoreStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+		if total := st.Hits + st.Misses; total > 0 {
+			st.HitRatio = float64(st.Hits) / float64(total)
+		}
+		snapshot[k.(string)] = st
+		return true
+	})
+	return snapshot
+}
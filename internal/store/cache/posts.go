@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/marceterrone10/social/internal/store"
+)
+
+// los variants de los adjuntos se procesan en segundo plano y no invalidan el cache, por eso un TTL corto
+const PostExpDuration = 5 * time.Minute
+
+// PostsStore cachea el post con sus comentarios, adjuntos y quote, tal como lo devuelve GET /posts/{id}
+type PostsStore struct {
+	backend Backend
+	metrics Metrics
+}
+
+func postKey(postID int64) string {
+	return fmt.Sprintf("post-%v", postID)
+}
+
+// Get devuelve nil si el post no esta en cache
+func (s *PostsStore) Get(ctx context.Context, postID int64) (*store.Post, error) {
+	var post store.Post
+	ok, err := getJSON(ctx, s.backend, s.metrics, "posts", postKey(postID), &post)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &post, nil
+}
+
+func (s *PostsStore) Set(ctx context.Context, post *store.Post) error {
+	return setJSON(ctx, s.backend, postKey(post.ID), post, PostExpDuration)
+}
+
+func (s *PostsStore) Delete(ctx context.Context, postIDs ...int64) error {
+	keys := make([]string, len(postIDs))
+	for i, id := range postIDs {
+		keys[i] = postKey(id)
+	}
+	return s.backend.Delete(ctx, keys...)
+}
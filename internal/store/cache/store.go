@@ -4,18 +4,35 @@ import (
 	"context"
 
 	"github.com/marceterrone10/social/internal/store"
-	"github.com/redis/go-redis/v9"
 )
 
 type Storage struct { // submodulo de storage para cache, estamos consumiendo de los repos de la DB
 	Users interface {
 		Get(context.Context, int64) (*store.User, error)
 		Set(context.Context, *store.User) error
+		SetNotFound(context.Context, int64) error
+		Delete(context.Context, int64) error
+	}
+	Posts interface {
+		Get(context.Context, int64) (*store.Post, error)
+		Set(context.Context, *store.Post) error
+		Delete(context.Context, ...int64) error
+	}
+	Feeds interface {
+		Get(context.Context, int64) ([]*store.PostWithMetadata, error)
+		Set(context.Context, int64, []*store.PostWithMetadata) error
+		Delete(context.Context, ...int64) error
 	}
 }
 
-func newRedisStorage(rdb *redis.Client) Storage { // constructor del storage para cache
+// NewStorage crea el storage para cache sobre el backend elegido. metrics puede ser nil.
+func NewStorage(backend Backend, metrics Metrics) Storage {
+	if metrics == nil {
+		metrics = noopMetrics{}
+	}
 	return Storage{
-		Users: &UsersStore{rdb: rdb},
+		Users: &UsersStore{backend: backend, metrics: metrics},
+		Posts: &PostsStore{backend: backend, metrics: metrics},
+		Feeds: &FeedsStore{backend: backend, metrics: metrics},
 	}
 }
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Tiered combina un L1 en memoria con un L2 compartido (Redis). Las lecturas que encuentran la clave en
+// el L2 la copian al L1. Las entradas del L1 viven como mucho l1TTL para acotar cuanto puede quedar
+// vieja una copia si se pierde un mensaje de invalidacion, y nunca mas que lo que le queda en el L2.
+type Tiered struct {
+	l1    *LRU
+	l2    Backend
+	l1TTL time.Duration
+}
+
+func NewTiered(l1 *LRU, l2 Backend, l1TTL time.Duration) *Tiered {
+	return &Tiered{l1: l1, l2: l2, l1TTL: l1TTL}
+}
+
+func (t *Tiered) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if data, ok, _ := t.l1.Get(ctx, key); ok {
+		return data, true, nil
+	}
+
+	l2, ok := t.l2.(ttlBackend)
+	if !ok {
+		data, ok, err := t.l2.Get(ctx, key)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		_ = t.l1.Set(ctx, key, data, t.l1TTL)
+		return data, true, nil
+	}
+
+	// la copia no puede durar mas que la entrada del L2, por ejemplo un "usuario no encontrado" de pocos segundos
+	data, ttl, ok, err := l2.GetWithTTL(ctx, key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	if ttl == 0 {
+		ttl = t.l1TTL
+	}
+	_ = t.l1.Set(ctx, key, data, min(ttl, t.l1TTL))
+	return data, true, nil
+}
+
+func (t *Tiered) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_ = t.l1.Set(ctx, key, value, min(ttl, t.l1TTL))
+	return t.l2.Set(ctx, key, value, ttl)
+}
+
+func (t *Tiered) Delete(ctx context.Context, keys ...string) error {
+	_ = t.l1.Delete(ctx, keys...)
+	return t.l2.Delete(ctx, keys...)
+}
@@ -2,46 +2,49 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/marceterrone10/social/internal/store"
-	"github.com/redis/go-redis/v9"
 )
 
-const UserExpDuration = 1 * time.Hour
+const (
+	UserExpDuration = 1 * time.Hour
+	// un usuario que no existe se recuerda poco tiempo, para que un id recien creado no quede bloqueado
+	UserNotFoundExpDuration = 1 * time.Minute
+)
 
 type UsersStore struct {
-	rdb *redis.Client
+	backend Backend
+	metrics Metrics
 }
 
-func (s *UsersStore) Get(ctx context.Context, userID int64) (*store.User, error) {
-	cacheKey := fmt.Sprintf("user-%v", userID)
+func userKey(userID int64) string {
+	return fmt.Sprintf("user-%v", userID)
+}
 
-	data, err := s.rdb.Get(ctx, cacheKey).Result()
-	if err != nil {
+// Get devuelve nil si el usuario no esta en cache y store.ErrNotFound si esta cacheado como inexistente
+func (s *UsersStore) Get(ctx context.Context, userID int64) (*store.User, error) {
+	var user *store.User
+	ok, err := getJSON(ctx, s.backend, s.metrics, "users", userKey(userID), &user)
+	if err != nil || !ok {
 		return nil, err
 	}
-
-	var user store.User
-	if data != "" {
-		err := json.Unmarshal([]byte(data), &user)
-		if err != nil {
-			return nil, err
-		}
+	if user == nil {
+		return nil, store.ErrNotFound
 	}
-
-	return &user, nil
+	return user, nil
 }
 
 func (s *UsersStore) Set(ctx context.Context, user *store.User) error {
-	cacheKey := fmt.Sprintf("user-%v", user.ID)
+	return setJSON(ctx, s.backend, userKey(user.ID), user, UserExpDuration)
+}
 
-	jsonData, err := json.Marshal(user)
-	if err != nil {
-		return err
-	}
+// SetNotFound cachea que el usuario no existe (negative caching)
+func (s *UsersStore) SetNotFound(ctx context.Context, userID int64) error {
+	return setJSON(ctx, s.backend, userKey(userID), nil, UserNotFoundExpDuration)
+}
 
-	return s.rdb.SetEx(ctx, cacheKey, jsonData, UserExpDuration).Err()
+func (s *UsersStore) Delete(ctx context.Context, userID int64) error {
+	return s.backend.Delete(ctx, userKey(userID))
 }
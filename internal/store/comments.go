@@ -3,6 +3,9 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+
+	"github.com/marceterrone10/social/internal/richtext"
 )
 
 type Comment struct {
@@ -10,8 +13,12 @@ type Comment struct {
 	PostID    int64  `json:"post_id"`
 	UserID    int64  `json:"user_id"`
 	Content   string `json:"content"`
+	Version   int    `json:"version"`
 	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
 	User      User   `json:"user"`
+	// usuarios mencionados por primera vez, a notificar despues de guardar
+	MentionedUserIDs []int64 `json:"-"`
 }
 
 type CommentsStore struct {
@@ -20,7 +27,7 @@ type CommentsStore struct {
 
 func (s *CommentsStore) GetByPostId(ctx context.Context, postId int64) (*[]Comment, error) {
 	query := `
-	SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.id, users.username, users.email 
+	SELECT c.id, c.post_id, c.user_id, c.content, c.version, c.created_at, c.updated_at, users.id, users.username, users.email
 	FROM comments c
 	JOIN users ON users.id = c.user_id
 	WHERE c.post_id = $1
@@ -37,9 +44,9 @@ func (s *CommentsStore) GetByPostId(ctx context.Context, postId int64) (*[]Comme
 
 	comments := []Comment{} // comments es un slice de los comentarios que se vamos a tener de la query
 	for rows.Next() {       // se va a ejecutar hasta que no haya más filas
-		var c Comment                                                                                                        // c de tipo Comment para almacenar los datos de los comentarios de la fila
-		c.User = User{}                                                                                                      // asignamos el usuario de tipo User para almacenar los datos del usuario de la fila
-		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt, &c.User.ID, &c.User.Username, &c.User.Email) // se scanean los datos de la fila y se asignan a las variables de c
+		var c Comment                                                                                                                                  // c de tipo Comment para almacenar los datos de los comentarios de la fila
+		c.User = User{}                                                                                                                                // asignamos el usuario de tipo User para almacenar los datos del usuario de la fila
+		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.Version, &c.CreatedAt, &c.UpdatedAt, &c.User.ID, &c.User.Username, &c.User.Email) // se scanean los datos de la fila y se asignan a las variables de c
 		if err != nil {
 			return nil, err
 		}
@@ -57,34 +64,103 @@ func (s *CommentsStore) GetByPostId(ctx context.Context, postId int64) (*[]Comme
 func (s *CommentsStore) Create(ctx context.Context, comment *Comment) error {
 	query :=
 		`
-	INSERT INTO comments (post_id, user_id, content) VALUES ($1, $2, $3) RETURNING id, created_at;
+	INSERT INTO comments (post_id, user_id, content) VALUES ($1, $2, $3) RETURNING id, version, created_at, updated_at;
 	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		comment.PostID,
-		comment.UserID,
-		comment.Content,
-	).Scan(&comment.ID, &comment.CreatedAt)
-	if err != nil {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			comment.PostID,
+			comment.UserID,
+			comment.Content,
+		).Scan(&comment.ID, &comment.Version, &comment.CreatedAt, &comment.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		userQuery :=
+			`
+		SELECT id, username, email FROM users WHERE id = $1;
+		`
+		comment.User = User{}
+		err = tx.QueryRowContext(
+			ctx,
+			userQuery,
+			comment.UserID,
+		).Scan(&comment.User.ID, &comment.User.Username, &comment.User.Email)
+		if err != nil {
+			return err
+		}
+
+		return syncCommentEntities(ctx, tx, comment)
+	})
+}
+
+// syncCommentEntities guarda los #hashtags y las @menciones del comentario
+func syncCommentEntities(ctx context.Context, tx *sql.Tx, comment *Comment) error {
+	if err := linkHashtags(ctx, tx, "comment_hashtags", "comment_id", comment.ID, richtext.Hashtags(comment.Content)); err != nil {
 		return err
 	}
 
-	userQuery :=
-		`
-	SELECT id, username, email FROM users WHERE id = $1;
+	var err error
+	comment.MentionedUserIDs, err = syncMentions(ctx, tx, comment.UserID, comment.PostID, &comment.ID, comment.Content)
+	return err
+}
+
+func (s *CommentsStore) GetById(ctx context.Context, id int64) (*Comment, error) {
+	query := `
+	SELECT c.id, c.post_id, c.user_id, c.content, c.version, c.created_at, c.updated_at, users.id, users.username, users.email
+	FROM comments c
+	JOIN users ON users.id = c.user_id
+	WHERE c.id = $1;
 	`
-	comment.User = User{}
-	err = s.db.QueryRowContext(
-		ctx,
-		userQuery,
-		comment.UserID,
-	).Scan(&comment.User.ID, &comment.User.Username, &comment.User.Email)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var c Comment
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.Version, &c.CreatedAt, &c.UpdatedAt, &c.User.ID, &c.User.Username, &c.User.Email)
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
 	}
-	return nil
+	return &c, nil
+}
 
+// Update cambia el contenido del comentario solo si comment.Version sigue siendo la version actual
+func (s *CommentsStore) Update(ctx context.Context, comment *Comment) error {
+	query := `
+	UPDATE comments SET content = $1, version = version + 1, updated_at = NOW()
+	WHERE id = $2 AND version = $3
+	RETURNING version, updated_at;
+	`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, comment.Content, comment.ID, comment.Version).Scan(&comment.Version, &comment.UpdatedAt)
+		if err == nil {
+			return syncCommentEntities(ctx, tx, comment)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// no se actualizo ninguna fila: o el comentario no existe o cambio la version
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1)`, comment.ID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	})
 }
@@ -0,0 +1,462 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Quien puede empezar una conversacion con un usuario
+const (
+	DMPolicyMutuals  = "mutuals"
+	DMPolicyEveryone = "everyone"
+)
+
+var DMPolicies = []string{DMPolicyMutuals, DMPolicyEveryone}
+
+// MaxConversationParticipants es el tamaño maximo de un grupo, contando al creador
+const MaxConversationParticipants = 10
+
+var (
+	ErrDMNotAllowed = errors.New("the user only accepts messages from mutual followers")
+	ErrDMBlocked    = errors.New("messages between these users are not allowed")
+)
+
+type Conversation struct {
+	ID           int64                     `json:"id"`
+	IsGroup      bool                      `json:"is_group"`
+	Title        *string                   `json:"title"`
+	CreatedBy    *int64                    `json:"created_by"`
+	Participants []ConversationParticipant `json:"participants"`
+	LastMessage  *Message                  `json:"last_message"`
+	// mensajes de los otros participantes posteriores a la ultima lectura del usuario
+	UnreadCount int       `json:"unread_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ConversationParticipant incluye la confirmacion de lectura del participante
+type ConversationParticipant struct {
+	UserID            int64      `json:"user_id"`
+	Username          string     `json:"username"`
+	LastReadMessageID *int64     `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at"`
+}
+
+type Message struct {
+	ID             int64     `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	SenderID       int64     `json:"sender_id"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type ConversationsStore struct {
+	db *sql.DB
+}
+
+// Create crea la conversacion entre el creador (c.CreatedBy) y recipientIDs. Si es 1:1 y ya
+// existe devuelve la existente con false. Cada destinatario tiene que seguir al creador y ser
+// seguido por el, salvo que su dm_policy sea 'everyone'.
+func (s *ConversationsStore) Create(ctx context.Context, c *Conversation, recipientIDs []int64) (bool, error) {
+	creatorID := *c.CreatedBy
+
+	var directKey *string
+	if !c.IsGroup {
+		key := fmt.Sprintf("%d:%d", min(creatorID, recipientIDs[0]), max(creatorID, recipientIDs[0]))
+		directKey = &key
+	}
+
+	created := false
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if directKey != nil {
+			err := tx.QueryRowContext(ctx, `SELECT id FROM conversations WHERE direct_key = $1`, *directKey).Scan(&c.ID)
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+
+		if err := s.checkRecipients(ctx, tx, creatorID, recipientIDs); err != nil {
+			return err
+		}
+
+		// si otra request creo la misma conversacion 1:1 en el medio, el conflicto devuelve esa
+		query := `
+		INSERT INTO conversations (is_group, title, direct_key, created_by) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (direct_key) DO UPDATE SET direct_key = EXCLUDED.direct_key
+		RETURNING id, xmax = 0
+		`
+		if err := tx.QueryRowContext(ctx, query, c.IsGroup, c.Title, directKey, creatorID).Scan(&c.ID, &created); err != nil {
+			return err
+		}
+		if !created {
+			return nil
+		}
+
+		query = `
+		INSERT INTO conversation_participants (conversation_id, user_id)
+		SELECT $1, unnest($2::bigint[])
+		`
+		_, err := tx.ExecContext(ctx, query, c.ID, pq.Array(append([]int64{creatorID}, recipientIDs...)))
+		return err
+	})
+	return created, err
+}
+
+// checkRecipients valida que existan los destinatarios, que no esten suspendidos ni haya un bloqueo
+// con el creador, y que acepten mensajes del creador
+func (s *ConversationsStore) checkRecipients(ctx context.Context, tx *sql.Tx, creatorID int64, recipientIDs []int64) error {
+	query := `
+	SELECT u.id, u.dm_policy = 'everyone' OR (
+		EXISTS (SELECT 1 FROM followers WHERE user_id = u.id AND follower_id = $1) AND
+		EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = u.id)
+	), EXISTS (
+		SELECT 1 FROM user_blocks
+		WHERE (blocker_id = $1 AND blocked_id = u.id) OR (blocker_id = u.id AND blocked_id = $1)
+	)
+	FROM users u
+	WHERE u.id = ANY($2) AND u.is_active AND u.suspended_at IS NULL
+	`
+	rows, err := tx.QueryContext(ctx, query, creatorID, pq.Array(recipientIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := 0
+	for rows.Next() {
+		var id int64
+		var allowed, blocked bool
+		if err := rows.Scan(&id, &allowed, &blocked); err != nil {
+			return err
+		}
+		if blocked {
+			return fmt.Errorf("%w (user %d)", ErrDMBlocked, id)
+		}
+		if !allowed {
+			return fmt.Errorf("%w (user %d)", ErrDMNotAllowed, id)
+		}
+		found++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if found != len(recipientIDs) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const conversationsQuery = `
+	SELECT c.id, c.is_group, c.title, c.created_by, c.created_at, c.updated_at,
+		(SELECT COUNT(*) FROM messages m
+		 WHERE m.conversation_id = c.id AND m.sender_id <> cp.user_id AND m.id > COALESCE(cp.last_read_message_id, 0)),
+		lm.id, lm.sender_id, lm.content, lm.created_at
+	FROM conversation_participants cp
+	JOIN conversations c ON c.id = cp.conversation_id
+	LEFT JOIN LATERAL (
+		SELECT id, sender_id, content, created_at FROM messages
+		WHERE conversation_id = c.id ORDER BY id DESC LIMIT 1
+	) lm ON true
+	`
+
+// List devuelve las conversaciones del usuario, la de actividad mas reciente primero
+func (s *ConversationsStore) List(ctx context.Context, userID int64, cursor *Cursor, limit int) ([]*Conversation, error) {
+	query := conversationsQuery + `
+	WHERE cp.user_id = $1 AND ($2::timestamptz IS NULL OR (c.updated_at, c.id) < ($2::timestamptz, $3))
+	ORDER BY c.updated_at DESC, c.id DESC
+	LIMIT $4
+	`
+
+	var after *time.Time
+	var afterID int64
+	if cursor != nil {
+		after = &cursor.UpdatedAt
+		afterID = cursor.ID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, after, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	conversations, err := scanConversations(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadParticipants(ctx, conversations); err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+// GetForUser devuelve la conversacion solo si el usuario participa de ella
+func (s *ConversationsStore) GetForUser(ctx context.Context, conversationID, userID int64) (*Conversation, error) {
+	query := conversationsQuery + `WHERE cp.user_id = $1 AND c.id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	conversations, err := scanConversations(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, ErrNotFound
+	}
+
+	if err := s.loadParticipants(ctx, conversations); err != nil {
+		return nil, err
+	}
+	return conversations[0], nil
+}
+
+func scanConversations(rows *sql.Rows) ([]*Conversation, error) {
+	defer rows.Close()
+
+	conversations := []*Conversation{}
+	for rows.Next() {
+		c := &Conversation{Participants: []ConversationParticipant{}}
+		var (
+			msgID, senderID sql.NullInt64
+			content         sql.NullString
+			sentAt          sql.NullTime
+		)
+		err := rows.Scan(
+			&c.ID, &c.IsGroup, &c.Title, &c.CreatedBy, &c.CreatedAt, &c.UpdatedAt,
+			&c.UnreadCount,
+			&msgID, &senderID, &content, &sentAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if msgID.Valid {
+			c.LastMessage = &Message{
+				ID:             msgID.Int64,
+				ConversationID: c.ID,
+				SenderID:       senderID.Int64,
+				Content:        content.String,
+				CreatedAt:      sentAt.Time,
+			}
+		}
+		conversations = append(conversations, c)
+	}
+	return conversations, rows.Err()
+}
+
+func (s *ConversationsStore) loadParticipants(ctx context.Context, conversations []*Conversation) error {
+	if len(conversations) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(conversations))
+	byID := make(map[int64]*Conversation, len(conversations))
+	for i, c := range conversations {
+		ids[i] = c.ID
+		byID[c.ID] = c
+	}
+
+	query := `
+	SELECT cp.conversation_id, u.id, u.username, cp.last_read_message_id, cp.last_read_at
+	FROM conversation_participants cp
+	JOIN users u ON u.id = cp.user_id
+	WHERE cp.conversation_id = ANY($1)
+	ORDER BY cp.conversation_id, cp.joined_at, u.id
+	`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var p ConversationParticipant
+		if err := rows.Scan(&id, &p.UserID, &p.Username, &p.LastReadMessageID, &p.LastReadAt); err != nil {
+			return err
+		}
+		byID[id].Participants = append(byID[id].Participants, p)
+	}
+	return rows.Err()
+}
+
+// CreateMessage guarda el mensaje, mueve la conversacion al principio de la bandeja y lo marca
+// como leido para quien lo envia. En una conversacion 1:1 devuelve ErrDMBlocked si uno de los dos
+// bloqueo al otro o si el otro esta suspendido; en los grupos los bloqueos no impiden escribir.
+func (s *ConversationsStore) CreateMessage(ctx context.Context, m *Message) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM conversations c
+			JOIN conversation_participants cp ON cp.conversation_id = c.id AND cp.user_id <> $2
+			JOIN users u ON u.id = cp.user_id
+			WHERE c.id = $1 AND NOT c.is_group AND (
+				u.suspended_at IS NOT NULL OR EXISTS (
+					SELECT 1 FROM user_blocks
+					WHERE (blocker_id = $2 AND blocked_id = u.id) OR (blocker_id = u.id AND blocked_id = $2)
+				)
+			)
+		)
+		`
+		var blocked bool
+		if err := tx.QueryRowContext(ctx, query, m.ConversationID, m.SenderID).Scan(&blocked); err != nil {
+			return err
+		}
+		if blocked {
+			return ErrDMBlocked
+		}
+
+		query = `INSERT INTO messages (conversation_id, sender_id, content) VALUES ($1, $2, $3) RETURNING id, created_at`
+		if err := tx.QueryRowContext(ctx, query, m.ConversationID, m.SenderID, m.Content).Scan(&m.ID, &m.CreatedAt); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE conversations SET updated_at = $2 WHERE id = $1`, m.ConversationID, m.CreatedAt); err != nil {
+			return err
+		}
+
+		query = `
+		UPDATE conversation_participants SET last_read_message_id = $3, last_read_at = $4
+		WHERE conversation_id = $1 AND user_id = $2
+		`
+		_, err := tx.ExecContext(ctx, query, m.ConversationID, m.SenderID, m.ID, m.CreatedAt)
+		return err
+	})
+}
+
+// ListMessages devuelve los mensajes del mas nuevo al mas viejo, anteriores a beforeID (0 para empezar por el ultimo)
+func (s *ConversationsStore) ListMessages(ctx context.Context, conversationID, beforeID int64, limit int) ([]*Message, error) {
+	query := `
+	SELECT id, conversation_id, sender_id, content, created_at
+	FROM messages
+	WHERE conversation_id = $1 AND ($2::bigint = 0 OR id < $2)
+	ORDER BY id DESC
+	LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, conversationID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []*Message{}
+	for rows.Next() {
+		m := &Message{}
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.SenderID, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// MarkRead mueve la confirmacion de lectura del usuario hasta messageID (0 para el ultimo mensaje).
+// La lectura nunca retrocede. Devuelve el ultimo mensaje leido, 0 si la conversacion no tiene mensajes.
+func (s *ConversationsStore) MarkRead(ctx context.Context, conversationID, userID, messageID int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var target sql.NullInt64
+	query := `SELECT MAX(id) FROM messages WHERE conversation_id = $1 AND ($2::bigint = 0 OR id = $2)`
+	if err := s.db.QueryRowContext(ctx, query, conversationID, messageID).Scan(&target); err != nil {
+		return 0, err
+	}
+	if !target.Valid {
+		if messageID != 0 {
+			return 0, ErrNotFound
+		}
+		return 0, nil
+	}
+
+	query = `
+	UPDATE conversation_participants
+	SET last_read_message_id = GREATEST(COALESCE(last_read_message_id, 0), $3),
+		last_read_at = NOW()
+	WHERE conversation_id = $1 AND user_id = $2
+	RETURNING last_read_message_id
+	`
+	var lastRead int64
+	err := s.db.QueryRowContext(ctx, query, conversationID, userID, target.Int64).Scan(&lastRead)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+	return lastRead, nil
+}
+
+// UnreadCount devuelve la cantidad de mensajes sin leer del usuario en todas sus conversaciones
+func (s *ConversationsStore) UnreadCount(ctx context.Context, userID int64) (int, error) {
+	query := `
+	SELECT COUNT(*)
+	FROM conversation_participants cp
+	JOIN messages m ON m.conversation_id = cp.conversation_id
+	WHERE cp.user_id = $1 AND m.sender_id <> $1 AND m.id > COALESCE(cp.last_read_message_id, 0)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+func (s *ConversationsStore) GetDMPolicy(ctx context.Context, userID int64) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var policy string
+	err := s.db.QueryRowContext(ctx, `SELECT dm_policy FROM users WHERE id = $1`, userID).Scan(&policy)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrNotFound
+		default:
+			return "", err
+		}
+	}
+	return policy, nil
+}
+
+func (s *ConversationsStore) SetDMPolicy(ctx context.Context, userID int64, policy string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `UPDATE users SET dm_policy = $2 WHERE id = $1`, userID, policy)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
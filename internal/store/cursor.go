@@ -0,0 +1,43 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor es la posicion del ultimo elemento de una pagina ordenada por (fecha, id) descendente
+type Cursor struct {
+	UpdatedAt time.Time
+	ID        int64
+}
+
+func (c Cursor) Encode() string {
+	raw := c.UpdatedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if c.UpdatedAt, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
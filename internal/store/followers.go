@@ -32,6 +32,66 @@ func (s *FollowsStore) Follow(ctx context.Context, userID, followerID int64) err
 	return nil
 }
 
+// Followers devuelve los ids de los usuarios que siguen a userID
+func (s *FollowsStore) Followers(ctx context.Context, userID int64) ([]int64, error) {
+	query := `SELECT user_id FROM followers WHERE follower_id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// FollowerCount devuelve la cantidad de seguidores de userID
+func (s *FollowsStore) FollowerCount(ctx context.Context, userID int64) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM followers WHERE follower_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+// FollowedWithMinFollowers devuelve los usuarios seguidos por userID que tienen al menos minFollowers seguidores
+func (s *FollowsStore) FollowedWithMinFollowers(ctx context.Context, userID int64, minFollowers int) ([]int64, error) {
+	query := `
+	SELECT f.follower_id
+	FROM followers f
+	WHERE f.user_id = $1 AND (SELECT COUNT(*) FROM followers c WHERE c.follower_id = f.follower_id) >= $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, minFollowers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 func (s *FollowsStore) Unfollow(ctx context.Context, userID, followerID int64) error {
 	query := `DELETE FROM followers WHERE user_id = $1 AND follower_id = $2`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
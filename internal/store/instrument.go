@@ -0,0 +1,683 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Observer recibe cada llamada a los repositorios, para medir latencias o abrir spans. Start se llama
+// antes de la consulta y la funcion que devuelve cuando termina, con el error de la consulta.
+type Observer interface {
+	Start(ctx context.Context, repository, method string) (context.Context, func(error))
+}
+
+// Observers combina varios observers en uno
+type Observers []Observer
+
+func (o Observers) Start(ctx context.Context, repository, method string) (context.Context, func(error)) {
+	dones := make([]func(error), len(o))
+	for i, obs := range o {
+		ctx, dones[i] = obs.Start(ctx, repository, method)
+	}
+	return ctx, func(err error) {
+		for i := len(dones) - 1; i >= 0; i-- {
+			dones[i](err)
+		}
+	}
+}
+
+// Instrument envuelve cada repositorio del storage para que sus llamadas pasen por obs
+func Instrument(s Storage, obs Observer) Storage {
+	return Storage{
+		Posts:         &instrumentedPost{s.Posts, obs},
+		Users:         &instrumentedUser{s.Users, obs},
+		Comments:      &instrumentedComment{s.Comments, obs},
+		Follows:       &instrumentedFollow{s.Follows, obs},
+		Roles:         &instrumentedRole{s.Roles, obs},
+		Audit:         &instrumentedAudit{s.Audit, obs},
+		Attachments:   &instrumentedAttachment{s.Attachments, obs},
+		Revisions:     &instrumentedRevision{s.Revisions, obs},
+		Reposts:       &instrumentedRepost{s.Reposts, obs},
+		Reactions:     &instrumentedReaction{s.Reactions, obs},
+		Notifications: &instrumentedNotification{s.Notifications, obs},
+		Conversations: &instrumentedConversation{s.Conversations, obs},
+		Ranking:       &instrumentedRanking{s.Ranking, obs},
+		Blocks:        &instrumentedBlock{s.Blocks, obs},
+		Trending:      &instrumentedTrending{s.Trending, obs},
+		Suggestions:   &instrumentedSuggestion{s.Suggestions, obs},
+	}
+}
+
+type instrumentedPost struct {
+	next PostRepository
+	obs  Observer
+}
+
+func (s *instrumentedPost) Create(ctx context.Context, post *Post) error {
+	ctx, done := s.obs.Start(ctx, "posts", "Create")
+	err := s.next.Create(ctx, post)
+	done(err)
+	return err
+}
+
+func (s *instrumentedPost) GetById(ctx context.Context, id int64) (*Post, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "GetById")
+	res, err := s.next.GetById(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedPost) GetByIds(ctx context.Context, ids []int64) (map[int64]*Post, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "GetByIds")
+	res, err := s.next.GetByIds(ctx, ids)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedPost) Delete(ctx context.Context, id int64) (*Post, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "Delete")
+	res, err := s.next.Delete(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedPost) Update(ctx context.Context, post *Post) (*Post, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "Update")
+	res, err := s.next.Update(ctx, post)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedPost) GetFeed(ctx context.Context, userId int64, fq PaginatedQuery) ([]*PostWithMetadata, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "GetFeed")
+	res, err := s.next.GetFeed(ctx, userId, fq)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedPost) GetFeedByIds(ctx context.Context, userID int64, ids []int64) ([]*PostWithMetadata, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "GetFeedByIds")
+	res, err := s.next.GetFeedByIds(ctx, userID, ids)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedPost) GetWithMetadataByIds(ctx context.Context, ids []int64) ([]*PostWithMetadata, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "GetWithMetadataByIds")
+	res, err := s.next.GetWithMetadataByIds(ctx, ids)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedPost) GetTimeline(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "GetTimeline")
+	res, err := s.next.GetTimeline(ctx, userID, limit)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedPost) GetAuthorsTimeline(ctx context.Context, authorIDs []int64, limit int) ([]TimelineEntry, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "GetAuthorsTimeline")
+	res, err := s.next.GetAuthorsTimeline(ctx, authorIDs, limit)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedPost) GetByTag(ctx context.Context, tag string, fq PaginatedQuery) ([]*PostWithMetadata, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "GetByTag")
+	res, err := s.next.GetByTag(ctx, tag, fq)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedPost) GetDrafts(ctx context.Context, userID int64, fq PaginatedQuery) ([]*Post, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "GetDrafts")
+	res, err := s.next.GetDrafts(ctx, userID, fq)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedPost) PublishDue(ctx context.Context, limit int) ([]*Post, error) {
+	ctx, done := s.obs.Start(ctx, "posts", "PublishDue")
+	res, err := s.next.PublishDue(ctx, limit)
+	done(err)
+	return res, err
+}
+
+type instrumentedUser struct {
+	next UserRepository
+	obs  Observer
+}
+
+func (s *instrumentedUser) Create(ctx context.Context, tx *sql.Tx, user *User) error {
+	ctx, done := s.obs.Start(ctx, "users", "Create")
+	err := s.next.Create(ctx, tx, user)
+	done(err)
+	return err
+}
+
+func (s *instrumentedUser) GetById(ctx context.Context, id int64) (*User, error) {
+	ctx, done := s.obs.Start(ctx, "users", "GetById")
+	res, err := s.next.GetById(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedUser) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, done := s.obs.Start(ctx, "users", "GetByEmail")
+	res, err := s.next.GetByEmail(ctx, email)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedUser) CreateInvitation(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
+	ctx, done := s.obs.Start(ctx, "users", "CreateInvitation")
+	err := s.next.CreateInvitation(ctx, user, token, invitationExp)
+	done(err)
+	return err
+}
+
+func (s *instrumentedUser) ActivateUser(ctx context.Context, token string) (int64, error) {
+	ctx, done := s.obs.Start(ctx, "users", "ActivateUser")
+	res, err := s.next.ActivateUser(ctx, token)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedUser) UpdateProfile(ctx context.Context, user *User) error {
+	ctx, done := s.obs.Start(ctx, "users", "UpdateProfile")
+	err := s.next.UpdateProfile(ctx, user)
+	done(err)
+	return err
+}
+
+func (s *instrumentedUser) Delete(ctx context.Context, userID int64) error {
+	ctx, done := s.obs.Start(ctx, "users", "Delete")
+	err := s.next.Delete(ctx, userID)
+	done(err)
+	return err
+}
+
+func (s *instrumentedUser) UpdateRole(ctx context.Context, userID int64, roleName string) error {
+	ctx, done := s.obs.Start(ctx, "users", "UpdateRole")
+	err := s.next.UpdateRole(ctx, userID, roleName)
+	done(err)
+	return err
+}
+
+func (s *instrumentedUser) UpdatePassword(ctx context.Context, user *User) error {
+	ctx, done := s.obs.Start(ctx, "users", "UpdatePassword")
+	err := s.next.UpdatePassword(ctx, user)
+	done(err)
+	return err
+}
+
+func (s *instrumentedUser) SetSuspended(ctx context.Context, userID int64, suspended bool) error {
+	ctx, done := s.obs.Start(ctx, "users", "SetSuspended")
+	err := s.next.SetSuspended(ctx, userID, suspended)
+	done(err)
+	return err
+}
+
+type instrumentedComment struct {
+	next CommentRepository
+	obs  Observer
+}
+
+func (s *instrumentedComment) GetByPostId(ctx context.Context, postId int64) (*[]Comment, error) {
+	ctx, done := s.obs.Start(ctx, "comments", "GetByPostId")
+	res, err := s.next.GetByPostId(ctx, postId)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedComment) GetById(ctx context.Context, id int64) (*Comment, error) {
+	ctx, done := s.obs.Start(ctx, "comments", "GetById")
+	res, err := s.next.GetById(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedComment) Create(ctx context.Context, comment *Comment) error {
+	ctx, done := s.obs.Start(ctx, "comments", "Create")
+	err := s.next.Create(ctx, comment)
+	done(err)
+	return err
+}
+
+func (s *instrumentedComment) Update(ctx context.Context, comment *Comment) error {
+	ctx, done := s.obs.Start(ctx, "comments", "Update")
+	err := s.next.Update(ctx, comment)
+	done(err)
+	return err
+}
+
+type instrumentedFollow struct {
+	next FollowRepository
+	obs  Observer
+}
+
+func (s *instrumentedFollow) Follow(ctx context.Context, userID int64, followerID int64) error {
+	ctx, done := s.obs.Start(ctx, "follows", "Follow")
+	err := s.next.Follow(ctx, userID, followerID)
+	done(err)
+	return err
+}
+
+func (s *instrumentedFollow) Unfollow(ctx context.Context, userID int64, followerID int64) error {
+	ctx, done := s.obs.Start(ctx, "follows", "Unfollow")
+	err := s.next.Unfollow(ctx, userID, followerID)
+	done(err)
+	return err
+}
+
+func (s *instrumentedFollow) Followers(ctx context.Context, userID int64) ([]int64, error) {
+	ctx, done := s.obs.Start(ctx, "follows", "Followers")
+	res, err := s.next.Followers(ctx, userID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedFollow) FollowerCount(ctx context.Context, userID int64) (int, error) {
+	ctx, done := s.obs.Start(ctx, "follows", "FollowerCount")
+	res, err := s.next.FollowerCount(ctx, userID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedFollow) FollowedWithMinFollowers(ctx context.Context, userID int64, minFollowers int) ([]int64, error) {
+	ctx, done := s.obs.Start(ctx, "follows", "FollowedWithMinFollowers")
+	res, err := s.next.FollowedWithMinFollowers(ctx, userID, minFollowers)
+	done(err)
+	return res, err
+}
+
+type instrumentedRole struct {
+	next RoleRepository
+	obs  Observer
+}
+
+func (s *instrumentedRole) GetByName(ctx context.Context, name string) (*Role, error) {
+	ctx, done := s.obs.Start(ctx, "roles", "GetByName")
+	res, err := s.next.GetByName(ctx, name)
+	done(err)
+	return res, err
+}
+
+type instrumentedAudit struct {
+	next AuditRepository
+	obs  Observer
+}
+
+func (s *instrumentedAudit) Create(ctx context.Context, event *AuditEvent) error {
+	ctx, done := s.obs.Start(ctx, "audit", "Create")
+	err := s.next.Create(ctx, event)
+	done(err)
+	return err
+}
+
+func (s *instrumentedAudit) List(ctx context.Context, f AuditFilter) ([]*AuditEvent, error) {
+	ctx, done := s.obs.Start(ctx, "audit", "List")
+	res, err := s.next.List(ctx, f)
+	done(err)
+	return res, err
+}
+
+type instrumentedAttachment struct {
+	next AttachmentRepository
+	obs  Observer
+}
+
+func (s *instrumentedAttachment) Create(ctx context.Context, postID int64, attachments []*Attachment) error {
+	ctx, done := s.obs.Start(ctx, "attachments", "Create")
+	err := s.next.Create(ctx, postID, attachments)
+	done(err)
+	return err
+}
+
+func (s *instrumentedAttachment) GetByPostId(ctx context.Context, postID int64) ([]Attachment, error) {
+	ctx, done := s.obs.Start(ctx, "attachments", "GetByPostId")
+	res, err := s.next.GetByPostId(ctx, postID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedAttachment) GetByPostIds(ctx context.Context, postIDs []int64) (map[int64][]Attachment, error) {
+	ctx, done := s.obs.Start(ctx, "attachments", "GetByPostIds")
+	res, err := s.next.GetByPostIds(ctx, postIDs)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedAttachment) Delete(ctx context.Context, postID int64, attachmentID int64) (*Attachment, error) {
+	ctx, done := s.obs.Start(ctx, "attachments", "Delete")
+	res, err := s.next.Delete(ctx, postID, attachmentID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedAttachment) Reorder(ctx context.Context, postID int64, ids []int64) error {
+	ctx, done := s.obs.Start(ctx, "attachments", "Reorder")
+	err := s.next.Reorder(ctx, postID, ids)
+	done(err)
+	return err
+}
+
+func (s *instrumentedAttachment) ClaimPending(ctx context.Context, limit int, staleAfter time.Duration) ([]Attachment, error) {
+	ctx, done := s.obs.Start(ctx, "attachments", "ClaimPending")
+	res, err := s.next.ClaimPending(ctx, limit, staleAfter)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedAttachment) SaveVariants(ctx context.Context, attachmentID int64, variants []AttachmentVariant, blurhash string) error {
+	ctx, done := s.obs.Start(ctx, "attachments", "SaveVariants")
+	err := s.next.SaveVariants(ctx, attachmentID, variants, blurhash)
+	done(err)
+	return err
+}
+
+func (s *instrumentedAttachment) MarkFailed(ctx context.Context, attachmentID int64, reason string) error {
+	ctx, done := s.obs.Start(ctx, "attachments", "MarkFailed")
+	err := s.next.MarkFailed(ctx, attachmentID, reason)
+	done(err)
+	return err
+}
+
+type instrumentedRevision struct {
+	next RevisionRepository
+	obs  Observer
+}
+
+func (s *instrumentedRevision) GetByPostId(ctx context.Context, postID int64) ([]PostRevision, error) {
+	ctx, done := s.obs.Start(ctx, "revisions", "GetByPostId")
+	res, err := s.next.GetByPostId(ctx, postID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedRevision) Get(ctx context.Context, postID int64, revision int) (*PostRevision, error) {
+	ctx, done := s.obs.Start(ctx, "revisions", "Get")
+	res, err := s.next.Get(ctx, postID, revision)
+	done(err)
+	return res, err
+}
+
+type instrumentedRepost struct {
+	next RepostRepository
+	obs  Observer
+}
+
+func (s *instrumentedRepost) Create(ctx context.Context, userID int64, postID int64) error {
+	ctx, done := s.obs.Start(ctx, "reposts", "Create")
+	err := s.next.Create(ctx, userID, postID)
+	done(err)
+	return err
+}
+
+func (s *instrumentedRepost) Delete(ctx context.Context, userID int64, postID int64) error {
+	ctx, done := s.obs.Start(ctx, "reposts", "Delete")
+	err := s.next.Delete(ctx, userID, postID)
+	done(err)
+	return err
+}
+
+type instrumentedReaction struct {
+	next ReactionRepository
+	obs  Observer
+}
+
+func (s *instrumentedReaction) Set(ctx context.Context, userID int64, postID int64, kind string) (bool, error) {
+	ctx, done := s.obs.Start(ctx, "reactions", "Set")
+	res, err := s.next.Set(ctx, userID, postID, kind)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedReaction) Delete(ctx context.Context, userID int64, postID int64) error {
+	ctx, done := s.obs.Start(ctx, "reactions", "Delete")
+	err := s.next.Delete(ctx, userID, postID)
+	done(err)
+	return err
+}
+
+type instrumentedNotification struct {
+	next NotificationRepository
+	obs  Observer
+}
+
+func (s *instrumentedNotification) Create(ctx context.Context, e NotificationEvent) (bool, error) {
+	ctx, done := s.obs.Start(ctx, "notifications", "Create")
+	res, err := s.next.Create(ctx, e)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedNotification) List(ctx context.Context, userID int64, cursor *Cursor, limit int) ([]*Notification, error) {
+	ctx, done := s.obs.Start(ctx, "notifications", "List")
+	res, err := s.next.List(ctx, userID, cursor, limit)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedNotification) UnreadCount(ctx context.Context, userID int64) (int, error) {
+	ctx, done := s.obs.Start(ctx, "notifications", "UnreadCount")
+	res, err := s.next.UnreadCount(ctx, userID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedNotification) MarkRead(ctx context.Context, userID int64, notificationID int64) error {
+	ctx, done := s.obs.Start(ctx, "notifications", "MarkRead")
+	err := s.next.MarkRead(ctx, userID, notificationID)
+	done(err)
+	return err
+}
+
+func (s *instrumentedNotification) MarkAllRead(ctx context.Context, userID int64) error {
+	ctx, done := s.obs.Start(ctx, "notifications", "MarkAllRead")
+	err := s.next.MarkAllRead(ctx, userID)
+	done(err)
+	return err
+}
+
+func (s *instrumentedNotification) GetPreferences(ctx context.Context, userID int64) (map[string]bool, error) {
+	ctx, done := s.obs.Start(ctx, "notifications", "GetPreferences")
+	res, err := s.next.GetPreferences(ctx, userID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedNotification) SetPreferences(ctx context.Context, userID int64, prefs map[string]bool) error {
+	ctx, done := s.obs.Start(ctx, "notifications", "SetPreferences")
+	err := s.next.SetPreferences(ctx, userID, prefs)
+	done(err)
+	return err
+}
+
+type instrumentedConversation struct {
+	next ConversationRepository
+	obs  Observer
+}
+
+func (s *instrumentedConversation) Create(ctx context.Context, c *Conversation, recipientIDs []int64) (bool, error) {
+	ctx, done := s.obs.Start(ctx, "conversations", "Create")
+	res, err := s.next.Create(ctx, c, recipientIDs)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedConversation) List(ctx context.Context, userID int64, cursor *Cursor, limit int) ([]*Conversation, error) {
+	ctx, done := s.obs.Start(ctx, "conversations", "List")
+	res, err := s.next.List(ctx, userID, cursor, limit)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedConversation) GetForUser(ctx context.Context, conversationID int64, userID int64) (*Conversation, error) {
+	ctx, done := s.obs.Start(ctx, "conversations", "GetForUser")
+	res, err := s.next.GetForUser(ctx, conversationID, userID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedConversation) CreateMessage(ctx context.Context, m *Message) error {
+	ctx, done := s.obs.Start(ctx, "conversations", "CreateMessage")
+	err := s.next.CreateMessage(ctx, m)
+	done(err)
+	return err
+}
+
+func (s *instrumentedConversation) ListMessages(ctx context.Context, conversationID int64, beforeID int64, limit int) ([]*Message, error) {
+	ctx, done := s.obs.Start(ctx, "conversations", "ListMessages")
+	res, err := s.next.ListMessages(ctx, conversationID, beforeID, limit)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedConversation) MarkRead(ctx context.Context, conversationID int64, userID int64, messageID int64) (int64, error) {
+	ctx, done := s.obs.Start(ctx, "conversations", "MarkRead")
+	res, err := s.next.MarkRead(ctx, conversationID, userID, messageID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedConversation) UnreadCount(ctx context.Context, userID int64) (int, error) {
+	ctx, done := s.obs.Start(ctx, "conversations", "UnreadCount")
+	res, err := s.next.UnreadCount(ctx, userID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedConversation) GetDMPolicy(ctx context.Context, userID int64) (string, error) {
+	ctx, done := s.obs.Start(ctx, "conversations", "GetDMPolicy")
+	res, err := s.next.GetDMPolicy(ctx, userID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedConversation) SetDMPolicy(ctx context.Context, userID int64, policy string) error {
+	ctx, done := s.obs.Start(ctx, "conversations", "SetDMPolicy")
+	err := s.next.SetDMPolicy(ctx, userID, policy)
+	done(err)
+	return err
+}
+
+type instrumentedRanking struct {
+	next RankingRepository
+	obs  Observer
+}
+
+func (s *instrumentedRanking) GetCandidates(ctx context.Context, userID int64, since time.Time, limit int) ([]*FeedCandidate, error) {
+	ctx, done := s.obs.Start(ctx, "ranking", "GetCandidates")
+	res, err := s.next.GetCandidates(ctx, userID, since, limit)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedRanking) GetInteractionProfile(ctx context.Context, userID int64, since time.Time) (*InteractionProfile, error) {
+	ctx, done := s.obs.Start(ctx, "ranking", "GetInteractionProfile")
+	res, err := s.next.GetInteractionProfile(ctx, userID, since)
+	done(err)
+	return res, err
+}
+
+type instrumentedBlock struct {
+	next BlockRepository
+	obs  Observer
+}
+
+func (s *instrumentedBlock) Block(ctx context.Context, blockerID int64, blockedID int64) error {
+	ctx, done := s.obs.Start(ctx, "blocks", "Block")
+	err := s.next.Block(ctx, blockerID, blockedID)
+	done(err)
+	return err
+}
+
+func (s *instrumentedBlock) Unblock(ctx context.Context, blockerID int64, blockedID int64) error {
+	ctx, done := s.obs.Start(ctx, "blocks", "Unblock")
+	err := s.next.Unblock(ctx, blockerID, blockedID)
+	done(err)
+	return err
+}
+
+func (s *instrumentedBlock) BlockedIDs(ctx context.Context, userID int64) ([]int64, error) {
+	ctx, done := s.obs.Start(ctx, "blocks", "BlockedIDs")
+	res, err := s.next.BlockedIDs(ctx, userID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedBlock) IsBlocked(ctx context.Context, userID int64, otherID int64) (bool, error) {
+	ctx, done := s.obs.Start(ctx, "blocks", "IsBlocked")
+	res, err := s.next.IsBlocked(ctx, userID, otherID)
+	done(err)
+	return res, err
+}
+
+type instrumentedTrending struct {
+	next TrendingRepository
+	obs  Observer
+}
+
+func (s *instrumentedTrending) TopPosts(ctx context.Context, window time.Duration, halfLife time.Duration, limit int) ([]TrendingPostScore, error) {
+	ctx, done := s.obs.Start(ctx, "trending", "TopPosts")
+	res, err := s.next.TopPosts(ctx, window, halfLife, limit)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedTrending) TopTags(ctx context.Context, window time.Duration, halfLife time.Duration, limit int) ([]TrendingTag, error) {
+	ctx, done := s.obs.Start(ctx, "trending", "TopTags")
+	res, err := s.next.TopTags(ctx, window, halfLife, limit)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedTrending) TagScoresByAuthors(ctx context.Context, window time.Duration, halfLife time.Duration, authorIDs []int64) ([]TrendingTag, error) {
+	ctx, done := s.obs.Start(ctx, "trending", "TagScoresByAuthors")
+	res, err := s.next.TagScoresByAuthors(ctx, window, halfLife, authorIDs)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedTrending) GetPostSignals(ctx context.Context, postID int64) (*PostSignals, error) {
+	ctx, done := s.obs.Start(ctx, "trending", "GetPostSignals")
+	res, err := s.next.GetPostSignals(ctx, postID)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedTrending) ExcludeSuspended(ctx context.Context, postIDs []int64) ([]int64, error) {
+	ctx, done := s.obs.Start(ctx, "trending", "ExcludeSuspended")
+	res, err := s.next.ExcludeSuspended(ctx, postIDs)
+	done(err)
+	return res, err
+}
+
+type instrumentedSuggestion struct {
+	next SuggestionRepository
+	obs  Observer
+}
+
+func (s *instrumentedSuggestion) Candidates(ctx context.Context, userID int64, since time.Time, limit int) ([]*SuggestionCandidate, error) {
+	ctx, done := s.obs.Start(ctx, "suggestions", "Candidates")
+	res, err := s.next.Candidates(ctx, userID, since, limit)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedSuggestion) Eligible(ctx context.Context, userID int64, ids []int64) ([]int64, error) {
+	ctx, done := s.obs.Start(ctx, "suggestions", "Eligible")
+	res, err := s.next.Eligible(ctx, userID, ids)
+	done(err)
+	return res, err
+}
+
+func (s *instrumentedSuggestion) Dismiss(ctx context.Context, userID int64, suggestedID int64) error {
+	ctx, done := s.obs.Start(ctx, "suggestions", "Dismiss")
+	err := s.next.Dismiss(ctx, userID, suggestedID)
+	done(err)
+	return err
+}
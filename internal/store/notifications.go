@@ -0,0 +1,303 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Tipos de notificacion. Cada tipo se puede desactivar en las preferencias del usuario.
+const (
+	NotificationTypeFollow   = "follow"
+	NotificationTypeComment  = "comment"
+	NotificationTypeMention  = "mention"
+	NotificationTypeReaction = "reaction"
+)
+
+var NotificationTypes = []string{
+	NotificationTypeFollow,
+	NotificationTypeComment,
+	NotificationTypeMention,
+	NotificationTypeReaction,
+}
+
+// cantidad de actores que se devuelven por notificacion, el resto solo se cuenta
+const notificationActorsShown = 3
+
+type Notification struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`
+	PostID    *int64 `json:"post_id"`
+	CommentID *int64 `json:"comment_id"`
+	// los ultimos actores del grupo, el primero es el mas reciente
+	Actors     []NotificationActor `json:"actors"`
+	ActorCount int                 `json:"actor_count"`
+	Message    string              `json:"message"`
+	Read       bool                `json:"read"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+type NotificationActor struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// NotificationEvent es un evento a notificar. Los eventos con el mismo GroupKey se agrupan
+// en una sola notificacion mientras no se lea.
+type NotificationEvent struct {
+	UserID    int64
+	ActorID   int64
+	Type      string
+	GroupKey  string
+	PostID    *int64
+	CommentID *int64
+}
+
+type NotificationsStore struct {
+	db *sql.DB
+}
+
+// Create agrega el actor al grupo sin leer del evento, o abre un grupo nuevo. Devuelve false sin
+// guardar nada si el usuario desactivo ese tipo de notificacion.
+func (s *NotificationsStore) Create(ctx context.Context, e NotificationEvent) (bool, error) {
+	enabled := true
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, `SELECT enabled FROM notification_preferences WHERE user_id = $1 AND type = $2`, e.UserID, e.Type).Scan(&enabled)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if !enabled {
+			return nil
+		}
+
+		// el indice unico parcial garantiza un solo grupo sin leer por clave aunque lleguen eventos concurrentes
+		query := `
+		INSERT INTO notifications (user_id, type, group_key, post_id, comment_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+		DO UPDATE SET updated_at = NOW(), comment_id = COALESCE(EXCLUDED.comment_id, notifications.comment_id)
+		RETURNING id
+		`
+		var id int64
+		if err := tx.QueryRowContext(ctx, query, e.UserID, e.Type, e.GroupKey, e.PostID, e.CommentID).Scan(&id); err != nil {
+			return err
+		}
+
+		query = `
+		INSERT INTO notification_actors (notification_id, actor_id) VALUES ($1, $2)
+		ON CONFLICT (notification_id, actor_id) DO UPDATE SET created_at = NOW()
+		`
+		if _, err := tx.ExecContext(ctx, query, id, e.ActorID); err != nil {
+			return err
+		}
+
+		query = `UPDATE notifications SET actor_count = (SELECT COUNT(*) FROM notification_actors WHERE notification_id = $1) WHERE id = $1`
+		_, err = tx.ExecContext(ctx, query, id)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// List devuelve las notificaciones del usuario de la mas reciente a la mas vieja, a partir del cursor (si no es nil).
+// El orden es por (updated_at, id): un grupo que suma un actor mientras el cliente pagina vuelve arriba de
+// todo, asi que no aparece en las paginas siguientes (el cursor ya lo dejo atras) sino en la primera pagina
+// de la proxima carga. Como updated_at solo crece, un grupo nunca se repite en dos paginas de la misma recorrida.
+func (s *NotificationsStore) List(ctx context.Context, userID int64, cursor *Cursor, limit int) ([]*Notification, error) {
+	query := `
+	SELECT id, type, post_id, comment_id, actor_count, read_at IS NOT NULL, created_at, updated_at
+	FROM notifications
+	WHERE user_id = $1 AND ($2::timestamptz IS NULL OR (updated_at, id) < ($2::timestamptz, $3))
+	ORDER BY updated_at DESC, id DESC
+	LIMIT $4
+	`
+
+	var after *time.Time
+	var afterID int64
+	if cursor != nil {
+		after = &cursor.UpdatedAt
+		afterID = cursor.ID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, after, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*Notification{}
+	byID := map[int64]*Notification{}
+	for rows.Next() {
+		n := &Notification{Actors: []NotificationActor{}}
+		if err := rows.Scan(&n.ID, &n.Type, &n.PostID, &n.CommentID, &n.ActorCount, &n.Read, &n.CreatedAt, &n.UpdatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+		byID[n.ID] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(notifications) == 0 {
+		return notifications, nil
+	}
+
+	ids := make([]int64, len(notifications))
+	for i, n := range notifications {
+		ids[i] = n.ID
+	}
+
+	query = `
+	SELECT na.notification_id, u.id, u.username
+	FROM notification_actors na
+	JOIN users u ON u.id = na.actor_id
+	WHERE na.notification_id = ANY($1)
+	ORDER BY na.notification_id, na.created_at DESC
+	`
+	actors, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer actors.Close()
+
+	for actors.Next() {
+		var id int64
+		var a NotificationActor
+		if err := actors.Scan(&id, &a.ID, &a.Username); err != nil {
+			return nil, err
+		}
+		if n := byID[id]; len(n.Actors) < notificationActorsShown {
+			n.Actors = append(n.Actors, a)
+		}
+	}
+	if err := actors.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, n := range notifications {
+		n.Message = n.summary()
+	}
+	return notifications, nil
+}
+
+// summary arma el texto de la notificacion, por ejemplo "juan and 4 others reacted to your post"
+func (n *Notification) summary() string {
+	var action string
+	switch n.Type {
+	case NotificationTypeFollow:
+		action = "followed you"
+	case NotificationTypeComment:
+		action = "commented on your post"
+	case NotificationTypeMention:
+		action = "mentioned you"
+	case NotificationTypeReaction:
+		action = "reacted to your post"
+	default:
+		action = n.Type
+	}
+
+	if len(n.Actors) == 0 {
+		return action
+	}
+
+	who := n.Actors[0].Username
+	switch others := n.ActorCount - 1; {
+	case others == 1:
+		who += " and 1 other"
+	case others > 1:
+		who += fmt.Sprintf(" and %d others", others)
+	}
+	return who + " " + action
+}
+
+func (s *NotificationsStore) UnreadCount(ctx context.Context, userID int64) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marca como leida una notificacion del usuario. Marcar una ya leida no hace nada.
+func (s *NotificationsStore) MarkRead(ctx context.Context, userID, notificationID int64) error {
+	query := `UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, notificationID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *NotificationsStore) MarkAllRead(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`, userID)
+	return err
+}
+
+// GetPreferences devuelve si cada tipo de notificacion esta activo. Por defecto estan todos activos.
+func (s *NotificationsStore) GetPreferences(ctx context.Context, userID int64) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT type, enabled FROM notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := map[string]bool{}
+	for _, t := range NotificationTypes {
+		prefs[t] = true
+	}
+	for rows.Next() {
+		var t string
+		var enabled bool
+		if err := rows.Scan(&t, &enabled); err != nil {
+			return nil, err
+		}
+		prefs[t] = enabled
+	}
+	return prefs, rows.Err()
+}
+
+func (s *NotificationsStore) SetPreferences(ctx context.Context, userID int64, prefs map[string]bool) error {
+	query := `
+	INSERT INTO notification_preferences (user_id, type, enabled) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled
+	`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		for t, enabled := range prefs {
+			if _, err := tx.ExecContext(ctx, query, userID, t, enabled); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -4,42 +4,154 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"slices"
+	"time"
 
 	"github.com/lib/pq"
+	"github.com/marceterrone10/social/internal/richtext"
+)
+
+// Estados de un post. Un draft con publish_at pasa a "scheduled" y el scheduler lo publica.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
 )
 
 type Post struct {
-	ID        int64     `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	UserID    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Comments  []Comment `json:"comments"`
-	User      User      `json:"user"`
+	ID            int64        `json:"id"`
+	Title         string       `json:"title"`
+	Content       string       `json:"content"`
+	UserID        int64        `json:"user_id"`
+	Tags          []string     `json:"tags"`
+	Status        string       `json:"status"`
+	PublishAt     *time.Time   `json:"publish_at"`
+	PublishedAt   *time.Time   `json:"published_at"`
+	Edited        bool         `json:"edited"`
+	EditedAt      *time.Time   `json:"edited_at"`
+	Version       int          `json:"version"`
+	QuotedPostID  *int64       `json:"quoted_post_id"`
+	QuotedPost    *QuotedPost  `json:"quoted_post,omitempty"`
+	RepostCount   int          `json:"repost_count"`
+	QuoteCount    int          `json:"quote_count"`
+	ReactionCount int          `json:"reaction_count"`
+	CreatedAt     string       `json:"created_at"`
+	UpdatedAt     string       `json:"updated_at"`
+	Comments      []Comment    `json:"comments"`
+	Attachments   []Attachment `json:"attachments"`
+	User          User         `json:"user"`
+	// usuarios a notificar por menciones despues de guardar el post (solo si esta publicado)
+	MentionedUserIDs []int64 `json:"-"`
+}
+
+// QuotedPost es el post citado por un quote. Si el original se borro o dejo de ser visible
+// queda como tombstone: solo el id y deleted en true.
+type QuotedPost struct {
+	ID        int64  `json:"id"`
+	Deleted   bool   `json:"deleted"`
+	Title     string `json:"title,omitempty"`
+	Content   string `json:"content,omitempty"`
+	UserID    int64  `json:"user_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// NewQuotedPost arma la cita de original, o el tombstone si original es nil o no esta publicado
+func NewQuotedPost(id int64, original *Post) *QuotedPost {
+	if original == nil || original.Status != PostStatusPublished {
+		return &QuotedPost{ID: id, Deleted: true}
+	}
+	return &QuotedPost{
+		ID:        original.ID,
+		Title:     original.Title,
+		Content:   original.Content,
+		UserID:    original.UserID,
+		Username:  original.User.Username,
+		CreatedAt: original.CreatedAt,
+	}
 }
 
 type PostWithMetadata struct {
 	Post
 	CommentCount int `json:"comment_count"`
+	// usuarios seguidos que repostearon el post, el post aparece una sola vez en el feed
+	RepostedBy []string `json:"reposted_by"`
 }
 
 type PostsStore struct {
 	db *sql.DB
 }
 
-func (s *PostsStore) GetFeed(ctx context.Context, userId int64, fq PaginatedQuery) ([]*PostWithMetadata, error) {
-	query := `
-	SELECT p.id, p.title, p.content, p.user_id, p.tags, p.created_at, p.updated_at, COUNT(c.id) as comment_count, u.id as user_id, u.username, u.email
+// columnas de un post, todas las queries usan el alias "p" para la tabla posts
+const postColumns = `p.id, p.title, p.content, p.user_id, p.tags, p.status, p.publish_at, p.published_at, p.edited_at, p.version,
+	p.quoted_post_id,
+	(SELECT COUNT(*) FROM reposts rp WHERE rp.post_id = p.id),
+	(SELECT COUNT(*) FROM posts qp WHERE qp.quoted_post_id = p.id AND qp.status = 'published'),
+	(SELECT COUNT(*) FROM post_reactions pr WHERE pr.post_id = p.id),
+	p.created_at, p.updated_at`
+
+// scanPost escanea las columnas de postColumns y a continuacion las extra
+func scanPost(row rowScanner, post *Post, extra ...any) error {
+	dest := []any{
+		&post.ID,
+		&post.Title,
+		&post.Content,
+		&post.UserID,
+		pq.Array(&post.Tags),
+		&post.Status,
+		&post.PublishAt,
+		&post.PublishedAt,
+		&post.EditedAt,
+		&post.Version,
+		&post.QuotedPostID,
+		&post.RepostCount,
+		&post.QuoteCount,
+		&post.ReactionCount,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	post.Edited = post.EditedAt != nil
+	return nil
+}
+
+// TimelineEntry es un post del home timeline y el momento en que entro (publicacion o repost)
+type TimelineEntry struct {
+	PostID int64
+	At     time.Time
+}
+
+// feedQuery arma la query del feed del usuario $1. Los reposts de los usuarios seguidos se agrupan
+// por post: si varios repostearon el mismo post aparece una sola vez, ordenado por el repost mas reciente.
+func feedQuery(filter, tail string) string {
+	return `
+	WITH feed_reposts AS (
+		SELECT r.post_id, MAX(r.created_at) AS reposted_at, array_agg(ru.username ORDER BY r.created_at DESC) AS reposted_by
+		FROM reposts r
+		JOIN users ru ON ru.id = r.user_id
+		WHERE r.user_id = $1 OR r.user_id IN (SELECT follower_id FROM followers WHERE user_id = $1)
+		GROUP BY r.post_id
+	)
+	SELECT ` + postColumns + `, COUNT(c.id) as comment_count, u.id as user_id, u.username, u.email, COALESCE(fr.reposted_by, '{}')
 	FROM posts p
+	LEFT JOIN feed_reposts fr ON fr.post_id = p.id
 	LEFT JOIN comments c ON c.post_id = p.id
 	JOIN users u ON u.id = p.user_id
-	WHERE p.user_id = $1 OR p.user_id IN (SELECT follower_id FROM followers WHERE user_id = $1)
-	GROUP BY p.id, u.id
-	ORDER BY p.created_at ` + fq.Sort + `
+	WHERE p.status = 'published'
+		AND (p.user_id = $1 OR p.user_id IN (SELECT follower_id FROM followers WHERE user_id = $1) OR fr.post_id IS NOT NULL)
+		` + filter + `
+	GROUP BY p.id, u.id, fr.reposted_by, fr.reposted_at
+	` + tail
+}
+
+func (s *PostsStore) GetFeed(ctx context.Context, userId int64, fq PaginatedQuery) ([]*PostWithMetadata, error) {
+	query := feedQuery("", `
+	ORDER BY GREATEST(p.published_at, fr.reposted_at) `+fq.Sort+`
 	LIMIT $2 OFFSET $3;
-	`
+	`)
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
@@ -47,25 +159,80 @@ func (s *PostsStore) GetFeed(ctx context.Context, userId int64, fq PaginatedQuer
 	if err != nil {
 		return nil, err
 	}
+	return scanFeed(rows)
+}
+
+// GetFeedByIds trae los posts ids tal como aparecen en el feed del usuario, en el orden de ids.
+// Los que ya no corresponden al feed (borrados, de usuarios que dejo de seguir) no se devuelven.
+func (s *PostsStore) GetFeedByIds(ctx context.Context, userID int64, ids []int64) ([]*PostWithMetadata, error) {
+	query := feedQuery("AND p.id = ANY($2)", "")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	posts, err := scanFeed(rows)
+	if err != nil {
+		return nil, err
+	}
+	return orderByIds(posts, ids), nil
+}
+
+// GetWithMetadataByIds trae los posts publicados ids con la cantidad de comentarios y el autor, en el orden de ids
+func (s *PostsStore) GetWithMetadataByIds(ctx context.Context, ids []int64) ([]*PostWithMetadata, error) {
+	query := `
+	SELECT ` + postColumns + `, (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id), u.id, u.username, u.email, '{}'::text[]
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE p.id = ANY($1) AND p.status = 'published'
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	posts, err := scanFeed(rows)
+	if err != nil {
+		return nil, err
+	}
+	return orderByIds(posts, ids), nil
+}
+
+func orderByIds(posts []*PostWithMetadata, ids []int64) []*PostWithMetadata {
+	byID := make(map[int64]*PostWithMetadata, len(posts))
+	for _, p := range posts {
+		byID[p.ID] = p
+	}
+	ordered := make([]*PostWithMetadata, 0, len(posts))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			ordered = append(ordered, p)
+		}
+	}
+	return ordered
+}
+
+func scanFeed(rows *sql.Rows) ([]*PostWithMetadata, error) {
 	defer rows.Close()
 
 	posts := []*PostWithMetadata{}
 	for rows.Next() {
 		var post PostWithMetadata
-		err :=
-			rows.Scan(
-				&post.Post.ID,
-				&post.Post.Title,
-				&post.Post.Content,
-				&post.Post.UserID,
-				pq.Array(&post.Post.Tags),
-				&post.Post.CreatedAt,
-				&post.Post.UpdatedAt,
-				&post.CommentCount,
-				&post.Post.User.ID,
-				&post.Post.User.Username,
-				&post.Post.User.Email,
-			)
+		err := scanPost(
+			rows,
+			&post.Post,
+			&post.CommentCount,
+			&post.Post.User.ID,
+			&post.Post.User.Username,
+			&post.Post.User.Email,
+			pq.Array(&post.RepostedBy),
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -75,45 +242,149 @@ func (s *PostsStore) GetFeed(ctx context.Context, userId int64, fq PaginatedQuer
 		return nil, err
 	}
 	return posts, nil
+}
 
+// GetTimeline arma desde Postgres las ultimas limit entradas del home timeline del usuario:
+// sus posts, los de los usuarios que sigue y los reposts de ambos
+func (s *PostsStore) GetTimeline(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error) {
+	query := `
+	SELECT post_id, MAX(at) AS at FROM (
+		(SELECT p.id AS post_id, p.published_at AS at
+		FROM posts p
+		WHERE p.status = 'published'
+			AND (p.user_id = $1 OR p.user_id IN (SELECT follower_id FROM followers WHERE user_id = $1))
+		ORDER BY p.published_at DESC
+		LIMIT $2)
+		UNION ALL
+		(SELECT r.post_id, r.created_at
+		FROM reposts r
+		JOIN posts p ON p.id = r.post_id AND p.status = 'published'
+		WHERE r.user_id = $1 OR r.user_id IN (SELECT follower_id FROM followers WHERE user_id = $1)
+		ORDER BY r.created_at DESC
+		LIMIT $2)
+	) t
+	GROUP BY post_id
+	ORDER BY at DESC
+	LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanTimeline(rows)
 }
 
-func (s *PostsStore) Create(ctx context.Context, post *Post) error { // se pasa contexto para que se pueda cancelar la operación si el contexto es cancelado
-	query := `INSERT INTO posts (title, content, user_id, tags) 
-	VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at;
+// GetAuthorsTimeline devuelve las ultimas limit entradas de los autores: sus posts publicados y sus reposts
+func (s *PostsStore) GetAuthorsTimeline(ctx context.Context, authorIDs []int64, limit int) ([]TimelineEntry, error) {
+	query := `
+	SELECT post_id, MAX(at) AS at FROM (
+		(SELECT p.id AS post_id, p.published_at AS at
+		FROM posts p
+		WHERE p.user_id = ANY($1) AND p.status = 'published'
+		ORDER BY p.published_at DESC
+		LIMIT $2)
+		UNION ALL
+		(SELECT r.post_id, r.created_at
+		FROM reposts r
+		JOIN posts p ON p.id = r.post_id AND p.status = 'published'
+		WHERE r.user_id = ANY($1)
+		ORDER BY r.created_at DESC
+		LIMIT $2)
+	) t
+	GROUP BY post_id
+	ORDER BY at DESC
+	LIMIT $2
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := s.db.QueryRowContext( // si pasamos contexto al la funcion, tenemos que usar QueryRowContext en lugar de QueryRow
-		ctx,
-		query,
-		post.Title,
-		post.Content,
-		post.UserID,
-		pq.Array(post.Tags),
-	).Scan(
-		&post.ID,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(authorIDs), limit)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return scanTimeline(rows)
+}
+
+func scanTimeline(rows *sql.Rows) ([]TimelineEntry, error) {
+	defer rows.Close()
+
+	entries := []TimelineEntry{}
+	for rows.Next() {
+		var e TimelineEntry
+		if err := rows.Scan(&e.PostID, &e.At); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *PostsStore) Create(ctx context.Context, post *Post) error { // se pasa contexto para que se pueda cancelar la operación si el contexto es cancelado
+	query := `INSERT INTO posts (title, content, user_id, tags, status, publish_at, quoted_post_id, published_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $5::text = 'published' THEN NOW() END) RETURNING id, published_at, version, created_at, updated_at;
+	`
+
+	if post.Status == "" {
+		post.Status = PostStatusPublished
+	}
+
+	// los #hashtags del texto se suman a los tags explicitos
+	post.Tags = richtext.MergeTags(post.Tags, post.text())
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+		err := tx.QueryRowContext( // si pasamos contexto al la funcion, tenemos que usar QueryRowContext en lugar de QueryRow
+			ctx,
+			query,
+			post.Title,
+			post.Content,
+			post.UserID,
+			pq.Array(post.Tags),
+			post.Status,
+			post.PublishAt,
+			post.QuotedPostID,
+		).Scan(
+			&post.ID,
+			&post.PublishedAt,
+			&post.Version,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		// la version inicial es la primera revision
+		if err := insertRevision(ctx, tx, post, post.UserID); err != nil {
+			return err
+		}
+
+		if err := linkHashtags(ctx, tx, "post_hashtags", "post_id", post.ID, post.Tags); err != nil {
+			return err
+		}
+
+		post.MentionedUserIDs, err = syncMentions(ctx, tx, post.UserID, post.ID, nil, post.text())
+		return err
+	})
 }
 
 func (s *PostsStore) GetById(ctx context.Context, id int64) (*Post, error) {
 	var post Post
-	query := `SELECT id, title, content, user_id, tags, created_at, updated_at FROM posts WHERE id = $1;`
+	query := `SELECT ` + postColumns + ` FROM posts p WHERE p.id = $1;`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := s.db.QueryRowContext(
+	err := scanPost(s.db.QueryRowContext(
 		ctx,
 		query,
 		id,
-	).Scan(&post.ID, &post.Title, &post.Content, &post.UserID, pq.Array(&post.Tags), &post.CreatedAt, &post.UpdatedAt)
+	), &post)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -126,25 +397,58 @@ func (s *PostsStore) GetById(ctx context.Context, id int64) (*Post, error) {
 	return &post, nil
 }
 
-func (s *PostsStore) Delete(ctx context.Context, id int64) (*Post, error) {
-	var post Post
-	query := `DELETE FROM posts WHERE id = $1 RETURNING id, title, content, user_id, tags, created_at, updated_at;`
+// GetByIds trae varios posts con el username del autor, indexados por id. Los ids que no existen no estan en el map.
+func (s *PostsStore) GetByIds(ctx context.Context, ids []int64) (map[int64]*Post, error) {
+	query := `
+	SELECT ` + postColumns + `, u.id, u.username
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE p.id = ANY($1)
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		id,
-	).Scan(
-		&post.ID,
-		&post.Title,
-		&post.Content,
-		&post.UserID,
-		pq.Array(&post.Tags),
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := map[int64]*Post{}
+	for rows.Next() {
+		var p Post
+		if err := scanPost(rows, &p, &p.User.ID, &p.User.Username); err != nil {
+			return nil, err
+		}
+		posts[p.ID] = &p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+func (s *PostsStore) Delete(ctx context.Context, id int64) (*Post, error) {
+	var post Post
+	query := `DELETE FROM posts p WHERE p.id = $1 RETURNING ` + postColumns + `;`
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+		err := scanPost(tx.QueryRowContext(
+			ctx,
+			query,
+			id,
+		), &post)
+		if err != nil {
+			return err
+		}
+
+		// el evento de auditoria se escribe en la misma transacción que el delete
+		return recordAudit(ctx, tx, AuditActionPostDelete, AuditTargetPost, post.ID, post.auditFields(), nil)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -157,18 +461,215 @@ func (s *PostsStore) Delete(ctx context.Context, id int64) (*Post, error) {
 }
 
 func (s *PostsStore) Update(ctx context.Context, post *Post) (*Post, error) {
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// bloqueamos la fila para leer el estado anterior del post
+		before := Post{}
+		err := tx.QueryRowContext(
+			ctx,
+			`SELECT id, title, content, user_id, tags, status, publish_at, version FROM posts WHERE id = $1 FOR UPDATE`,
+			post.ID,
+		).Scan(&before.ID, &before.Title, &before.Content, &before.UserID, pq.Array(&before.Tags), &before.Status, &before.PublishAt, &before.Version)
+		if err != nil {
+			return err
+		}
+
+		// post.Version es la version que leyo el cliente, si cambio otro request lo edito en el medio
+		if before.Version != post.Version {
+			return ErrVersionConflict
+		}
+
+		// un post publicado no puede volver a ser draft
+		if before.Status == PostStatusPublished && post.Status != PostStatusPublished {
+			return ErrPostAlreadyPublished
+		}
+
+		post.Tags = richtext.MergeTags(post.Tags, post.text())
+
+		contentChanged := before.Title != post.Title || before.Content != post.Content || !slices.Equal(before.Tags, post.Tags)
+
+		// solo se marca como editado si cambia el contenido de un post ya publicado
+		query := `
+		UPDATE posts p
+		SET title = $1, content = $2, tags = $3, status = $4, publish_at = $5,
+			published_at = CASE WHEN $4::text = 'published' THEN COALESCE(p.published_at, NOW()) END,
+			edited_at = CASE WHEN $6 AND p.status = 'published' THEN NOW() ELSE p.edited_at END,
+			updated_at = NOW(),
+			version = p.version + 1
+		WHERE p.id = $7
+		RETURNING ` + postColumns
+		err = scanPost(tx.QueryRowContext(ctx, query, post.Title, post.Content, pq.Array(post.Tags), post.Status, post.PublishAt, contentChanged, post.ID), post)
+		if err != nil {
+			return err
+		}
+
+		if contentChanged {
+			editorID := AuditContextFrom(ctx).ActorID
+			if editorID == 0 {
+				editorID = post.UserID
+			}
+			if err := insertRevision(ctx, tx, post, editorID); err != nil {
+				return err
+			}
+
+			if err := linkHashtags(ctx, tx, "post_hashtags", "post_id", post.ID, post.Tags); err != nil {
+				return err
+			}
+
+			post.MentionedUserIDs, err = syncMentions(ctx, tx, post.UserID, post.ID, nil, post.text())
+			if err != nil {
+				return err
+			}
+		}
+
+		// al publicar un draft se notifican todas sus menciones, no solo las nuevas
+		if before.Status != PostStatusPublished && post.Status == PostStatusPublished {
+			post.MentionedUserIDs, err = postMentions(ctx, tx, post.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return recordAudit(ctx, tx, AuditActionPostUpdate, AuditTargetPost, post.ID, before.auditFields(), post.auditFields())
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return post, nil
+
+}
+
+// text es el texto del post del que se extraen las menciones y hashtags
+func (p *Post) text() string {
+	return p.Title + "\n" + p.Content
+}
+
+// auditFields son los campos del post que se registran en el diff de auditoria
+func (p *Post) auditFields() map[string]any {
+	return map[string]any{
+		"title":   p.Title,
+		"content": p.Content,
+		"user_id": p.UserID,
+		"tags":    p.Tags,
+		"status":  p.Status,
+	}
+}
+
+var ErrPostAlreadyPublished = errors.New("a published post cannot go back to draft")
+
+// GetDrafts devuelve los drafts y posts programados del usuario. Nunca se muestran a otros usuarios.
+func (s *PostsStore) GetDrafts(ctx context.Context, userID int64, fq PaginatedQuery) ([]*Post, error) {
 	query := `
-	UPDATE posts 
-	SET title = $1, content = $2
-	WHERE id = $3
+	SELECT ` + postColumns + `
+	FROM posts p
+	WHERE p.user_id = $1 AND p.status IN ('draft', 'scheduled')
+	ORDER BY p.updated_at ` + fq.Sort + `
+	LIMIT $2 OFFSET $3
 	`
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, post.Title, post.Content, post.ID)
+	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset)
 	if err != nil {
 		return nil, err
 	}
-	return post, nil
+	defer rows.Close()
+
+	posts := []*Post{}
+	for rows.Next() {
+		var p Post
+		if err := scanPost(rows, &p); err != nil {
+			return nil, err
+		}
+		posts = append(posts, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// PublishDue publica hasta limit posts programados cuyo publish_at ya paso. El UPDATE es atomico y
+// con SKIP LOCKED varias instancias del scheduler nunca publican el mismo post dos veces.
+func (s *PostsStore) PublishDue(ctx context.Context, limit int) ([]*Post, error) {
+	query := `
+	UPDATE posts p SET status = 'published', published_at = NOW(), version = p.version + 1
+	WHERE p.id IN (
+		SELECT id FROM posts
+		WHERE status = 'scheduled' AND publish_at <= NOW()
+		ORDER BY publish_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + postColumns
+
+	posts := []*Post{}
+
+	// el UPDATE y la lectura de menciones van en la misma transaccion: si falla la segunda, los posts
+	// siguen programados y se vuelven a tomar en la proxima vuelta en vez de publicarse sin eventos
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, query, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		byID := map[int64]*Post{}
+		for rows.Next() {
+			var p Post
+			if err := scanPost(rows, &p); err != nil {
+				return err
+			}
+			posts = append(posts, &p)
+			byID[p.ID] = &p
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(posts) == 0 {
+			return nil
+		}
+
+		// las menciones de los posts programados se notifican recien ahora que se publicaron
+		ids := make([]int64, 0, len(posts))
+		for _, p := range posts {
+			ids = append(ids, p.ID)
+		}
+		mentions, err := tx.QueryContext(ctx, `SELECT post_id, user_id FROM mentions WHERE post_id = ANY($1) AND comment_id IS NULL`, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		defer mentions.Close()
+
+		for mentions.Next() {
+			var postID, userID int64
+			if err := mentions.Scan(&postID, &userID); err != nil {
+				return err
+			}
+			byID[postID].MentionedUserIDs = append(byID[postID].MentionedUserIDs, userID)
+		}
+		return mentions.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
 
+// IsVisibleTo indica si el usuario puede ver el post: los drafts y programados solo los ve el autor
+func (p *Post) IsVisibleTo(userID int64) bool {
+	return p.Status == PostStatusPublished || p.UserID == userID
 }
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// FeedCandidate es un post que puede entrar en el feed rankeado, con las señales que usa el scoring
+type FeedCandidate struct {
+	PostID      int64
+	AuthorID    int64
+	Tags        []string
+	PublishedAt time.Time
+	Comments    int
+	Reactions   int
+	Reposts     int
+	// 1 si el autor es seguido por el usuario, 2 si lo sigue alguien que el usuario sigue
+	Degree int
+}
+
+// InteractionProfile resume con quien y con que tags interactuo el usuario (reacciones, comentarios,
+// reposts y sus propios posts)
+type InteractionProfile struct {
+	Authors map[int64]int
+	Tags    map[string]int
+}
+
+// cantidad maxima de interacciones que se leen para armar el perfil
+const profileMaxInteractions = 1000
+
+type RankingStore struct {
+	db *sql.DB
+}
+
+// GetCandidates devuelve los posts publicados desde since por los usuarios seguidos y por los de
+// segundo grado, sin los del propio usuario
+func (s *RankingStore) GetCandidates(ctx context.Context, userID int64, since time.Time, limit int) ([]*FeedCandidate, error) {
+	query := `
+	WITH following AS (
+		SELECT follower_id AS id FROM followers WHERE user_id = $1
+	), second_degree AS (
+		SELECT DISTINCT f.follower_id AS id
+		FROM followers f
+		WHERE f.user_id IN (SELECT id FROM following)
+			AND f.follower_id <> $1
+			AND f.follower_id NOT IN (SELECT id FROM following)
+	)
+	SELECT p.id, p.user_id, p.tags, p.published_at,
+		(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id),
+		(SELECT COUNT(*) FROM post_reactions pr WHERE pr.post_id = p.id),
+		(SELECT COUNT(*) FROM reposts rp WHERE rp.post_id = p.id),
+		CASE WHEN p.user_id IN (SELECT id FROM following) THEN 1 ELSE 2 END
+	FROM posts p
+	WHERE p.status = 'published' AND p.published_at >= $2
+		AND (p.user_id IN (SELECT id FROM following) OR p.user_id IN (SELECT id FROM second_degree))
+	ORDER BY p.published_at DESC
+	LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []*FeedCandidate{}
+	for rows.Next() {
+		c := &FeedCandidate{}
+		err := rows.Scan(&c.PostID, &c.AuthorID, pq.Array(&c.Tags), &c.PublishedAt, &c.Comments, &c.Reactions, &c.Reposts, &c.Degree)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// GetInteractionProfile arma el perfil con las interacciones del usuario desde since
+func (s *RankingStore) GetInteractionProfile(ctx context.Context, userID int64, since time.Time) (*InteractionProfile, error) {
+	query := `
+	SELECT p.user_id, p.tags
+	FROM (
+		SELECT post_id, created_at FROM post_reactions WHERE user_id = $1
+		UNION ALL
+		SELECT post_id, created_at FROM comments WHERE user_id = $1
+		UNION ALL
+		SELECT post_id, created_at FROM reposts WHERE user_id = $1
+		UNION ALL
+		SELECT id, created_at FROM posts WHERE user_id = $1 AND status = 'published'
+	) i
+	JOIN posts p ON p.id = i.post_id
+	WHERE i.created_at >= $2
+	ORDER BY i.created_at DESC
+	LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, since, profileMaxInteractions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profile := &InteractionProfile{Authors: map[int64]int{}, Tags: map[string]int{}}
+	for rows.Next() {
+		var authorID int64
+		var tags []string
+		if err := rows.Scan(&authorID, pq.Array(&tags)); err != nil {
+			return nil, err
+		}
+		// con sus propios posts solo suman los tags
+		if authorID != userID {
+			profile.Authors[authorID]++
+		}
+		for _, t := range tags {
+			profile.Tags[t]++
+		}
+	}
+	return profile, rows.Err()
+}
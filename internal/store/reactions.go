@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Tipos de reaccion a un post. Cada usuario tiene como mucho una reaccion por post.
+var ReactionKinds = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+type ReactionsStore struct {
+	db *sql.DB
+}
+
+// Set guarda la reaccion del usuario al post, reemplazando la anterior. Devuelve true si el
+// usuario no habia reaccionado antes.
+func (s *ReactionsStore) Set(ctx context.Context, userID, postID int64, kind string) (bool, error) {
+	query := `
+	INSERT INTO post_reactions (user_id, post_id, kind) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, post_id) DO UPDATE SET kind = EXCLUDED.kind
+	RETURNING xmax = 0
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var created bool
+	err := s.db.QueryRowContext(ctx, query, userID, postID, kind).Scan(&created)
+	return created, err
+}
+
+func (s *ReactionsStore) Delete(ctx context.Context, userID, postID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM post_reactions WHERE user_id = $1 AND post_id = $2`, userID, postID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type RepostsStore struct {
+	db *sql.DB
+}
+
+// Create repostea el post. Repostear dos veces el mismo post no hace nada.
+func (s *RepostsStore) Create(ctx context.Context, userID, postID int64) error {
+	query := `INSERT INTO reposts (user_id, post_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+	return err
+}
+
+func (s *RepostsStore) Delete(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM reposts WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
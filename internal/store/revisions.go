@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type PostRevision struct {
+	ID       int64    `json:"id"`
+	PostID   int64    `json:"post_id"`
+	Revision int      `json:"revision"`
+	Title    string   `json:"title"`
+	Content  string   `json:"content"`
+	Tags     []string `json:"tags"`
+	EditorID int64    `json:"editor_id"`
+	Editor   User     `json:"editor"`
+	// true si el que edito no es el autor del post (un moderador o admin)
+	ModeratorEdit bool   `json:"moderator_edit"`
+	CreatedAt     string `json:"created_at"`
+}
+
+type RevisionsStore struct {
+	db *sql.DB
+}
+
+const revisionQuery = `
+	SELECT r.id, r.post_id, r.revision, r.title, r.content, r.tags, r.editor_id, u.id, u.username, r.editor_id <> p.user_id, r.created_at
+	FROM post_revisions r
+	JOIN posts p ON p.id = r.post_id
+	JOIN users u ON u.id = r.editor_id
+	`
+
+func scanRevision(row rowScanner) (*PostRevision, error) {
+	var r PostRevision
+	err := row.Scan(&r.ID, &r.PostID, &r.Revision, &r.Title, &r.Content, pq.Array(&r.Tags), &r.EditorID, &r.Editor.ID, &r.Editor.Username, &r.ModeratorEdit, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *RevisionsStore) GetByPostId(ctx context.Context, postID int64) ([]PostRevision, error) {
+	query := revisionQuery + `WHERE r.post_id = $1 ORDER BY r.revision DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (s *RevisionsStore) Get(ctx context.Context, postID int64, revision int) (*PostRevision, error) {
+	query := revisionQuery + `WHERE r.post_id = $1 AND r.revision = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	r, err := scanRevision(s.db.QueryRowContext(ctx, query, postID, revision))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// insertRevision guarda el estado actual del post como una nueva revision, dentro de la transacción del cambio
+func insertRevision(ctx context.Context, tx *sql.Tx, post *Post, editorID int64) error {
+	query := `
+	INSERT INTO post_revisions (post_id, revision, title, content, tags, editor_id)
+	VALUES ($1, (SELECT COALESCE(MAX(revision), 0) + 1 FROM post_revisions WHERE post_id = $1), $2, $3, $4, $5)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, post.ID, post.Title, post.Content, pq.Array(post.Tags), editorID)
+	return err
+}
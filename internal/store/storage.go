@@ -9,15 +9,24 @@ import (
 
 var (
 	ErrNotFound                        = errors.New("record not found")
+	ErrVersionConflict                 = errors.New("the record was modified by another request")
 	QueryTimeoutDuration time.Duration = 5 * time.Second
 )
 
 type PostRepository interface { // aca vamos a tener las operaciones que vamos a hacer sobre los posts
 	Create(context.Context, *Post) error
 	GetById(context.Context, int64) (*Post, error)
+	GetByIds(context.Context, []int64) (map[int64]*Post, error)
 	Delete(context.Context, int64) (*Post, error)
 	Update(context.Context, *Post) (*Post, error)
 	GetFeed(context.Context, int64, PaginatedQuery) ([]*PostWithMetadata, error)
+	GetFeedByIds(ctx context.Context, userID int64, ids []int64) ([]*PostWithMetadata, error)
+	GetWithMetadataByIds(ctx context.Context, ids []int64) ([]*PostWithMetadata, error)
+	GetTimeline(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error)
+	GetAuthorsTimeline(ctx context.Context, authorIDs []int64, limit int) ([]TimelineEntry, error)
+	GetByTag(ctx context.Context, tag string, fq PaginatedQuery) ([]*PostWithMetadata, error)
+	GetDrafts(context.Context, int64, PaginatedQuery) ([]*Post, error)
+	PublishDue(ctx context.Context, limit int) ([]*Post, error)
 }
 
 type UserRepository interface { // aca vamos a tener las operaciones que vamos a hacer sobre los usuarios
@@ -25,39 +34,149 @@ type UserRepository interface { // aca vamos a tener las operaciones que vamos a
 	GetById(context.Context, int64) (*User, error)
 	GetByEmail(context.Context, string) (*User, error)
 	CreateInvitation(ctx context.Context, user *User, token string, invitationExp time.Duration) error
-	ActivateUser(ctx context.Context, token string) error
+	ActivateUser(ctx context.Context, token string) (int64, error)
+	UpdateProfile(context.Context, *User) error
 	Delete(ctx context.Context, userID int64) error
+	UpdateRole(ctx context.Context, userID int64, roleName string) error
+	UpdatePassword(ctx context.Context, user *User) error
+	SetSuspended(ctx context.Context, userID int64, suspended bool) error
 }
 
 type CommentRepository interface {
 	GetByPostId(context.Context, int64) (*[]Comment, error)
+	GetById(context.Context, int64) (*Comment, error)
 	Create(context.Context, *Comment) error
+	Update(context.Context, *Comment) error
 }
 
 type FollowRepository interface {
 	Follow(context.Context, int64, int64) error
 	Unfollow(context.Context, int64, int64) error
+	Followers(context.Context, int64) ([]int64, error)
+	FollowerCount(context.Context, int64) (int, error)
+	FollowedWithMinFollowers(ctx context.Context, userID int64, minFollowers int) ([]int64, error)
 }
 
 type RoleRepository interface {
 	GetByName(context.Context, string) (*Role, error)
 }
 
+type AttachmentRepository interface {
+	Create(context.Context, int64, []*Attachment) error
+	GetByPostId(context.Context, int64) ([]Attachment, error)
+	GetByPostIds(context.Context, []int64) (map[int64][]Attachment, error)
+	Delete(context.Context, int64, int64) (*Attachment, error)
+	Reorder(context.Context, int64, []int64) error
+	ClaimPending(ctx context.Context, limit int, staleAfter time.Duration) ([]Attachment, error)
+	SaveVariants(ctx context.Context, attachmentID int64, variants []AttachmentVariant, blurhash string) error
+	MarkFailed(ctx context.Context, attachmentID int64, reason string) error
+}
+
+type RevisionRepository interface {
+	GetByPostId(context.Context, int64) ([]PostRevision, error)
+	Get(ctx context.Context, postID int64, revision int) (*PostRevision, error)
+}
+
+type RepostRepository interface {
+	Create(ctx context.Context, userID, postID int64) error
+	Delete(ctx context.Context, userID, postID int64) error
+}
+
+type ReactionRepository interface {
+	Set(ctx context.Context, userID, postID int64, kind string) (bool, error)
+	Delete(ctx context.Context, userID, postID int64) error
+}
+
+type NotificationRepository interface {
+	Create(context.Context, NotificationEvent) (bool, error)
+	List(ctx context.Context, userID int64, cursor *Cursor, limit int) ([]*Notification, error)
+	UnreadCount(context.Context, int64) (int, error)
+	MarkRead(ctx context.Context, userID, notificationID int64) error
+	MarkAllRead(context.Context, int64) error
+	GetPreferences(context.Context, int64) (map[string]bool, error)
+	SetPreferences(ctx context.Context, userID int64, prefs map[string]bool) error
+}
+
+type ConversationRepository interface {
+	Create(ctx context.Context, c *Conversation, recipientIDs []int64) (bool, error)
+	List(ctx context.Context, userID int64, cursor *Cursor, limit int) ([]*Conversation, error)
+	GetForUser(ctx context.Context, conversationID, userID int64) (*Conversation, error)
+	CreateMessage(context.Context, *Message) error
+	ListMessages(ctx context.Context, conversationID, beforeID int64, limit int) ([]*Message, error)
+	MarkRead(ctx context.Context, conversationID, userID, messageID int64) (int64, error)
+	UnreadCount(context.Context, int64) (int, error)
+	GetDMPolicy(context.Context, int64) (string, error)
+	SetDMPolicy(ctx context.Context, userID int64, policy string) error
+}
+
+type BlockRepository interface {
+	Block(ctx context.Context, blockerID, blockedID int64) error
+	Unblock(ctx context.Context, blockerID, blockedID int64) error
+	BlockedIDs(context.Context, int64) ([]int64, error)
+	IsBlocked(ctx context.Context, userID, otherID int64) (bool, error)
+}
+
+type TrendingRepository interface {
+	TopPosts(ctx context.Context, window, halfLife time.Duration, limit int) ([]TrendingPostScore, error)
+	TopTags(ctx context.Context, window, halfLife time.Duration, limit int) ([]TrendingTag, error)
+	TagScoresByAuthors(ctx context.Context, window, halfLife time.Duration, authorIDs []int64) ([]TrendingTag, error)
+	GetPostSignals(context.Context, int64) (*PostSignals, error)
+	ExcludeSuspended(context.Context, []int64) ([]int64, error)
+}
+
+type SuggestionRepository interface {
+	Candidates(ctx context.Context, userID int64, since time.Time, limit int) ([]*SuggestionCandidate, error)
+	Eligible(ctx context.Context, userID int64, ids []int64) ([]int64, error)
+	Dismiss(ctx context.Context, userID, suggestedID int64) error
+}
+
+type RankingRepository interface {
+	GetCandidates(ctx context.Context, userID int64, since time.Time, limit int) ([]*FeedCandidate, error)
+	GetInteractionProfile(ctx context.Context, userID int64, since time.Time) (*InteractionProfile, error)
+}
+
+type AuditRepository interface {
+	Create(context.Context, *AuditEvent) error
+	List(context.Context, AuditFilter) ([]*AuditEvent, error)
+}
+
 type Storage struct { // inyección de dependencias de los repos
-	Posts    PostRepository
-	Users    UserRepository
-	Comments CommentRepository
-	Follows  FollowRepository
-	Roles    RoleRepository
+	Posts         PostRepository
+	Users         UserRepository
+	Comments      CommentRepository
+	Follows       FollowRepository
+	Roles         RoleRepository
+	Audit         AuditRepository
+	Attachments   AttachmentRepository
+	Revisions     RevisionRepository
+	Reposts       RepostRepository
+	Reactions     ReactionRepository
+	Notifications NotificationRepository
+	Conversations ConversationRepository
+	Ranking       RankingRepository
+	Blocks        BlockRepository
+	Trending      TrendingRepository
+	Suggestions   SuggestionRepository
 }
 
 func NewStorage(db *sql.DB) Storage { // constructor del storage
 	return Storage{
-		Posts:    &PostsStore{db},
-		Users:    &UsersStore{db},
-		Comments: &CommentsStore{db},
-		Follows:  &FollowsStore{db},
-		Roles:    &RolesStore{db},
+		Posts:         &PostsStore{db},
+		Users:         &UsersStore{db},
+		Comments:      &CommentsStore{db},
+		Follows:       &FollowsStore{db},
+		Roles:         &RolesStore{db},
+		Audit:         &AuditStore{db},
+		Attachments:   &AttachmentsStore{db},
+		Revisions:     &RevisionsStore{db},
+		Reposts:       &RepostsStore{db},
+		Reactions:     &ReactionsStore{db},
+		Notifications: &NotificationsStore{db},
+		Conversations: &ConversationsStore{db},
+		Ranking:       &RankingStore{db},
+		Blocks:        &BlocksStore{db},
+		Trending:      &TrendingStore{db},
+		Suggestions:   &SuggestionsStore{db},
 	}
 }
 
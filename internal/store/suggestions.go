@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Pesos de cada fuente en el score de una sugerencia
+const (
+	SuggestionWeightMutual       = 3
+	SuggestionWeightCoEngagement = 2
+	SuggestionWeightTag          = 1
+)
+
+// SuggestionCandidate es un usuario que se le puede sugerir seguir a otro, con el motivo
+type SuggestionCandidate struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	// cantidad de usuarios seguidos que siguen al candidato
+	MutualFollowers int `json:"mutual_followers"`
+	// cantidad de posts con los que ambos interactuaron
+	CoEngagement int `json:"co_engagement"`
+	// tags que ambos usaron o con los que ambos interactuaron
+	SharedTags []string `json:"shared_tags"`
+	Score      int      `json:"score"`
+}
+
+type SuggestionsStore struct {
+	db *sql.DB
+}
+
+// Candidates calcula las sugerencias para userID con la actividad desde since: amigos de amigos,
+// usuarios que interactuaron con los mismos posts y usuarios que publican en los mismos tags. Quedan
+// afuera el propio usuario, los que ya sigue, los bloqueados (en cualquier sentido), los suspendidos
+// y los descartados.
+func (s *SuggestionsStore) Candidates(ctx context.Context, userID int64, since time.Time, limit int) ([]*SuggestionCandidate, error) {
+	query := `
+	WITH following AS (
+		SELECT follower_id AS id FROM followers WHERE user_id = $1
+	), excluded AS (
+		SELECT id FROM following
+		UNION SELECT blocked_id FROM user_blocks WHERE blocker_id = $1
+		UNION SELECT blocker_id FROM user_blocks WHERE blocked_id = $1
+		UNION SELECT suggested_id FROM suggestion_dismissals WHERE user_id = $1
+	), mutuals AS (
+		SELECT f.follower_id AS id, COUNT(*) AS n
+		FROM followers f
+		WHERE f.user_id IN (SELECT id FROM following)
+		GROUP BY f.follower_id
+	), engaged AS (
+		SELECT post_id FROM post_reactions WHERE user_id = $1 AND created_at >= $2
+		UNION SELECT post_id FROM comments WHERE user_id = $1 AND created_at >= $2
+		UNION SELECT post_id FROM reposts WHERE user_id = $1 AND created_at >= $2
+	), co_engagement AS (
+		SELECT i.user_id AS id, COUNT(DISTINCT i.post_id) AS n
+		FROM (
+			SELECT user_id, post_id FROM post_reactions WHERE post_id IN (SELECT post_id FROM engaged)
+			UNION ALL
+			SELECT user_id, post_id FROM comments WHERE post_id IN (SELECT post_id FROM engaged)
+			UNION ALL
+			SELECT user_id, post_id FROM reposts WHERE post_id IN (SELECT post_id FROM engaged)
+		) i
+		GROUP BY i.user_id
+	), my_tags AS (
+		SELECT DISTINCT t.tag
+		FROM posts p, unnest(p.tags) AS t(tag)
+		WHERE p.status = 'published'
+			AND ((p.user_id = $1 AND p.published_at >= $2) OR p.id IN (SELECT post_id FROM engaged))
+	), shared_tags AS (
+		SELECT p.user_id AS id, array_agg(DISTINCT t.tag) AS tags
+		FROM posts p, unnest(p.tags) AS t(tag)
+		WHERE p.status = 'published' AND p.published_at >= $2 AND t.tag IN (SELECT tag FROM my_tags)
+		GROUP BY p.user_id
+	), scored AS (
+		SELECT c.id,
+			COALESCE(m.n, 0) AS mutuals,
+			COALESCE(ce.n, 0) AS co_engagement,
+			COALESCE(st.tags, '{}') AS tags
+		FROM (
+			SELECT id FROM mutuals
+			UNION SELECT id FROM co_engagement
+			UNION SELECT id FROM shared_tags
+		) c
+		LEFT JOIN mutuals m ON m.id = c.id
+		LEFT JOIN co_engagement ce ON ce.id = c.id
+		LEFT JOIN shared_tags st ON st.id = c.id
+		WHERE c.id <> $1 AND c.id NOT IN (SELECT id FROM excluded)
+	)
+	SELECT u.id, u.username, s.mutuals, s.co_engagement, s.tags,
+		s.mutuals * $4 + s.co_engagement * $5 + cardinality(s.tags) * $6 AS score
+	FROM scored s
+	JOIN users u ON u.id = s.id
+	WHERE u.is_active AND u.suspended_at IS NULL
+	ORDER BY score DESC, s.mutuals DESC, u.id
+	LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, since, limit,
+		SuggestionWeightMutual, SuggestionWeightCoEngagement, SuggestionWeightTag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []*SuggestionCandidate{}
+	for rows.Next() {
+		c := &SuggestionCandidate{}
+		err := rows.Scan(&c.UserID, &c.Username, &c.MutualFollowers, &c.CoEngagement, pq.Array(&c.SharedTags), &c.Score)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// Eligible filtra ids (de sugerencias ya calculadas) con las exclusiones de Candidates, que pueden
+// haber cambiado desde el calculo. Mantiene el orden.
+func (s *SuggestionsStore) Eligible(ctx context.Context, userID int64, ids []int64) ([]int64, error) {
+	query := `
+	SELECT u.id
+	FROM unnest($2::bigint[]) WITH ORDINALITY AS c(id, ord)
+	JOIN users u ON u.id = c.id
+	WHERE u.suspended_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM followers f WHERE f.user_id = $1 AND f.follower_id = c.id)
+		AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b
+			WHERE (b.blocker_id = $1 AND b.blocked_id = c.id) OR (b.blocker_id = c.id AND b.blocked_id = $1)
+		)
+		AND NOT EXISTS (SELECT 1 FROM suggestion_dismissals d WHERE d.user_id = $1 AND d.suggested_id = c.id)
+	ORDER BY c.ord
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	eligible := make([]int64, 0, len(ids))
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		eligible = append(eligible, id)
+	}
+	return eligible, rows.Err()
+}
+
+// Dismiss hace que suggestedID no se le vuelva a sugerir a userID. Descartar dos veces no hace nada.
+func (s *SuggestionsStore) Dismiss(ctx context.Context, userID, suggestedID int64) error {
+	query := `INSERT INTO suggestion_dismissals (user_id, suggested_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, suggestedID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "suggestion_dismissals" violates foreign key constraint "suggestion_dismissals_suggested_id_fkey"`:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
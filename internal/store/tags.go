@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/marceterrone10/social/internal/richtext"
+)
+
+// linkHashtags deja los hashtags de un post o comentario (segun table/column) iguales a tags
+func linkHashtags(ctx context.Context, tx *sql.Tx, table, column string, id int64, tags []string) error {
+	if _, err := tx.ExecContext(ctx, `INSERT INTO hashtags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`, pq.Array(tags)); err != nil {
+		return err
+	}
+
+	query := `
+	DELETE FROM ` + table + `
+	WHERE ` + column + ` = $1 AND hashtag_id NOT IN (SELECT id FROM hashtags WHERE name = ANY($2))
+	`
+	if _, err := tx.ExecContext(ctx, query, id, pq.Array(tags)); err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO ` + table + ` (` + column + `, hashtag_id)
+	SELECT $1::bigint, id FROM hashtags WHERE name = ANY($2)
+	ON CONFLICT DO NOTHING
+	`
+	_, err := tx.ExecContext(ctx, query, id, pq.Array(tags))
+	return err
+}
+
+// syncMentions guarda las menciones de text (commentID es nil si el texto es del post) y devuelve
+// los ids de los usuarios mencionados por primera vez. Los usernames que no existen se ignoran.
+func syncMentions(ctx context.Context, tx *sql.Tx, authorID, postID int64, commentID *int64, text string) ([]int64, error) {
+	usernames := richtext.Mentions(text)
+
+	query := `
+	DELETE FROM mentions
+	WHERE post_id = $1 AND comment_id IS NOT DISTINCT FROM $2::bigint
+		AND user_id NOT IN (SELECT id FROM users WHERE username = ANY($3))
+	`
+	if _, err := tx.ExecContext(ctx, query, postID, commentID, pq.Array(usernames)); err != nil {
+		return nil, err
+	}
+
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	// el autor no se notifica a si mismo
+	query = `
+	INSERT INTO mentions (post_id, comment_id, user_id, author_id)
+	SELECT $1::bigint, $2::bigint, id, $4::bigint FROM users WHERE username = ANY($3) AND id <> $4
+	ON CONFLICT DO NOTHING
+	RETURNING user_id
+	`
+	rows, err := tx.QueryContext(ctx, query, postID, commentID, pq.Array(usernames), authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// postMentions devuelve los usuarios mencionados en el texto del post (no en sus comentarios)
+func postMentions(ctx context.Context, tx *sql.Tx, postID int64) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT user_id FROM mentions WHERE post_id = $1 AND comment_id IS NULL`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetByTag es el timeline de un hashtag: los posts publicados que lo usan, del mas nuevo al mas viejo
+func (s *PostsStore) GetByTag(ctx context.Context, tag string, fq PaginatedQuery) ([]*PostWithMetadata, error) {
+	query := `
+	SELECT ` + postColumns + `, (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id), u.id, u.username, u.email
+	FROM posts p
+	JOIN post_hashtags ph ON ph.post_id = p.id
+	JOIN hashtags h ON h.id = ph.hashtag_id
+	JOIN users u ON u.id = p.user_id
+	WHERE h.name = $1 AND p.status = 'published'
+	ORDER BY p.published_at ` + fq.Sort + `
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, tag, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*PostWithMetadata{}
+	for rows.Next() {
+		post := PostWithMetadata{RepostedBy: []string{}}
+		err := scanPost(rows, &post.Post, &post.CommentCount, &post.User.ID, &post.User.Username, &post.User.Email)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, &post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Pesos de cada interaccion en el score de trending
+const (
+	TrendingWeightPost     = 1.0
+	TrendingWeightReaction = 1.0
+	TrendingWeightComment  = 2.0
+	TrendingWeightRepost   = 3.0
+)
+
+type TrendingTag struct {
+	Tag   string  `json:"tag"`
+	Score float64 `json:"score"`
+}
+
+type TrendingPostScore struct {
+	PostID int64
+	Score  float64
+}
+
+// PostSignals es lo que hace falta de un post para sumarlo a trending
+type PostSignals struct {
+	AuthorID  int64
+	Tags      []string
+	Suspended bool
+}
+
+type TrendingStore struct {
+	db *sql.DB
+}
+
+// trendingEvents son las interacciones con posts publicados de autores no suspendidos en la ventana ($1
+// segundos), cada una con su peso ya decaido segun su antiguedad ($2 es la vida media en segundos).
+// Publicar un post solo cuenta para los tags.
+func trendingEvents(includePosts bool) string {
+	posts := ""
+	if includePosts {
+		posts = `
+		UNION ALL
+		SELECT id, published_at, ` + weight(TrendingWeightPost) + ` FROM posts WHERE status = 'published' AND published_at >= NOW() - make_interval(secs => $1)`
+	}
+	return `
+	SELECT e.post_id, e.weight * EXP(-LN(2) * EXTRACT(EPOCH FROM (NOW() - e.at)) / $2) AS score
+	FROM (
+		SELECT post_id, created_at AS at, ` + weight(TrendingWeightReaction) + ` AS weight FROM post_reactions WHERE created_at >= NOW() - make_interval(secs => $1)
+		UNION ALL
+		SELECT post_id, created_at, ` + weight(TrendingWeightComment) + ` FROM comments WHERE created_at >= NOW() - make_interval(secs => $1)
+		UNION ALL
+		SELECT post_id, created_at, ` + weight(TrendingWeightRepost) + ` FROM reposts WHERE created_at >= NOW() - make_interval(secs => $1)` + posts + `
+	) e
+	JOIN posts p ON p.id = e.post_id AND p.status = 'published'
+	JOIN users u ON u.id = p.user_id AND u.suspended_at IS NULL
+	`
+}
+
+func weight(w float64) string {
+	return strconv.FormatFloat(w, 'f', -1, 64) + "::float8"
+}
+
+// TopPosts agrega desde Postgres los posts con mas interacciones recientes en la ventana
+func (s *TrendingStore) TopPosts(ctx context.Context, window, halfLife time.Duration, limit int) ([]TrendingPostScore, error) {
+	query := `
+	SELECT post_id, SUM(score) AS score
+	FROM (` + trendingEvents(false) + `) t
+	GROUP BY post_id
+	ORDER BY score DESC
+	LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, window.Seconds(), halfLife.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := []TrendingPostScore{}
+	for rows.Next() {
+		var ps TrendingPostScore
+		if err := rows.Scan(&ps.PostID, &ps.Score); err != nil {
+			return nil, err
+		}
+		scores = append(scores, ps)
+	}
+	return scores, rows.Err()
+}
+
+// TopTags agrega desde Postgres los hashtags de los posts con mas actividad reciente en la ventana
+func (s *TrendingStore) TopTags(ctx context.Context, window, halfLife time.Duration, limit int) ([]TrendingTag, error) {
+	query := `
+	SELECT h.name, SUM(t.score) AS score
+	FROM (` + trendingEvents(true) + `) t
+	JOIN post_hashtags ph ON ph.post_id = t.post_id
+	JOIN hashtags h ON h.id = ph.hashtag_id
+	GROUP BY h.name
+	ORDER BY score DESC
+	LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, window.Seconds(), halfLife.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TrendingTag{}
+	for rows.Next() {
+		var t TrendingTag
+		if err := rows.Scan(&t.Tag, &t.Score); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// TagScoresByAuthors devuelve cuanto suman a cada hashtag de la ventana los posts de authorIDs, para
+// descontarlo de TopTags
+func (s *TrendingStore) TagScoresByAuthors(ctx context.Context, window, halfLife time.Duration, authorIDs []int64) ([]TrendingTag, error) {
+	query := `
+	SELECT h.name, SUM(t.score) AS score
+	FROM (` + trendingEvents(true) + ` WHERE p.user_id = ANY($3)) t
+	JOIN post_hashtags ph ON ph.post_id = t.post_id
+	JOIN hashtags h ON h.id = ph.hashtag_id
+	GROUP BY h.name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, window.Seconds(), halfLife.Seconds(), pq.Array(authorIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TrendingTag{}
+	for rows.Next() {
+		var t TrendingTag
+		if err := rows.Scan(&t.Tag, &t.Score); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+func (s *TrendingStore) GetPostSignals(ctx context.Context, postID int64) (*PostSignals, error) {
+	query := `
+	SELECT p.user_id, COALESCE(array_agg(h.name) FILTER (WHERE h.name IS NOT NULL), '{}'), u.suspended_at IS NOT NULL
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	LEFT JOIN post_hashtags ph ON ph.post_id = p.id
+	LEFT JOIN hashtags h ON h.id = ph.hashtag_id
+	WHERE p.id = $1 AND p.status = 'published'
+	GROUP BY p.user_id, u.suspended_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var ps PostSignals
+	err := s.db.QueryRowContext(ctx, query, postID).Scan(&ps.AuthorID, pq.Array(&ps.Tags), &ps.Suspended)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &ps, nil
+}
+
+// ExcludeSuspended devuelve los posts de postIDs que siguen publicados y cuyo autor no esta suspendido, en el mismo orden
+func (s *TrendingStore) ExcludeSuspended(ctx context.Context, postIDs []int64) ([]int64, error) {
+	query := `
+	SELECT p.id
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE p.id = ANY($1) AND p.status = 'published' AND u.suspended_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	active := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		active[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(active))
+	for _, id := range postIDs {
+		if active[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
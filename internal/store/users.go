@@ -14,6 +14,7 @@ import (
 var (
 	ErrDuplicateEmail    = errors.New("a user with the email already exists")
 	ErrDuplicateUsername = errors.New("a user with the username already exists")
+	ErrRoleNotFound      = errors.New("role not found")
 )
 
 type User struct {
@@ -25,6 +26,9 @@ type User struct {
 	IsActive  bool     `json:"is_active"`
 	RoleID    int64    `json:"role_id"`
 	Role      Role     `json:"role"`
+	Version   int      `json:"version"`
+	// nil si el usuario no esta suspendido
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
 }
 
 type UsersStore struct {
@@ -98,7 +102,7 @@ func (s *UsersStore) GetById(ctx context.Context, id int64) (*User, error) {
 	var user User
 	query :=
 		`
-	SELECT users.id, username, password, email, created_at, roles.*
+	SELECT users.id, username, password, email, created_at, users.version, users.suspended_at, roles.*
 	FROM users 
 	JOIN roles ON roles.id = users.role_id
 	WHERE users.id = $1;
@@ -116,6 +120,8 @@ func (s *UsersStore) GetById(ctx context.Context, id int64) (*User, error) {
 		&user.Password.hash,
 		&user.Email,
 		&user.CreatedAt,
+		&user.Version,
+		&user.SuspendedAt,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Description,
@@ -147,13 +153,16 @@ func (s *UsersStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token
 	return nil
 }
 
-func (s *UsersStore) ActivateUser(ctx context.Context, token string) error {
-	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+// ActivateUser activa al usuario de la invitacion y devuelve su id
+func (s *UsersStore) ActivateUser(ctx context.Context, token string) (int64, error) {
+	var userID int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
 		// 1. Buscar el token en la DB del usuario
 		user, err := s.getUserFromInvitation(ctx, tx, token)
 		if err != nil {
 			return err
 		}
+		userID = user.ID
 		// 2. Activar el usuario
 		user.IsActive = true
 		if err := s.update(ctx, tx, user); err != nil {
@@ -165,6 +174,7 @@ func (s *UsersStore) ActivateUser(ctx context.Context, token string) error {
 		}
 		return nil
 	})
+	return userID, err
 }
 
 func (s *UsersStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
@@ -202,7 +212,7 @@ func (s *UsersStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, toke
 }
 
 func (s *UsersStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
-	query := `UPDATE users SET username = $1, email = $2, is_active = $3 WHERE id = $4`
+	query := `UPDATE users SET username = $1, email = $2, is_active = $3, version = version + 1 WHERE id = $4`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -260,7 +270,7 @@ func (s *UsersStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
 func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query :=
 		`
-	SELECT id, username, email, password, created_at FROM users 
+	SELECT id, username, email, password, created_at, suspended_at FROM users 
 	WHERE email = $1 AND is_active
 	`
 
@@ -274,6 +284,7 @@ func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.SuspendedAt,
 	)
 	if err != nil {
 		switch err {
@@ -290,3 +301,143 @@ func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error
 func (p *password) Compare(text string) error {
 	return bcrypt.CompareHashAndPassword(p.hash, []byte(text))
 }
+
+func (s *UsersStore) UpdateRole(ctx context.Context, userID int64, roleName string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var roleID int64
+		err := tx.QueryRowContext(ctx, `SELECT id FROM roles WHERE name = $1`, roleName).Scan(&roleID)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRoleNotFound
+			default:
+				return err
+			}
+		}
+
+		var before string
+		query := `
+		SELECT r.name FROM users u
+		JOIN roles r ON r.id = u.role_id
+		WHERE u.id = $1
+		FOR UPDATE OF u
+		`
+		err = tx.QueryRowContext(ctx, query, userID).Scan(&before)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET role_id = $1, version = version + 1 WHERE id = $2`, roleID, userID); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, AuditActionUserRoleChange, AuditTargetUser, userID,
+			map[string]any{"role": before},
+			map[string]any{"role": roleName},
+		)
+	})
+}
+
+func (s *UsersStore) UpdatePassword(ctx context.Context, user *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `UPDATE users SET password = $1, version = version + 1 WHERE id = $2`, user.Password.hash, user.ID)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		// nunca se guarda el hash en el diff, solo que hubo un cambio
+		return recordAudit(ctx, tx, AuditActionUserPassword, AuditTargetUser, user.ID,
+			map[string]any{"password": "[redacted]"},
+			map[string]any{"password": "[changed]"},
+		)
+	})
+}
+
+// UpdateProfile actualiza los datos publicos del usuario. Falla con ErrVersionConflict si user.Version
+// no es la version actual (otro request lo modifico antes).
+func (s *UsersStore) UpdateProfile(ctx context.Context, user *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var before User
+		err := tx.QueryRowContext(ctx, `SELECT username, version FROM users WHERE id = $1 FOR UPDATE`, user.ID).Scan(&before.Username, &before.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if before.Version != user.Version {
+			return ErrVersionConflict
+		}
+
+		query := `UPDATE users SET username = $1, version = version + 1 WHERE id = $2 RETURNING version`
+		if err := tx.QueryRowContext(ctx, query, user.Username, user.ID).Scan(&user.Version); err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+				return ErrDuplicateUsername
+			default:
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetSuspended suspende o levanta la suspension del usuario. Queda registrado en la auditoria.
+func (s *UsersStore) SetSuspended(ctx context.Context, userID int64, suspended bool) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var before bool
+		err := tx.QueryRowContext(ctx, `SELECT suspended_at IS NOT NULL FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&before)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		if before == suspended {
+			return nil
+		}
+
+		query := `UPDATE users SET suspended_at = CASE WHEN $2 THEN NOW() END, version = version + 1 WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, userID, suspended); err != nil {
+			return err
+		}
+
+		action := AuditActionUserUnsuspend
+		if suspended {
+			action = AuditActionUserSuspend
+		}
+		return recordAudit(ctx, tx, action, AuditTargetUser, userID,
+			map[string]any{"suspended": before},
+			map[string]any{"suspended": suspended},
+		)
+	})
+}
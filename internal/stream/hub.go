@@ -0,0 +1,81 @@
+package stream
+
+import "sync"
+
+// tamaño del buffer de cada conexion. Si el cliente no consume a tiempo y se llena, la suscripcion
+// se corta: el cliente se reconecta con Last-Event-ID y recupera lo que se perdio del historial.
+const subscriptionBuffer = 64
+
+type Subscription struct {
+	C <-chan Message
+	// Dropped se cierra si la suscripcion se corto porque el cliente era demasiado lento
+	Dropped <-chan struct{}
+
+	hub     *hub
+	topics  []string
+	ch      chan Message
+	dropped chan struct{}
+	once    sync.Once
+}
+
+// Close libera la suscripcion, se puede llamar mas de una vez
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+// hub entrega los mensajes a las suscripciones locales de la instancia
+type hub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+func newHub() *hub {
+	return &hub{topics: map[string]map[*Subscription]struct{}{}}
+}
+
+func (h *hub) subscribe(topics ...string) *Subscription {
+	ch := make(chan Message, subscriptionBuffer)
+	dropped := make(chan struct{})
+	s := &Subscription{C: ch, Dropped: dropped, hub: h, topics: topics, ch: ch, dropped: dropped}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, t := range topics {
+		if h.topics[t] == nil {
+			h.topics[t] = map[*Subscription]struct{}{}
+		}
+		h.topics[t][s] = struct{}{}
+	}
+	return s
+}
+
+func (h *hub) remove(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, t := range s.topics {
+		delete(h.topics[t], s)
+		if len(h.topics[t]) == 0 {
+			delete(h.topics, t)
+		}
+	}
+}
+
+// deliver nunca bloquea: si el buffer de una suscripcion esta lleno se la corta
+func (h *hub) deliver(msg Message) {
+	var slow []*Subscription
+
+	h.mu.RLock()
+	for s := range h.topics[msg.Topic] {
+		select {
+		case s.ch <- msg:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range slow {
+		s.once.Do(func() { close(s.dropped) })
+		h.remove(s)
+	}
+}
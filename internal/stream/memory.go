@@ -0,0 +1,78 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// cantidad de mensajes por topic que se guardan para reanudar con Last-Event-ID
+const memoryHistorySize = 256
+
+// MemoryBroker es el broker en memoria para cuando Redis esta deshabilitado. Solo entrega
+// los mensajes a los clientes conectados a esta instancia.
+type MemoryBroker struct {
+	*hub
+
+	mu      sync.Mutex
+	lastMs  int64
+	seq     uint64
+	history map[string][]Message
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		hub:     newHub(),
+		history: map[string][]Message{},
+	}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, topic, msgType string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	msg := Message{ID: b.nextID(), Topic: topic, Type: msgType, Data: raw}
+	h := append(b.history[topic], msg)
+	if len(h) > memoryHistorySize {
+		h = h[len(h)-memoryHistorySize:]
+	}
+	b.history[topic] = h
+	b.mu.Unlock()
+
+	b.deliver(msg)
+	return nil
+}
+
+// nextID genera IDs crecientes aunque haya varios mensajes en el mismo milisegundo
+func (b *MemoryBroker) nextID() string {
+	ms := time.Now().UnixMilli()
+	if ms <= b.lastMs {
+		b.seq++
+	} else {
+		b.lastMs = ms
+		b.seq = 0
+	}
+	return fmt.Sprintf("%d-%d", b.lastMs, b.seq)
+}
+
+func (b *MemoryBroker) Subscribe(topics ...string) *Subscription {
+	return b.subscribe(topics...)
+}
+
+func (b *MemoryBroker) Since(ctx context.Context, topic, lastID string) ([]Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msgs := []Message{}
+	for _, m := range b.history[topic] {
+		if CompareIDs(m.ID, lastID) > 0 {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs, nil
+}
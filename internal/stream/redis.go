@@ -0,0 +1,108 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	redisKeyPrefix = "stream:"
+	// historial por topic en Redis Streams para reanudar con Last-Event-ID
+	redisHistorySize = 1000
+	redisHistoryTTL  = 24 * time.Hour
+)
+
+// RedisBroker guarda cada mensaje en un Redis Stream (historial e ID) y lo publica por pub/sub
+// para que todas las instancias de la API lo entreguen a sus clientes conectados.
+type RedisBroker struct {
+	*hub
+	rdb    *redis.Client
+	logger *zap.SugaredLogger
+}
+
+func NewRedisBroker(rdb *redis.Client, logger *zap.SugaredLogger) *RedisBroker {
+	return &RedisBroker{
+		hub:    newHub(),
+		rdb:    rdb,
+		logger: logger,
+	}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, topic, msgType string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	key := redisKeyPrefix + topic
+	id, err := b.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: redisHistorySize,
+		Approx: true,
+		Values: map[string]any{"type": msgType, "data": string(raw)},
+	}).Result()
+	if err != nil {
+		return err
+	}
+	if err := b.rdb.Expire(ctx, key, redisHistoryTTL).Err(); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(Message{ID: id, Topic: topic, Type: msgType, Data: raw})
+	if err != nil {
+		return err
+	}
+	return b.rdb.Publish(ctx, key, payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(topics ...string) *Subscription {
+	return b.subscribe(topics...)
+}
+
+func (b *RedisBroker) Since(ctx context.Context, topic, lastID string) ([]Message, error) {
+	entries, err := b.rdb.XRangeN(ctx, redisKeyPrefix+topic, "("+lastID, "+", redisHistorySize).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]Message, 0, len(entries))
+	for _, e := range entries {
+		msgType, _ := e.Values["type"].(string)
+		data, _ := e.Values["data"].(string)
+		msgs = append(msgs, Message{ID: e.ID, Topic: topic, Type: msgType, Data: json.RawMessage(data)})
+	}
+	return msgs, nil
+}
+
+// Run recibe los mensajes publicados por todas las instancias y los entrega a los clientes
+// conectados a esta. Corre hasta que se cancele el contexto.
+func (b *RedisBroker) Run(ctx context.Context) {
+	ps := b.rdb.PSubscribe(ctx, redisKeyPrefix+"*")
+	defer ps.Close()
+
+	ch := ps.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var msg Message
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				b.logger.Errorw("invalid stream message", "channel", m.Channel, "error", err)
+				continue
+			}
+			if msg.Topic == "" {
+				msg.Topic = strings.TrimPrefix(m.Channel, redisKeyPrefix)
+			}
+			b.deliver(msg)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package stream
+
+import (
+	"context"
+
+	"github.com/marceterrone10/social/internal/events"
+	"github.com/marceterrone10/social/internal/store"
+	"go.uber.org/zap"
+)
+
+// Relay pasa los eventos de dominio y las notificaciones al broker, en el topic de cada destinatario
+type Relay struct {
+	broker  Broker
+	follows store.FollowRepository
+	logger  *zap.SugaredLogger
+}
+
+func NewRelay(broker Broker, follows store.FollowRepository, logger *zap.SugaredLogger) *Relay {
+	return &Relay{
+		broker:  broker,
+		follows: follows,
+		logger:  logger,
+	}
+}
+
+// HandleEvent se suscribe al bus de eventos
+func (r *Relay) HandleEvent(ctx context.Context, e events.Event) {
+	switch e.Type {
+	case events.TypePostPublished:
+		followers, err := r.follows.Followers(ctx, e.ActorID)
+		if err != nil {
+			r.logger.Errorw("error loading followers for stream", "user_id", e.ActorID, "error", err)
+			return
+		}
+
+		data := map[string]int64{"post_id": e.PostID, "user_id": e.ActorID}
+		// el autor tambien ve su post en el feed
+		for _, id := range append(followers, e.ActorID) {
+			r.publish(ctx, UserTopic(id), TypeFeedPost, data)
+		}
+	case events.TypeComment:
+		r.publish(ctx, PostTopic(e.PostID), TypeComment, map[string]int64{
+			"post_id":    e.PostID,
+			"comment_id": e.CommentID,
+			"user_id":    e.ActorID,
+		})
+	}
+}
+
+// HandleNotification se registra con Notifier.OnNotify
+func (r *Relay) HandleNotification(ctx context.Context, n store.NotificationEvent) {
+	r.publish(ctx, UserTopic(n.UserID), TypeNotification, map[string]any{
+		"type":       n.Type,
+		"actor_id":   n.ActorID,
+		"post_id":    n.PostID,
+		"comment_id": n.CommentID,
+	})
+}
+
+func (r *Relay) publish(ctx context.Context, topic, msgType string, data any) {
+	if err := r.broker.Publish(ctx, topic, msgType, data); err != nil {
+		r.logger.Errorw("error publishing stream message", "topic", topic, "type", msgType, "error", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Package stream distribuye eventos en tiempo real (items del feed, notificaciones, comentarios)
+// a los clientes conectados por SSE o WebSocket. Con Redis los eventos llegan a todas las instancias
+// de la API; sin Redis se usa un broker en memoria que solo sirve para una instancia.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tipos de mensaje
+const (
+	TypeFeedPost     = "feed.post"
+	TypeNotification = "notification"
+	TypeComment      = "comment.created"
+	TypeMessage      = "message.created"
+	TypeMessageRead  = "message.read"
+)
+
+// Message es un evento del stream. El ID tiene la forma "<unix ms>-<secuencia>" (igual que los
+// IDs de Redis Streams), crece con el tiempo y es lo que el cliente manda en Last-Event-ID.
+type Message struct {
+	ID    string          `json:"id"`
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Broker publica mensajes en un topic y permite suscribirse a varios topics
+type Broker interface {
+	Publish(ctx context.Context, topic, msgType string, data any) error
+	// Subscribe registra la suscripcion antes de devolverla, asi no se pierden mensajes entre Since y el primer receive
+	Subscribe(topics ...string) *Subscription
+	// Since devuelve los mensajes del topic posteriores a lastID que todavia estan en el historial
+	Since(ctx context.Context, topic, lastID string) ([]Message, error)
+}
+
+// UserTopic es el topic privado de un usuario (feed y notificaciones)
+func UserTopic(userID int64) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// PostTopic es el topic de la actividad de un post (comentarios nuevos)
+func PostTopic(postID int64) string {
+	return fmt.Sprintf("post:%d", postID)
+}
+
+// CompareIDs compara dos IDs de mensaje: -1 si a es anterior a b, 0 si son iguales y 1 si es posterior
+func CompareIDs(a, b string) int {
+	ams, aseq := splitID(a)
+	bms, bseq := splitID(b)
+	switch {
+	case ams < bms:
+		return -1
+	case ams > bms:
+		return 1
+	case aseq < bseq:
+		return -1
+	case aseq > bseq:
+		return 1
+	}
+	return 0
+}
+
+func splitID(id string) (uint64, uint64) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ := strconv.ParseUint(msPart, 10, 64)
+	seq, _ := strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
+
+// ValidID indica si id tiene el formato de un ID de mensaje
+func ValidID(id string) bool {
+	msPart, seqPart, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	if _, err := strconv.ParseUint(msPart, 10, 64); err != nil {
+		return false
+	}
+	_, err := strconv.ParseUint(seqPart, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,225 @@
+// Package suggestions arma las sugerencias de "a quien seguir" a partir del grafo de seguidores, de las
+// interacciones con los mismos posts y de los tags en comun. Calcularlas es caro, asi que se guardan por
+// usuario (en Redis o, sin Redis, en memoria) y se recalculan en segundo plano cuando quedan viejas o
+// cuando cambia a quien sigue el usuario.
+package suggestions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/marceterrone10/social/internal/events"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type Config struct {
+	// cantidad de sugerencias que se calculan y guardan por usuario
+	PoolSize int
+	// periodo de actividad que se tiene en cuenta para co-engagement y tags
+	Window time.Duration
+	// a partir de esta antiguedad las sugerencias se siguen sirviendo pero se recalculan en segundo plano
+	StaleAfter time.Duration
+	// a partir de esta antiguedad se descartan y se recalculan en la proxima lectura
+	MaxAge time.Duration
+	// cantidad maxima de recalculos pendientes, los que no entran se descartan
+	QueueSize int
+}
+
+type entry struct {
+	Candidates []*store.SuggestionCandidate `json:"candidates"`
+	ComputedAt time.Time                    `json:"computed_at"`
+}
+
+type Service struct {
+	rdb    *redis.Client
+	repo   store.SuggestionRepository
+	cfg    Config
+	logger *zap.SugaredLogger
+
+	queue chan int64
+
+	mu      sync.Mutex
+	pending map[int64]bool
+	// cache en memoria cuando Redis esta deshabilitado
+	cache map[int64]*entry
+}
+
+// NewService crea el servicio. Con rdb nil las sugerencias se guardan en memoria.
+func NewService(rdb *redis.Client, repo store.SuggestionRepository, cfg Config, logger *zap.SugaredLogger) *Service {
+	return &Service{
+		rdb:     rdb,
+		repo:    repo,
+		cfg:     cfg,
+		logger:  logger,
+		queue:   make(chan int64, cfg.QueueSize),
+		pending: map[int64]bool{},
+		cache:   map[int64]*entry{},
+	}
+}
+
+func cacheKey(userID int64) string {
+	return fmt.Sprintf("suggestions:%d", userID)
+}
+
+// Suggestions devuelve hasta limit sugerencias para el usuario. Las exclusiones (seguidos, bloqueados,
+// suspendidos, descartados) se vuelven a aplicar al leer porque pueden haber cambiado desde el calculo.
+func (s *Service) Suggestions(ctx context.Context, userID int64, limit int) ([]*store.SuggestionCandidate, error) {
+	e, err := s.load(ctx, userID)
+	if err != nil {
+		// si el cache falla se calcula en el momento
+		s.logger.Warnw("error reading cached suggestions", "user_id", userID, "error", err)
+	}
+
+	if e == nil || time.Since(e.ComputedAt) > s.cfg.MaxAge {
+		if e, err = s.compute(ctx, userID); err != nil {
+			return nil, err
+		}
+	} else if time.Since(e.ComputedAt) > s.cfg.StaleAfter {
+		s.Refresh(userID)
+	}
+
+	if len(e.Candidates) == 0 {
+		return []*store.SuggestionCandidate{}, nil
+	}
+
+	ids := make([]int64, len(e.Candidates))
+	byID := make(map[int64]*store.SuggestionCandidate, len(e.Candidates))
+	for i, c := range e.Candidates {
+		ids[i] = c.UserID
+		byID[c.UserID] = c
+	}
+	eligible, err := s.repo.Eligible(ctx, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	// si se descartaron sugerencias se recalcula para completar la lista
+	if len(eligible) < len(ids) {
+		s.Refresh(userID)
+	}
+
+	suggestions := make([]*store.SuggestionCandidate, 0, min(limit, len(eligible)))
+	for _, id := range eligible[:min(limit, len(eligible))] {
+		suggestions = append(suggestions, byID[id])
+	}
+	return suggestions, nil
+}
+
+// Dismiss hace que suggestedID no se le vuelva a sugerir al usuario
+func (s *Service) Dismiss(ctx context.Context, userID, suggestedID int64) error {
+	return s.repo.Dismiss(ctx, userID, suggestedID)
+}
+
+// Refresh encola el recalculo de las sugerencias del usuario. No bloquea: si la cola esta llena se descarta.
+func (s *Service) Refresh(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending[userID] {
+		return
+	}
+	select {
+	case s.queue <- userID:
+		s.pending[userID] = true
+	default:
+		s.logger.Warnw("suggestions queue full, skipping refresh", "user_id", userID)
+	}
+}
+
+// Run recalcula las sugerencias encoladas hasta que se cancele el contexto. Sin Redis ademas limpia
+// del cache en memoria las entradas que pasaron MaxAge.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.StaleAfter)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case userID := <-s.queue:
+			s.mu.Lock()
+			delete(s.pending, userID)
+			s.mu.Unlock()
+
+			if _, err := s.compute(ctx, userID); err != nil {
+				s.logger.Errorw("error computing suggestions", "user_id", userID, "error", err)
+			}
+		case <-ticker.C:
+			s.prune()
+		}
+	}
+}
+
+// HandleEvent se suscribe al bus de eventos: cuando el usuario sigue o deja de seguir a alguien
+// cambian sus amigos de amigos
+func (s *Service) HandleEvent(ctx context.Context, e events.Event) {
+	switch e.Type {
+	case events.TypeFollow, events.TypeUnfollow:
+		s.Refresh(e.ActorID)
+	}
+}
+
+func (s *Service) compute(ctx context.Context, userID int64) (*entry, error) {
+	candidates, err := s.repo.Candidates(ctx, userID, time.Now().Add(-s.cfg.Window), s.cfg.PoolSize)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &entry{Candidates: candidates, ComputedAt: time.Now()}
+	if err := s.save(ctx, userID, e); err != nil {
+		s.logger.Warnw("error caching suggestions", "user_id", userID, "error", err)
+	}
+	return e, nil
+}
+
+func (s *Service) load(ctx context.Context, userID int64) (*entry, error) {
+	if s.rdb == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.cache[userID], nil
+	}
+
+	data, err := s.rdb.Get(ctx, cacheKey(userID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *Service) save(ctx context.Context, userID int64, e *entry) error {
+	if s.rdb == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.cache[userID] = e
+		return nil
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, cacheKey(userID), data, s.cfg.MaxAge).Err()
+}
+
+func (s *Service) prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, e := range s.cache {
+		if time.Since(e.ComputedAt) > s.cfg.MaxAge {
+			delete(s.cache, id)
+		}
+	}
+}
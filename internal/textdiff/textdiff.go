@@ -0,0 +1,68 @@
+package textdiff
+
+import "strings"
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+type Line struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines calcula el diff linea a linea entre a y b usando la subsecuencia comun mas larga.
+// Los posts son cortos, asi que la tabla O(n*m) no es un problema.
+func Lines(a, b string) []Line {
+	x := splitLines(a)
+	y := splitLines(b)
+
+	// lcs[i][j] = largo de la LCS entre x[i:] e y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	diff := make([]Line, 0, len(x)+len(y))
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			diff = append(diff, Line{OpEqual, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, Line{OpDelete, x[i]})
+			i++
+		default:
+			diff = append(diff, Line{OpInsert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		diff = append(diff, Line{OpDelete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		diff = append(diff, Line{OpInsert, y[j]})
+	}
+
+	return diff
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
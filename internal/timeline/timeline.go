@@ -0,0 +1,297 @@
+// Package timeline mantiene el home timeline de cada usuario en un sorted set de Redis (fan-out on write):
+// al publicarse un post su id se agrega al timeline de cada seguidor del autor, asi leer el feed no
+// necesita el JOIN de PostsStore.GetFeed. Las cuentas con muchos seguidores no se reparten al escribir
+// (fan-out on read): sus posts y reposts se mezclan al leer. Sin Redis el feed se sigue leyendo de Postgres.
+package timeline
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/marceterrone10/social/internal/events"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type Config struct {
+	// a partir de esta cantidad de seguidores los posts del autor se mezclan al leer en vez de repartirse
+	CelebrityThreshold int
+	// cantidad de posts que se guardan por timeline, las paginas mas alla se leen de Postgres
+	MaxSize int
+	// cantidad de posts del autor que se agregan al timeline al empezar a seguirlo
+	BackfillSize int
+	// un timeline que no se lee en este tiempo expira y se reconstruye desde Postgres en la proxima lectura
+	TTL time.Duration
+}
+
+// add agrega el post a un timeline que ya esta construido y lo recorta a MaxSize. Si el timeline no
+// esta construido no hace nada: se arma completo desde Postgres la proxima vez que se lea.
+// GT hace que un repost posterior mueva el post arriba pero uno anterior no lo baje.
+var add = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 0 then
+	return 0
+end
+for i = 2, #ARGV, 2 do
+	redis.call('ZADD', KEYS[1], 'GT', ARGV[i], ARGV[i + 1])
+end
+redis.call('ZREMRANGEBYRANK', KEYS[1], 0, -(tonumber(ARGV[1]) + 1))
+return 1
+`)
+
+type Service struct {
+	rdb     *redis.Client
+	posts   store.PostRepository
+	follows store.FollowRepository
+	cfg     Config
+	logger  *zap.SugaredLogger
+}
+
+// NewService crea el servicio. Con rdb nil el feed se lee siempre de Postgres y los eventos se ignoran.
+func NewService(rdb *redis.Client, posts store.PostRepository, follows store.FollowRepository, cfg Config, logger *zap.SugaredLogger) *Service {
+	return &Service{
+		rdb:     rdb,
+		posts:   posts,
+		follows: follows,
+		cfg:     cfg,
+		logger:  logger,
+	}
+}
+
+func timelineKey(userID int64) string {
+	return fmt.Sprintf("timeline:%d", userID)
+}
+
+// readyKey marca que el timeline esta construido, aunque este vacio (un sorted set vacio no existe en Redis)
+func readyKey(userID int64) string {
+	return fmt.Sprintf("timeline:%d:ready", userID)
+}
+
+// Feed devuelve una pagina del home timeline. El orden ascendente y las paginas que pasan MaxSize
+// se leen de Postgres.
+func (s *Service) Feed(ctx context.Context, userID int64, fq store.PaginatedQuery) ([]*store.PostWithMetadata, error) {
+	if s.rdb == nil || fq.Sort != "desc" || fq.Offset+fq.Limit > s.cfg.MaxSize {
+		return s.posts.GetFeed(ctx, userID, fq)
+	}
+
+	ids, err := s.page(ctx, userID, fq.Offset, fq.Limit)
+	if err != nil {
+		// si Redis falla el feed sigue funcionando, mas lento
+		s.logger.Warnw("timeline unavailable, reading feed from postgres", "user_id", userID, "error", err)
+		return s.posts.GetFeed(ctx, userID, fq)
+	}
+	if len(ids) == 0 {
+		return []*store.PostWithMetadata{}, nil
+	}
+
+	// la query ademas descarta lo que quedo viejo en el timeline (posts borrados, autores que ya no sigue)
+	return s.posts.GetFeedByIds(ctx, userID, ids)
+}
+
+// page devuelve los ids de la pagina, mezclando el timeline de Redis con los posts y reposts de las cuentas grandes
+func (s *Service) page(ctx context.Context, userID int64, offset, limit int) ([]int64, error) {
+	ready, err := s.rdb.Exists(ctx, readyKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if ready == 0 {
+		if err := s.Rebuild(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	n := offset + limit
+	zs, err := s.rdb.ZRevRangeWithScores(ctx, timelineKey(userID), 0, int64(n-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]store.TimelineEntry, 0, len(zs))
+	for _, z := range zs {
+		id, err := strconv.ParseInt(z.Member.(string), 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, store.TimelineEntry{PostID: id, At: time.UnixMilli(int64(z.Score))})
+	}
+
+	celebrities, err := s.follows.FollowedWithMinFollowers(ctx, userID, s.cfg.CelebrityThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if len(celebrities) > 0 {
+		extra, err := s.posts.GetAuthorsTimeline(ctx, celebrities, n)
+		if err != nil {
+			return nil, err
+		}
+		entries = merge(entries, extra)
+	}
+
+	pipe := s.rdb.Pipeline()
+	pipe.Expire(ctx, timelineKey(userID), s.cfg.TTL)
+	pipe.Expire(ctx, readyKey(userID), s.cfg.TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Warnw("error refreshing timeline ttl", "user_id", userID, "error", err)
+	}
+
+	if offset >= len(entries) {
+		return nil, nil
+	}
+	entries = entries[offset:min(n, len(entries))]
+
+	ids := make([]int64, len(entries))
+	for i, e := range entries {
+		ids[i] = e.PostID
+	}
+	return ids, nil
+}
+
+// merge junta dos listas de entradas en orden descendente sin repetir posts
+func merge(a, b []store.TimelineEntry) []store.TimelineEntry {
+	all := append(a, b...)
+	slices.SortStableFunc(all, func(x, y store.TimelineEntry) int { return y.At.Compare(x.At) })
+
+	seen := make(map[int64]bool, len(all))
+	merged := all[:0]
+	for _, e := range all {
+		if !seen[e.PostID] {
+			seen[e.PostID] = true
+			merged = append(merged, e)
+		}
+	}
+	return merged
+}
+
+// Rebuild arma el timeline del usuario desde Postgres, reemplazando lo que hubiera en Redis
+func (s *Service) Rebuild(ctx context.Context, userID int64) error {
+	entries, err := s.posts.GetTimeline(ctx, userID, s.cfg.MaxSize)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		key := timelineKey(userID)
+		pipe.Del(ctx, key)
+		if len(entries) > 0 {
+			pipe.ZAdd(ctx, key, members(entries)...)
+			pipe.Expire(ctx, key, s.cfg.TTL)
+		}
+		pipe.Set(ctx, readyKey(userID), 1, s.cfg.TTL)
+		return nil
+	})
+	return err
+}
+
+func members(entries []store.TimelineEntry) []redis.Z {
+	zs := make([]redis.Z, len(entries))
+	for i, e := range entries {
+		zs[i] = redis.Z{Score: float64(e.At.UnixMilli()), Member: e.PostID}
+	}
+	return zs
+}
+
+// HandleEvent se suscribe al bus de eventos y mantiene los timelines al dia
+func (s *Service) HandleEvent(ctx context.Context, e events.Event) {
+	if s.rdb == nil {
+		return
+	}
+
+	var err error
+	switch e.Type {
+	case events.TypePostPublished, events.TypeRepost:
+		err = s.fanOut(ctx, e.ActorID, store.TimelineEntry{PostID: e.PostID, At: e.CreatedAt})
+	case events.TypePostDeleted:
+		err = s.remove(ctx, e.ActorID, e.PostID)
+	case events.TypeFollow:
+		err = s.backfill(ctx, e.ActorID, e.UserID)
+	case events.TypeUnfollow:
+		err = s.prune(ctx, e.ActorID, e.UserID)
+	}
+	if err != nil {
+		s.logger.Errorw("error updating timelines", "type", e.Type, "actor_id", e.ActorID, "post_id", e.PostID, "error", err)
+	}
+}
+
+// fanOut agrega la entrada al timeline del autor y, si no es una cuenta grande, al de sus seguidores
+func (s *Service) fanOut(ctx context.Context, authorID int64, entry store.TimelineEntry) error {
+	followers, err := s.follows.Followers(ctx, authorID)
+	if err != nil {
+		return err
+	}
+
+	targets := []int64{authorID}
+	if len(followers) < s.cfg.CelebrityThreshold {
+		targets = append(targets, followers...)
+	}
+
+	pipe := s.rdb.Pipeline()
+	for _, id := range targets {
+		s.addTo(ctx, pipe, id, []store.TimelineEntry{entry})
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Service) addTo(ctx context.Context, pipe redis.Pipeliner, userID int64, entries []store.TimelineEntry) {
+	args := []any{s.cfg.MaxSize}
+	for _, e := range entries {
+		args = append(args, e.At.UnixMilli(), e.PostID)
+	}
+	// dentro de un pipeline no hay fallback de EVALSHA a EVAL, por eso se manda el script completo
+	add.Eval(ctx, pipe, []string{timelineKey(userID), readyKey(userID)}, args...)
+}
+
+// remove saca el post borrado del timeline del autor y de sus seguidores
+func (s *Service) remove(ctx context.Context, authorID, postID int64) error {
+	followers, err := s.follows.Followers(ctx, authorID)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.rdb.Pipeline()
+	for _, id := range append(followers, authorID) {
+		pipe.ZRem(ctx, timelineKey(id), postID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// backfill agrega los ultimos posts y reposts del usuario seguido al timeline del nuevo seguidor
+func (s *Service) backfill(ctx context.Context, followerID, followedID int64) error {
+	count, err := s.follows.FollowerCount(ctx, followedID)
+	if err != nil {
+		return err
+	}
+	// los posts de las cuentas grandes ya se mezclan al leer
+	if count >= s.cfg.CelebrityThreshold {
+		return nil
+	}
+
+	entries, err := s.posts.GetAuthorsTimeline(ctx, []int64{followedID}, s.cfg.BackfillSize)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	pipe := s.rdb.Pipeline()
+	s.addTo(ctx, pipe, followerID, entries)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// prune saca del timeline del seguidor los posts y reposts del usuario que dejo de seguir. Si alguno
+// ademas lo publico o reposteo otro usuario seguido, vuelve a aparecer cuando el timeline se reconstruya.
+func (s *Service) prune(ctx context.Context, followerID, unfollowedID int64) error {
+	entries, err := s.posts.GetAuthorsTimeline(ctx, []int64{unfollowedID}, s.cfg.MaxSize)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	ids := make([]any, len(entries))
+	for i, e := range entries {
+		ids[i] = e.PostID
+	}
+	return s.rdb.ZRem(ctx, timelineKey(followerID), ids...).Err()
+}
@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/marceterrone10/social/internal/mailer"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/marceterrone10/social/internal/store/cache"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StoreObserver abre un span por cada llamada a un repositorio. Implementa store.Observer.
+type StoreObserver struct{}
+
+func (StoreObserver) Start(ctx context.Context, repository, method string) (context.Context, func(error)) {
+	ctx, span := tracer().Start(ctx, "store."+repository+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBCollectionName(repository),
+			semconv.DBOperationName(method),
+		),
+	)
+	return ctx, func(err error) {
+		// un registro que no existe es una respuesta valida, no un error de la consulta
+		if errors.Is(err, store.ErrNotFound) {
+			err = nil
+		}
+		end(span, err)
+	}
+}
+
+type cacheBackend struct {
+	next cache.Backend
+}
+
+// CacheBackend envuelve el backend del cache con un span por operacion
+func CacheBackend(next cache.Backend) cache.Backend {
+	return &cacheBackend{next: next}
+}
+
+func (b *cacheBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	ctx, span := tracer().Start(ctx, "cache.Get", trace.WithAttributes(attribute.String("cache.key", key)))
+	data, ok, err := b.next.Get(ctx, key)
+	span.SetAttributes(attribute.Bool("cache.hit", ok))
+	end(span, err)
+	return data, ok, err
+}
+
+func (b *cacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ctx, span := tracer().Start(ctx, "cache.Set", trace.WithAttributes(attribute.String("cache.key", key)))
+	err := b.next.Set(ctx, key, value, ttl)
+	end(span, err)
+	return err
+}
+
+func (b *cacheBackend) Delete(ctx context.Context, keys ...string) error {
+	ctx, span := tracer().Start(ctx, "cache.Delete", trace.WithAttributes(attribute.Int("cache.keys", len(keys))))
+	err := b.next.Delete(ctx, keys...)
+	end(span, err)
+	return err
+}
+
+// RedisHook abre un span por comando (o pipeline) de Redis. Se agrega con rdb.AddHook.
+type RedisHook struct{}
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := tracer().Start(ctx, "redis."+cmd.Name(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemNameRedis, semconv.DBOperationName(cmd.Name())),
+		)
+		err := next(ctx, cmd)
+		end(span, redisError(err))
+		return err
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = cmd.Name()
+		}
+		ctx, span := tracer().Start(ctx, "redis.pipeline",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemNameRedis, semconv.DBOperationName(strings.Join(names, " "))),
+		)
+		err := next(ctx, cmds)
+		end(span, redisError(err))
+		return err
+	}
+}
+
+// redisError ignora redis.Nil, que solo indica que la clave no existe
+func redisError(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
+
+type instrumentedMailer struct {
+	next mailer.Client
+}
+
+// Mailer envuelve el cliente con un span por envio
+func Mailer(next mailer.Client) mailer.Client {
+	return &instrumentedMailer{next: next}
+}
+
+func (m *instrumentedMailer) Send(ctx context.Context, templateFile, username string, email string, data any, isSandbox bool) error {
+	ctx, span := tracer().Start(ctx, "mailer.Send", trace.WithAttributes(
+		attribute.String("mailer.template", templateFile),
+		attribute.Bool("mailer.sandbox", isSandbox),
+	))
+	err := m.next.Send(ctx, templateFile, username, email, data, isSandbox)
+	end(span, err)
+	return err
+}
@@ -0,0 +1,423 @@
+// Package trending calcula los hashtags y posts en tendencia en ventanas de 1h, 24h y 7d. El score
+// no es un conteo: cada interaccion pesa menos cuanto mas vieja es (vida media por ventana), asi que
+// lo que crece rapido ahora le gana a lo que junto muchas interacciones hace dias.
+//
+// Con Redis los scores se mantienen incrementalmente en sorted sets por bucket de tiempo y al leer se
+// suman los buckets de la ventana con su peso de decaimiento. Sin Redis se agrega periodicamente desde Postgres.
+package trending
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/marceterrone10/social/internal/events"
+	"github.com/marceterrone10/social/internal/richtext"
+	"github.com/marceterrone10/social/internal/store"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type Window struct {
+	Name     string
+	Duration time.Duration
+	// tiempo en que una interaccion pasa a valer la mitad
+	HalfLife time.Duration
+	// resolucion de los sorted sets en Redis
+	Bucket time.Duration
+}
+
+var Windows = []Window{
+	{Name: "1h", Duration: time.Hour, HalfLife: 15 * time.Minute, Bucket: 5 * time.Minute},
+	{Name: "24h", Duration: 24 * time.Hour, HalfLife: 6 * time.Hour, Bucket: time.Hour},
+	{Name: "7d", Duration: 7 * 24 * time.Hour, HalfLife: 48 * time.Hour, Bucket: 6 * time.Hour},
+}
+
+func WindowByName(name string) (Window, bool) {
+	for _, w := range Windows {
+		if w.Name == name {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
+
+type Config struct {
+	// cantidad de tags y posts que se calculan por ventana
+	Limit int
+	// cuanto se reutiliza un resultado antes de recalcularlo
+	CacheTTL time.Duration
+	// cada cuanto se agrega desde Postgres cuando Redis esta deshabilitado
+	AggregateInterval time.Duration
+}
+
+type Result struct {
+	Window     string                    `json:"window"`
+	Tags       []store.TrendingTag       `json:"tags"`
+	Posts      []store.TrendingPostScore `json:"-"`
+	ComputedAt time.Time                 `json:"computed_at"`
+}
+
+const (
+	kindTags  = "tags"
+	kindPosts = "posts"
+)
+
+type Service struct {
+	rdb    *redis.Client
+	repo   store.TrendingRepository
+	cfg    Config
+	logger *zap.SugaredLogger
+
+	mu    sync.Mutex
+	cache map[string]*Result
+}
+
+// NewService crea el servicio. Con rdb nil los scores se agregan desde Postgres.
+func NewService(rdb *redis.Client, repo store.TrendingRepository, cfg Config, logger *zap.SugaredLogger) *Service {
+	return &Service{
+		rdb:    rdb,
+		repo:   repo,
+		cfg:    cfg,
+		logger: logger,
+		cache:  map[string]*Result{},
+	}
+}
+
+// Trending devuelve las tendencias de la ventana, del cache si todavia estan frescas
+func (s *Service) Trending(ctx context.Context, w Window) (*Result, error) {
+	ttl := s.cfg.CacheTTL
+	if s.rdb == nil {
+		// sin Redis el resultado lo refresca Run, el cache solo tiene que durar hasta la proxima agregacion
+		ttl += s.cfg.AggregateInterval
+	}
+
+	s.mu.Lock()
+	cached, ok := s.cache[w.Name]
+	s.mu.Unlock()
+	if ok && time.Since(cached.ComputedAt) < ttl {
+		return cached, nil
+	}
+
+	result, err := s.compute(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cache[w.Name] = result
+	s.mu.Unlock()
+	return result, nil
+}
+
+// Run agrega las tendencias desde Postgres cada AggregateInterval hasta que se cancele el contexto.
+// Con Redis no hace nada: los scores se mantienen con cada evento.
+func (s *Service) Run(ctx context.Context) {
+	if s.rdb != nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.AggregateInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, w := range Windows {
+			result, err := s.compute(ctx, w)
+			if err != nil {
+				s.logger.Errorw("error aggregating trending", "window", w.Name, "error", err)
+				continue
+			}
+			s.mu.Lock()
+			s.cache[w.Name] = result
+			s.mu.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) compute(ctx context.Context, w Window) (*Result, error) {
+	result := &Result{Window: w.Name, ComputedAt: time.Now()}
+
+	var err error
+	if s.rdb == nil {
+		if result.Tags, err = s.repo.TopTags(ctx, w.Duration, w.HalfLife, s.cfg.Limit); err != nil {
+			return nil, err
+		}
+		if result.Posts, err = s.repo.TopPosts(ctx, w.Duration, w.HalfLife, s.cfg.Limit); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	tags, err := s.top(ctx, kindTags, w, result.ComputedAt, s.cfg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	result.Tags = make([]store.TrendingTag, len(tags))
+	for i, z := range tags {
+		result.Tags[i] = store.TrendingTag{Tag: z.Member.(string), Score: z.Score}
+	}
+
+	// se piden de mas porque los de autores suspendidos o posts borrados se descartan
+	posts, err := s.top(ctx, kindPosts, w, result.ComputedAt, s.cfg.Limit*2)
+	if err != nil {
+		return nil, err
+	}
+	scores := map[int64]float64{}
+	ids := make([]int64, 0, len(posts))
+	for _, z := range posts {
+		id, err := strconv.ParseInt(z.Member.(string), 10, 64)
+		if err != nil {
+			continue
+		}
+		scores[id] = z.Score
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 {
+		if ids, err = s.repo.ExcludeSuspended(ctx, ids); err != nil {
+			return nil, err
+		}
+	}
+	result.Posts = make([]store.TrendingPostScore, 0, min(len(ids), s.cfg.Limit))
+	for _, id := range ids[:min(len(ids), s.cfg.Limit)] {
+		result.Posts = append(result.Posts, store.TrendingPostScore{PostID: id, Score: scores[id]})
+	}
+	return result, nil
+}
+
+func bucketKey(kind string, w Window, start time.Time) string {
+	return fmt.Sprintf("trending:%s:%s:%d", kind, w.Name, start.Unix())
+}
+
+// authorTagsKey guarda lo que los posts de un autor sumaron a los tags del bucket, para poder descontarlo
+func authorTagsKey(w Window, start time.Time, authorID int64) string {
+	return fmt.Sprintf("%s:author:%d", bucketKey(kindTags, w, start), authorID)
+}
+
+// bucketTTL es cuanto vive un bucket: la ventana mas un margen para el bucket en curso
+func (w Window) bucketTTL() time.Duration {
+	return w.Duration + 2*w.Bucket
+}
+
+// buckets devuelve el inicio de los buckets de la ventana, del mas nuevo al mas viejo, y el peso de cada
+// uno segun su antiguedad
+func (w Window) buckets(now time.Time) ([]time.Time, []float64) {
+	current := now.Truncate(w.Bucket)
+	n := int(w.Duration / w.Bucket)
+
+	starts := make([]time.Time, 0, n+1)
+	weights := make([]float64, 0, n+1)
+	for i := 0; i <= n; i++ {
+		start := current.Add(-time.Duration(i) * w.Bucket)
+		// el peso se toma en la mitad del bucket
+		age := now.Sub(start.Add(w.Bucket / 2))
+		starts = append(starts, start)
+		weights = append(weights, math.Exp2(-float64(max(age, 0))/float64(w.HalfLife)))
+	}
+	return starts, weights
+}
+
+// top suma los buckets de la ventana, cada uno pesado por su antiguedad, y devuelve los primeros limit
+func (s *Service) top(ctx context.Context, kind string, w Window, now time.Time, limit int) ([]redis.Z, error) {
+	starts, weights := w.buckets(now)
+	keys := make([]string, len(starts))
+	for i, start := range starts {
+		keys[i] = bucketKey(kind, w, start)
+	}
+
+	dest := fmt.Sprintf("trending:%s:%s", kind, w.Name)
+	pipe := s.rdb.TxPipeline()
+	pipe.ZUnionStore(ctx, dest, &redis.ZStore{Keys: keys, Weights: weights, Aggregate: "SUM"})
+	pipe.Expire(ctx, dest, s.cfg.CacheTTL)
+	top := pipe.ZRevRangeWithScores(ctx, dest, 0, int64(limit-1))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	return top.Val(), nil
+}
+
+// HandleEvent se suscribe al bus de eventos y suma cada interaccion a los sorted sets
+func (s *Service) HandleEvent(ctx context.Context, e events.Event) {
+	if s.rdb == nil {
+		// Postgres ya excluye a los suspendidos, alcanza con no volver a usar el resultado cacheado
+		if e.Type == events.TypeUserSuspended {
+			s.clearCache()
+		}
+		return
+	}
+
+	var err error
+	switch e.Type {
+	case events.TypePostPublished:
+		// un post nuevo empuja sus tags pero no cuenta como interaccion con el post
+		err = s.record(ctx, e.PostID, store.TrendingWeightPost, e.CreatedAt, false)
+	case events.TypeReaction:
+		err = s.record(ctx, e.PostID, store.TrendingWeightReaction, e.CreatedAt, true)
+	case events.TypeComment:
+		err = s.record(ctx, e.PostID, store.TrendingWeightComment, e.CreatedAt, true)
+	case events.TypeRepost:
+		err = s.record(ctx, e.PostID, store.TrendingWeightRepost, e.CreatedAt, true)
+	case events.TypeUserSuspended:
+		err = s.removeAuthor(ctx, e.UserID)
+	}
+	if err != nil {
+		s.logger.Errorw("error recording trending event", "type", e.Type, "post_id", e.PostID, "error", err)
+	}
+}
+
+func (s *Service) record(ctx context.Context, postID int64, weight float64, at time.Time, countPost bool) error {
+	signals, err := s.repo.GetPostSignals(ctx, postID)
+	if err != nil {
+		return err
+	}
+	// el contenido de usuarios suspendidos no suma a las tendencias
+	if signals.Suspended {
+		return nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	for _, w := range Windows {
+		start := at.Truncate(w.Bucket)
+		ttl := w.bucketTTL()
+
+		if countPost {
+			key := bucketKey(kindPosts, w, start)
+			pipe.ZIncrBy(ctx, key, weight, strconv.FormatInt(postID, 10))
+			pipe.Expire(ctx, key, ttl)
+		}
+		if len(signals.Tags) > 0 {
+			key := bucketKey(kindTags, w, start)
+			authorKey := authorTagsKey(w, start, signals.AuthorID)
+			for _, t := range signals.Tags {
+				if tag, ok := richtext.NormalizeTag(t); ok {
+					pipe.ZIncrBy(ctx, key, weight, tag)
+					pipe.ZIncrBy(ctx, authorKey, weight, tag)
+				}
+			}
+			pipe.Expire(ctx, key, ttl)
+			pipe.Expire(ctx, authorKey, ttl)
+		}
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// removeAuthor descuenta de los tags en tendencia lo que sumaron los posts de un usuario suspendido
+func (s *Service) removeAuthor(ctx context.Context, authorID int64) error {
+	type bucket struct {
+		key    string
+		author string
+		scores *redis.ZSliceCmd
+	}
+
+	now := time.Now()
+	pipe := s.rdb.Pipeline()
+	var buckets []bucket
+	for _, w := range Windows {
+		// todos los buckets que pueden seguir en Redis, incluidos los del margen del TTL
+		for start := now.Truncate(w.Bucket); now.Sub(start) < w.bucketTTL(); start = start.Add(-w.Bucket) {
+			author := authorTagsKey(w, start, authorID)
+			buckets = append(buckets, bucket{
+				key:    bucketKey(kindTags, w, start),
+				author: author,
+				scores: pipe.ZRangeWithScores(ctx, author, 0, -1),
+			})
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return err
+	}
+
+	pipe = s.rdb.Pipeline()
+	for _, b := range buckets {
+		for _, z := range b.scores.Val() {
+			pipe.ZIncrBy(ctx, b.key, -z.Score, z.Member.(string))
+		}
+		// lo que queda en cero (o por debajo, por redondeo) ya no es tendencia
+		pipe.ZRemRangeByScore(ctx, b.key, "-inf", "0")
+		pipe.Del(ctx, b.author)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	s.clearCache()
+	return nil
+}
+
+func (s *Service) clearCache() {
+	s.mu.Lock()
+	clear(s.cache)
+	s.mu.Unlock()
+}
+
+// WithoutAuthors devuelve tags sin lo que sumaron los posts de authorIDs (por ejemplo los usuarios que
+// bloqueo quien pide las tendencias), reordenados. tags no se modifica: es el resultado compartido del cache.
+func (s *Service) WithoutAuthors(ctx context.Context, w Window, tags []store.TrendingTag, authorIDs []int64) ([]store.TrendingTag, error) {
+	if len(authorIDs) == 0 || len(tags) == 0 {
+		return tags, nil
+	}
+
+	discount, err := s.authorTagScores(ctx, w, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]store.TrendingTag, 0, len(tags))
+	for _, t := range tags {
+		t.Score -= discount[t.Tag]
+		// tolerancia para el error de redondeo de las sumas
+		if t.Score > 1e-9 {
+			result = append(result, t)
+		}
+	}
+	slices.SortStableFunc(result, func(a, b store.TrendingTag) int { return cmp.Compare(b.Score, a.Score) })
+	return result, nil
+}
+
+// authorTagScores devuelve cuanto suman los posts de authorIDs a cada tag de la ventana
+func (s *Service) authorTagScores(ctx context.Context, w Window, authorIDs []int64) (map[string]float64, error) {
+	scores := map[string]float64{}
+
+	if s.rdb == nil {
+		tags, err := s.repo.TagScoresByAuthors(ctx, w.Duration, w.HalfLife, authorIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tags {
+			scores[t.Tag] = t.Score
+		}
+		return scores, nil
+	}
+
+	starts, weights := w.buckets(time.Now())
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.ZSliceCmd, 0, len(starts)*len(authorIDs))
+	cmdWeights := make([]float64, 0, cap(cmds))
+	for i, start := range starts {
+		for _, id := range authorIDs {
+			cmds = append(cmds, pipe.ZRangeWithScores(ctx, authorTagsKey(w, start, id), 0, -1))
+			cmdWeights = append(cmdWeights, weights[i])
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for i, cmd := range cmds {
+		for _, z := range cmd.Val() {
+			scores[z.Member.(string)] += z.Score * cmdWeights[i]
+		}
+	}
+	return scores, nil
+}